@@ -0,0 +1,222 @@
+package main
+
+import (
+  "bytes"
+  "crypto/tls"
+  "encoding/json"
+  "fmt"
+  "io"
+  "net/http"
+  "net/url"
+  "slices"
+  "strings"
+  "time"
+)
+
+// Define a snippet type to hold a snippet as it's represented by the JSON
+// API. Expires is nil if the snippet never expires.
+type snippet struct {
+  Slug             string     `json:"slug"`
+  Title            string     `json:"title"`
+  Content          string     `json:"content"`
+  Language         string     `json:"language"`
+  Visibility       string     `json:"visibility"`
+  BurnAfterReading bool       `json:"burn_after_reading"`
+  Tags             []string   `json:"tags"`
+  Author           string     `json:"author"`
+  Created          time.Time  `json:"created"`
+  Expires          *time.Time `json:"expires"`
+}
+
+// Define a snippetInput type to hold the body of a create request. Empty
+// fields are left out, so that the server's defaults apply.
+type snippetInput struct {
+  Title            string   `json:"title"`
+  Content          string   `json:"content"`
+  Language         string   `json:"language,omitempty"`
+  Visibility       string   `json:"visibility,omitempty"`
+  BurnAfterReading bool     `json:"burn_after_reading,omitempty"`
+  Tags             []string `json:"tags,omitempty"`
+  Expires          string   `json:"expires,omitempty"`
+  ExpiresAt        string   `json:"expires_at,omitempty"`
+}
+
+// Define an apiError type for the error envelope sent by the JSON API, so
+// that we can show the server's explanation of what went wrong.
+type apiError struct {
+  Status  int               `json:"status"`
+  Message string            `json:"message"`
+  Fields  map[string]string `json:"fields"`
+}
+
+func (e *apiError) Error() string {
+  if len(e.Fields) == 0 {
+    return e.Message
+  }
+
+  // List the field errors in a consistent order.
+  var fields []string
+  for field, message := range e.Fields {
+    fields = append(fields, fmt.Sprintf("  %s: %s", field, message))
+  }
+  slices.Sort(fields)
+
+  return e.Message + "\n" + strings.Join(fields, "\n")
+}
+
+// Define a client type which talks to the JSON API of a snippetbox server.
+type client struct {
+  server string
+  token  string
+  http   *http.Client
+}
+
+// newClient() returns a client for the server and token in the config.
+// Setting Insecure skips TLS certificate checks, which is only meant for
+// talking to a development server with a self-signed certificate.
+func newClient(cfg config) *client {
+  httpClient := &http.Client{Timeout: 30 * time.Second}
+
+  if cfg.Insecure {
+    transport := http.DefaultTransport.(*http.Transport).Clone()
+    transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+    httpClient.Transport = transport
+  }
+
+  return &client{
+    server: strings.TrimSuffix(cfg.Server, "/"),
+    token:  cfg.Token,
+    http:   httpClient,
+  }
+}
+
+// snippetURL() returns the URL of the web page for a snippet.
+func (c *client) snippetURL(slug string) string {
+  return c.server + "/snippet/view/" + slug
+}
+
+// do() sends a request to the API and returns the raw JSON response body. If
+// body isn't nil it's encoded as JSON. Error responses are returned as an
+// *apiError.
+func (c *client) do(method, path string, body any) ([]byte, error) {
+  if c.server == "" {
+    return nil, fmt.Errorf("no server configured; run \"snippet login\" first")
+  }
+
+  var reqBody io.Reader
+  if body != nil {
+    js, err := json.Marshal(body)
+    if err != nil {
+      return nil, err
+    }
+    reqBody = bytes.NewReader(js)
+  }
+
+  req, err := http.NewRequest(method, c.server + path, reqBody)
+  if err != nil {
+    return nil, err
+  }
+
+  req.Header.Set("Accept", "application/json")
+  if body != nil {
+    req.Header.Set("Content-Type", "application/json")
+  }
+  if c.token != "" {
+    req.Header.Set("Authorization", "Bearer " + c.token)
+  }
+
+  rs, err := c.http.Do(req)
+  if err != nil {
+    return nil, err
+  }
+  defer rs.Body.Close()
+
+  data, err := io.ReadAll(rs.Body)
+  if err != nil {
+    return nil, err
+  }
+
+  if rs.StatusCode >= 400 {
+    var envelope struct {
+      Error *apiError `json:"error"`
+    }
+    if json.Unmarshal(data, &envelope) == nil && envelope.Error != nil {
+      return nil, envelope.Error
+    }
+    return nil, fmt.Errorf("unexpected response from server: %s", rs.Status)
+  }
+
+  return data, nil
+}
+
+// The Get() method fetches a single snippet. Note that fetching a
+// burn-after-reading snippet destroys it, just like viewing it in a browser.
+func (c *client) Get(slug string) (snippet, []byte, error) {
+  data, err := c.do(http.MethodGet, "/api/v1/snippets/" + url.PathEscape(slug), nil)
+  if err != nil {
+    return snippet{}, nil, err
+  }
+
+  var rs struct {
+    Snippet snippet `json:"snippet"`
+  }
+  err = json.Unmarshal(data, &rs)
+
+  return rs.Snippet, data, err
+}
+
+// The Create() method creates a new snippet, and returns it as saved by the
+// server.
+func (c *client) Create(input snippetInput) (snippet, []byte, error) {
+  data, err := c.do(http.MethodPost, "/api/v1/snippets", input)
+  if err != nil {
+    return snippet{}, nil, err
+  }
+
+  var rs struct {
+    Snippet snippet `json:"snippet"`
+  }
+  err = json.Unmarshal(data, &rs)
+
+  return rs.Snippet, data, err
+}
+
+// The Delete() method deletes one of the user's snippets.
+func (c *client) Delete(slug string) error {
+  _, err := c.do(http.MethodDelete, "/api/v1/snippets/" + url.PathEscape(slug), nil)
+  return err
+}
+
+// Define a listResult type to hold a page of snippets from List() or
+// Search(). NextCursor is used by List(), and NextPage by Search().
+type listResult struct {
+  Snippets   []snippet `json:"snippets"`
+  NextCursor string    `json:"next_cursor"`
+  NextPage   int       `json:"next_page"`
+}
+
+// The List() method fetches a page of snippets matching the filters in qs.
+func (c *client) List(qs url.Values) (listResult, []byte, error) {
+  return c.list("/api/v1/snippets?" + qs.Encode())
+}
+
+// The Search() method fetches a page of search results.
+func (c *client) Search(query string, page int) (listResult, []byte, error) {
+  qs := url.Values{}
+  qs.Set("q", query)
+  qs.Set("page", fmt.Sprint(page))
+
+  return c.list("/api/v1/search?" + qs.Encode())
+}
+
+func (c *client) list(path string) (listResult, []byte, error) {
+  data, err := c.do(http.MethodGet, path, nil)
+  if err != nil {
+    return listResult{}, nil, err
+  }
+
+  var rs listResult
+  err = json.Unmarshal(data, &rs)
+
+  return rs, data, err
+}
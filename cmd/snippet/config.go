@@ -0,0 +1,87 @@
+package main
+
+import (
+  "encoding/json"
+  "errors"
+  "os"
+  "path/filepath"
+)
+
+// Define a config type to hold the settings saved by the login command. The
+// token is a personal API token, created on the account page of the web
+// application.
+type config struct {
+  Server   string `json:"server"`
+  Token    string `json:"token"`
+  Insecure bool   `json:"insecure,omitempty"`
+}
+
+// configPath() returns the path of the config file, following the XDG Base
+// Directory spec: $XDG_CONFIG_HOME/snippetbox/config.json, where
+// $XDG_CONFIG_HOME defaults to ~/.config. We don't use os.UserConfigDir()
+// because on macOS it points somewhere that terminal users don't expect.
+func configPath() (string, error) {
+  dir := os.Getenv("XDG_CONFIG_HOME")
+  if dir == "" {
+    home, err := os.UserHomeDir()
+    if err != nil {
+      return "", err
+    }
+    dir = filepath.Join(home, ".config")
+  }
+
+  return filepath.Join(dir, "snippetbox", "config.json"), nil
+}
+
+// loadConfig() reads the config file. A missing file isn't an error; it just
+// gives an empty config. The SNIPPETBOX_SERVER and SNIPPETBOX_TOKEN
+// environment variables override the file, which is handy in CI.
+func loadConfig() (config, error) {
+  var cfg config
+
+  path, err := configPath()
+  if err != nil {
+    return cfg, err
+  }
+
+  data, err := os.ReadFile(path)
+  if err != nil && !errors.Is(err, os.ErrNotExist) {
+    return cfg, err
+  }
+  if err == nil {
+    err = json.Unmarshal(data, &cfg)
+    if err != nil {
+      return cfg, err
+    }
+  }
+
+  if server := os.Getenv("SNIPPETBOX_SERVER"); server != "" {
+    cfg.Server = server
+  }
+  if token := os.Getenv("SNIPPETBOX_TOKEN"); token != "" {
+    cfg.Token = token
+  }
+
+  return cfg, nil
+}
+
+// saveConfig() writes the config file, creating its directory if needed. The
+// file holds a secret token, so only the current user can read it.
+func saveConfig(cfg config) (string, error) {
+  path, err := configPath()
+  if err != nil {
+    return "", err
+  }
+
+  err = os.MkdirAll(filepath.Dir(path), 0700)
+  if err != nil {
+    return "", err
+  }
+
+  data, err := json.MarshalIndent(cfg, "", "\t")
+  if err != nil {
+    return "", err
+  }
+
+  return path, os.WriteFile(path, append(data, '\n'), 0600)
+}
@@ -0,0 +1,395 @@
+// Command snippet is a command-line client for snippetbox. It talks to the
+// server's JSON API, using a personal API token from the account page.
+//
+// Usage:
+//
+//   snippet login [-server url] [-insecure]
+//   snippet create [-title t] [-expires e] [-tags a,b] [-language l] [-visibility v] [-burn] [file]
+//   snippet get [-json] <slug-or-url>
+//   snippet list [-json] [-author id] [-tag t] [-limit n] [-cursor c]
+//   snippet search [-json] [-page n] <query>
+//   snippet delete <slug-or-url>
+//
+// Every command except login also accepts -json, which prints the server's
+// JSON response instead of the usual human-friendly output.
+package main
+
+import (
+  "bufio"
+  "encoding/json"
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "net/url"
+  "os"
+  "path/filepath"
+  "strings"
+  "text/tabwriter"
+  "time"
+)
+
+const usage = `usage: snippet <command> [flags] [arguments]
+
+commands:
+  login    save the server URL and API token to use
+  create   create a snippet from a file or standard input
+  get      print a snippet's content
+  list     list snippets
+  search   search snippets
+  delete   delete one of your snippets
+
+Run "snippet <command> -h" for help with a command.
+`
+
+// Define an app type to hold what the commands need: the API client, and
+// where to write output.
+type app struct {
+  client *client
+  stdin  io.Reader
+  stdout io.Writer
+}
+
+func main() {
+  err := run(os.Args[1:], os.Stdin, os.Stdout)
+  switch {
+  case err == nil, errors.Is(err, flag.ErrHelp):
+  case errors.Is(err, errUsage):
+    os.Exit(2)
+  default:
+    fmt.Fprintf(os.Stderr, "snippet: %s\n", err)
+    os.Exit(1)
+  }
+}
+
+// errUsage is returned when the arguments don't make sense. The usage message
+// has already been printed, so there's nothing more to say.
+var errUsage = errors.New("invalid usage")
+
+// run() runs the command given by args. It's separate from main() so that it
+// can be tested.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+  if len(args) == 0 {
+    fmt.Fprint(os.Stderr, usage)
+    return errUsage
+  }
+
+  cfg, err := loadConfig()
+  if err != nil {
+    return fmt.Errorf("reading config: %w", err)
+  }
+
+  a := &app{
+    client: newClient(cfg),
+    stdin:  stdin,
+    stdout: stdout,
+  }
+
+  name, args := args[0], args[1:]
+
+  switch name {
+  case "login":
+    return a.login(cfg, args)
+  case "create":
+    return a.create(args)
+  case "get":
+    return a.get(args)
+  case "list":
+    return a.list(args)
+  case "search":
+    return a.search(args)
+  case "delete":
+    return a.delete(args)
+  case "help", "-h", "-help", "--help":
+    fmt.Fprint(stdout, usage)
+    return nil
+  default:
+    fmt.Fprint(os.Stderr, usage)
+    return fmt.Errorf("unknown command %q", name)
+  }
+}
+
+// newFlagSet() returns a flag set for a command. Any parsing errors are
+// reported by the flag package before we see them.
+func newFlagSet(name, args string) *flag.FlagSet {
+  fs := flag.NewFlagSet(name, flag.ContinueOnError)
+  fs.Usage = func() {
+    fmt.Fprintf(fs.Output(), "usage: snippet %s [flags] %s\n", name, args)
+    fs.PrintDefaults()
+  }
+  return fs
+}
+
+// parse() parses the flags for a command, and checks that it was given the
+// right number of arguments. If the user asked for help it returns
+// flag.ErrHelp, so that the command stops without doing anything.
+func parse(fs *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+  err := fs.Parse(args)
+  if err != nil {
+    if errors.Is(err, flag.ErrHelp) {
+      return err
+    }
+    return errUsage
+  }
+
+  if fs.NArg() < minArgs || fs.NArg() > maxArgs {
+    fs.Usage()
+    return errUsage
+  }
+
+  return nil
+}
+
+// slugArg() accepts either a slug or the URL of a snippet's page, which is
+// what people tend to have on their clipboard.
+func slugArg(arg string) string {
+  arg = strings.TrimSuffix(arg, "/")
+  return arg[strings.LastIndex(arg, "/")+1:]
+}
+
+// The login command saves the server URL and an API token. The token is read
+// from standard input, so that it doesn't end up in the shell history, and
+// checked against the server before it's saved.
+func (a *app) login(cfg config, args []string) error {
+  fs := newFlagSet("login", "")
+  server := fs.String("server", cfg.Server, "URL of the snippetbox server, like https://snippets.example.com")
+  insecure := fs.Bool("insecure", cfg.Insecure, "don't check the server's TLS certificate (for development servers)")
+  if err := parse(fs, args, 0, 0); err != nil {
+    return err
+  }
+
+  if *server == "" {
+    return errors.New("the -server flag is required the first time you log in")
+  }
+
+  fmt.Fprintf(a.stdout, "Create a token at %s/account, then paste it here: ", strings.TrimSuffix(*server, "/"))
+
+  token, err := bufio.NewReader(a.stdin).ReadString('\n')
+  if err != nil && !errors.Is(err, io.EOF) {
+    return err
+  }
+  token = strings.TrimSpace(token)
+  if token == "" {
+    return errors.New("no token given")
+  }
+
+  cfg = config{Server: *server, Token: token, Insecure: *insecure}
+  a.client = newClient(cfg)
+
+  // Any authenticated request will do to check the token.
+  _, _, err = a.client.List(url.Values{})
+  if err != nil {
+    return fmt.Errorf("checking token: %w", err)
+  }
+
+  path, err := saveConfig(cfg)
+  if err != nil {
+    return err
+  }
+
+  fmt.Fprintf(a.stdout, "Logged in. Your token has been saved to %s\n", path)
+  return nil
+}
+
+// The create command creates a snippet from a file, or from standard input if
+// no file is given, and prints its URL.
+func (a *app) create(args []string) error {
+  fs := newFlagSet("create", "[file]")
+  asJSON := fs.Bool("json", false, "print the new snippet as JSON")
+  title := fs.String("title", "", "title of the snippet (defaults to the file name)")
+  expires := fs.String("expires", "", "when the snippet expires: 10m, 1h, 1d, 7d, 365d, never, or a time like 2030-01-02T15:04:05Z")
+  tags := fs.String("tags", "", "comma-separated list of tags")
+  language := fs.String("language", "", "language of the content (detected automatically if not given)")
+  visibility := fs.String("visibility", "", "public, unlisted or private (defaults to public)")
+  burn := fs.Bool("burn", false, "destroy the snippet once somebody else has read it")
+  if err := parse(fs, args, 0, 1); err != nil {
+    return err
+  }
+
+  var (
+    content []byte
+    err     error
+  )
+  if fs.NArg() == 1 {
+    content, err = os.ReadFile(fs.Arg(0))
+    if *title == "" {
+      *title = filepath.Base(fs.Arg(0))
+    }
+  } else {
+    content, err = io.ReadAll(a.stdin)
+  }
+  if err != nil {
+    return err
+  }
+
+  if *title == "" {
+    *title = "Untitled"
+  }
+
+  input := snippetInput{
+    Title:            *title,
+    Content:          string(content),
+    Language:         *language,
+    Visibility:       *visibility,
+    BurnAfterReading: *burn,
+    Expires:          *expires,
+  }
+
+  // Anything which looks like a time is a custom expiry time.
+  if _, err := time.Parse(time.RFC3339, *expires); err == nil {
+    input.Expires = "custom"
+    input.ExpiresAt = *expires
+  }
+
+  for _, tag := range strings.Split(*tags, ",") {
+    if tag = strings.TrimSpace(tag); tag != "" {
+      input.Tags = append(input.Tags, tag)
+    }
+  }
+
+  s, data, err := a.client.Create(input)
+  if err != nil {
+    return err
+  }
+
+  if *asJSON {
+    _, err = a.stdout.Write(data)
+    return err
+  }
+
+  fmt.Fprintln(a.stdout, a.client.snippetURL(s.Slug))
+  return nil
+}
+
+// The get command prints the content of a snippet, so that it can be piped
+// into another command. With -json it prints the snippet's details too.
+func (a *app) get(args []string) error {
+  fs := newFlagSet("get", "<slug-or-url>")
+  asJSON := fs.Bool("json", false, "print the snippet as JSON")
+  if err := parse(fs, args, 1, 1); err != nil {
+    return err
+  }
+
+  s, data, err := a.client.Get(slugArg(fs.Arg(0)))
+  if err != nil {
+    return err
+  }
+
+  if *asJSON {
+    _, err = a.stdout.Write(data)
+    return err
+  }
+
+  _, err = io.WriteString(a.stdout, s.Content)
+  if err == nil && !strings.HasSuffix(s.Content, "\n") {
+    _, err = io.WriteString(a.stdout, "\n")
+  }
+  return err
+}
+
+// The list command prints a page of snippets, newest first.
+func (a *app) list(args []string) error {
+  fs := newFlagSet("list", "")
+  asJSON := fs.Bool("json", false, "print the snippets as JSON")
+  author := fs.Int("author", 0, "only list snippets by the user with this ID")
+  tag := fs.String("tag", "", "only list snippets with this tag")
+  limit := fs.Int("limit", 0, "number of snippets to list (up to 100)")
+  cursor := fs.String("cursor", "", "cursor for the next page, as printed at the end of the previous one")
+  if err := parse(fs, args, 0, 0); err != nil {
+    return err
+  }
+
+  qs := url.Values{}
+  if *author != 0 {
+    qs.Set("author", fmt.Sprint(*author))
+  }
+  if *tag != "" {
+    qs.Set("tag", *tag)
+  }
+  if *limit != 0 {
+    qs.Set("limit", fmt.Sprint(*limit))
+  }
+  if *cursor != "" {
+    qs.Set("cursor", *cursor)
+  }
+
+  rs, data, err := a.client.List(qs)
+  if err != nil {
+    return err
+  }
+
+  if *asJSON {
+    _, err = a.stdout.Write(data)
+    return err
+  }
+
+  a.printSnippets(rs.Snippets)
+  if rs.NextCursor != "" {
+    fmt.Fprintf(a.stdout, "\nMore: snippet list -cursor %s\n", rs.NextCursor)
+  }
+  return nil
+}
+
+// The search command prints a page of search results.
+func (a *app) search(args []string) error {
+  fs := newFlagSet("search", "<query>")
+  asJSON := fs.Bool("json", false, "print the results as JSON")
+  page := fs.Int("page", 1, "page of results to print")
+  if err := parse(fs, args, 1, 1); err != nil {
+    return err
+  }
+
+  rs, data, err := a.client.Search(fs.Arg(0), *page)
+  if err != nil {
+    return err
+  }
+
+  if *asJSON {
+    _, err = a.stdout.Write(data)
+    return err
+  }
+
+  a.printSnippets(rs.Snippets)
+  if rs.NextPage != 0 {
+    fmt.Fprintf(a.stdout, "\nMore: snippet search -page %d %q\n", rs.NextPage, fs.Arg(0))
+  }
+  return nil
+}
+
+// The delete command deletes one of the user's snippets.
+func (a *app) delete(args []string) error {
+  fs := newFlagSet("delete", "<slug-or-url>")
+  asJSON := fs.Bool("json", false, "print the result as JSON")
+  if err := parse(fs, args, 1, 1); err != nil {
+    return err
+  }
+
+  slug := slugArg(fs.Arg(0))
+
+  err := a.client.Delete(slug)
+  if err != nil {
+    return err
+  }
+
+  if *asJSON {
+    return json.NewEncoder(a.stdout).Encode(map[string]string{"deleted": slug})
+  }
+
+  fmt.Fprintf(a.stdout, "Deleted %s\n", slug)
+  return nil
+}
+
+// printSnippets() prints a table of snippets, one per line.
+func (a *app) printSnippets(snippets []snippet) {
+  if len(snippets) == 0 {
+    fmt.Fprintln(a.stdout, "No snippets found.")
+    return
+  }
+
+  tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+  fmt.Fprintln(tw, "SLUG\tTITLE\tAUTHOR\tCREATED")
+  for _, s := range snippets {
+    fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Slug, s.Title, s.Author, s.Created.Local().Format("2006-01-02 15:04"))
+  }
+  tw.Flush()
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+)
+
+const testToken = "alice-token"
+
+// newTestServer() starts a fake snippetbox server, which answers API requests
+// with canned responses, and points the client config at it. It returns the
+// server and a pointer to the body of the last create request it received.
+func newTestServer(t *testing.T) (*httptest.Server, *snippetInput) {
+  var created snippetInput
+
+  mux := http.NewServeMux()
+
+  mux.HandleFunc("GET /api/v1/snippets", func(w http.ResponseWriter, r *http.Request) {
+    if r.Header.Get("Authorization") != "Bearer " + testToken {
+      w.WriteHeader(http.StatusUnauthorized)
+      io.WriteString(w, `{"error": {"status": 401, "message": "invalid or missing authentication token"}}`)
+      return
+    }
+    io.WriteString(w, `{"snippets": [{"slug": "s1lentPd", "title": "an old silent pond", "author": "Alice Jones"}], "next_cursor": "abc"}`)
+  })
+
+  mux.HandleFunc("GET /api/v1/snippets/{slug}", func(w http.ResponseWriter, r *http.Request) {
+    if r.PathValue("slug") != "s1lentPd" {
+      w.WriteHeader(http.StatusNotFound)
+      io.WriteString(w, `{"error": {"status": 404, "message": "the requested snippet could not be found"}}`)
+      return
+    }
+    io.WriteString(w, `{"snippet": {"slug": "s1lentPd", "title": "an old silent pond", "content": "an old silent pond..."}}`)
+  })
+
+  mux.HandleFunc("POST /api/v1/snippets", func(w http.ResponseWriter, r *http.Request) {
+    json.NewDecoder(r.Body).Decode(&created)
+    if created.Content == "" {
+      w.WriteHeader(http.StatusUnprocessableEntity)
+      io.WriteString(w, `{"error": {"status": 422, "message": "the request contains invalid fields", "fields": {"content": "this field cannot be blank"}}}`)
+      return
+    }
+    w.WriteHeader(http.StatusCreated)
+    io.WriteString(w, `{"snippet": {"slug": "n3wSnipt"}}`)
+  })
+
+  mux.HandleFunc("DELETE /api/v1/snippets/{slug}", func(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusNoContent)
+  })
+
+  ts := httptest.NewServer(mux)
+  t.Cleanup(ts.Close)
+
+  // Keep the tests away from the real config file.
+  t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+  t.Setenv("SNIPPETBOX_SERVER", ts.URL)
+  t.Setenv("SNIPPETBOX_TOKEN", testToken)
+
+  return ts, &created
+}
+
+func TestRun(t *testing.T) {
+  ts, _ := newTestServer(t)
+
+  tests := []struct {
+    name    string
+    args    []string
+    stdin   string
+    wantOut string
+    wantErr string
+  }{
+    {
+      name:    "Create from stdin",
+      args:    []string{"create", "-title", "haiku", "-expires", "1h"},
+      stdin:   "an old silent pond...",
+      wantOut: ts.URL + "/snippet/view/n3wSnipt\n",
+    },
+    {
+      name:    "Create with invalid fields",
+      args:    []string{"create"},
+      wantErr: "the request contains invalid fields\n  content: this field cannot be blank",
+    },
+    {
+      name:    "Get",
+      args:    []string{"get", "s1lentPd"},
+      wantOut: "an old silent pond...\n",
+    },
+    {
+      name:    "Get by URL",
+      args:    []string{"get", ts.URL + "/snippet/view/s1lentPd"},
+      wantOut: "an old silent pond...\n",
+    },
+    {
+      name:    "Get as JSON",
+      args:    []string{"get", "-json", "s1lentPd"},
+      wantOut: `{"snippet": {"slug": "s1lentPd", "title": "an old silent pond", "content": "an old silent pond..."}}`,
+    },
+    {
+      name:    "Get missing snippet",
+      args:    []string{"get", "m1ss1ngX"},
+      wantErr: "the requested snippet could not be found",
+    },
+    {
+      name:    "List",
+      args:    []string{"list"},
+      wantOut: "SLUG      TITLE               AUTHOR       CREATED\ns1lentPd  an old silent pond  Alice Jones  ",
+    },
+    {
+      name:    "Delete",
+      args:    []string{"delete", "s1lentPd"},
+      wantOut: "Deleted s1lentPd\n",
+    },
+    {
+      name:    "Wrong number of arguments",
+      args:    []string{"get"},
+      wantErr: "invalid usage",
+    },
+    {
+      name:    "Unknown command",
+      args:    []string{"frobnicate"},
+      wantErr: `unknown command "frobnicate"`,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      var stdout bytes.Buffer
+
+      err := run(tt.args, strings.NewReader(tt.stdin), &stdout)
+
+      if tt.wantErr != "" {
+        if err == nil {
+          t.Fatalf("got no error; want %q", tt.wantErr)
+        }
+        assert.Equal(t, err.Error(), tt.wantErr)
+        return
+      }
+
+      assert.NilError(t, err)
+      assert.StringContains(t, stdout.String(), tt.wantOut)
+    })
+  }
+}
+
+func TestRunCreateFromFile(t *testing.T) {
+  _, created := newTestServer(t)
+
+  path := filepath.Join(t.TempDir(), "pond.txt")
+  err := os.WriteFile(path, []byte("an old silent pond..."), 0600)
+  assert.NilError(t, err)
+
+  var stdout bytes.Buffer
+  err = run([]string{"create", "-tags", "haiku, nature", "-expires", "2999-01-02T15:04:05Z", path}, nil, &stdout)
+  assert.NilError(t, err)
+
+  // The title defaults to the file name, and a time is sent as a custom
+  // expiry.
+  assert.Equal(t, created.Title, "pond.txt")
+  assert.Equal(t, created.Content, "an old silent pond...")
+  assert.Equal(t, strings.Join(created.Tags, ","), "haiku,nature")
+  assert.Equal(t, created.Expires, "custom")
+  assert.Equal(t, created.ExpiresAt, "2999-01-02T15:04:05Z")
+}
+
+func TestRunLogin(t *testing.T) {
+  ts, _ := newTestServer(t)
+
+  // Log in using the flags rather than the environment.
+  t.Setenv("SNIPPETBOX_SERVER", "")
+  t.Setenv("SNIPPETBOX_TOKEN", "")
+
+  t.Run("Invalid token", func(t *testing.T) {
+    var stdout bytes.Buffer
+    err := run([]string{"login", "-server", ts.URL}, strings.NewReader("wrong\n"), &stdout)
+
+    if err == nil {
+      t.Fatal("got no error; want one")
+    }
+    assert.Equal(t, err.Error(), "checking token: invalid or missing authentication token")
+  })
+
+  t.Run("Valid token", func(t *testing.T) {
+    var stdout bytes.Buffer
+    err := run([]string{"login", "-server", ts.URL}, strings.NewReader(testToken + "\n"), &stdout)
+    assert.NilError(t, err)
+
+    cfg, err := loadConfig()
+    assert.NilError(t, err)
+    assert.Equal(t, cfg.Server, ts.URL)
+    assert.Equal(t, cfg.Token, testToken)
+
+    // The config file holds a secret, so only its owner can read it.
+    path, err := configPath()
+    assert.NilError(t, err)
+    info, err := os.Stat(path)
+    assert.NilError(t, err)
+    assert.Equal(t, info.Mode().Perm(), os.FileMode(0600))
+  })
+}
+
+func TestConfigPath(t *testing.T) {
+  t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+
+  path, err := configPath()
+  assert.NilError(t, err)
+  assert.Equal(t, path, "/tmp/xdg/snippetbox/config.json")
+
+  t.Setenv("XDG_CONFIG_HOME", "")
+  t.Setenv("HOME", "/home/alice")
+
+  path, err = configPath()
+  assert.NilError(t, err)
+  assert.Equal(t, path, "/home/alice/.config/snippetbox/config.json")
+}
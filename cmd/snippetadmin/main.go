@@ -0,0 +1,527 @@
+// Command snippetadmin carries out administrative tasks directly against the
+// snippetbox database, like creating users, resetting passwords and cleaning
+// up spam. It reads the same settings as the web application, from the same
+// flags, SNIPPETBOX_* environment variables and config file, so it connects
+// to the same database.
+//
+// Usage:
+//
+//   snippetadmin [-config file] [-dsn dsn | -db url] <command> [flags] [arguments]
+//
+// Run snippetadmin without any arguments to see the list of commands.
+package main
+
+import (
+  "bufio"
+  "context"
+  "database/sql"
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "strconv"
+  "strings"
+  "text/tabwriter"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/config"
+  "github.com/kjloveless/snippetbox/internal/database"
+  "github.com/kjloveless/snippetbox/internal/migrations"
+  "github.com/kjloveless/snippetbox/internal/models"
+  "github.com/kjloveless/snippetbox/internal/validator"
+)
+
+const usage = `usage: snippetadmin [-config file] [-dsn dsn | -db url] <command> [flags] [arguments]
+
+The database is found in the same way as for the web application, so the
+SNIPPETBOX_* environment variables and the config file work here too.
+
+users:
+  user create -name <name> -email <email>   create a user (reads the password from stdin)
+  user list                                 list all users
+  user disable <id>                         stop a user logging in or using the API
+  user enable <id>                          re-enable a disabled user
+  user passwd <id>                          set a user's password (reads it from stdin)
+  user delete -yes <id>                     delete a user and all their snippets
+
+snippets:
+  snippet list [-user id] [-limit n]        list the latest snippets, including hidden ones
+  snippet delete <slug>                     delete a snippet
+  snippet purge -expired                    delete all expired snippets
+  snippet purge -user <id> -yes             delete all of a user's snippets
+
+database:
+  migrate status                            list the schema migrations and whether they've been applied
+  migrate up                                apply all pending migrations
+  migrate down -yes                         roll back the most recent migration
+  migrate baseline                          mark a database made before migrations as being at 0001
+  stats                                     print the size of each table
+`
+
+// Define an application struct to hold the dependencies for the commands, in
+// the same way as cmd/web does.
+type application struct {
+  users    models.AdminUserModelInterface
+  snippets models.SnippetModelInterface
+  admin    models.AdminModelInterface
+  migrator *migrations.Migrator
+  stdin    io.Reader
+  stdout   io.Writer
+}
+
+// errUsage is returned when the arguments don't make sense, and makes main()
+// print the usage message.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+  fs := flag.NewFlagSet("snippetadmin", flag.ExitOnError)
+  fs.Usage = func() { fmt.Fprint(fs.Output(), usage) }
+  cfg := config.MustLoad(fs, os.Args[1:])
+
+  if fs.NArg() == 0 {
+    fs.Usage()
+    os.Exit(2)
+  }
+
+  db, kind, err := database.Open(cfg.Source())
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "snippetadmin: %s\n", err)
+    os.Exit(1)
+  }
+  defer db.Close()
+
+  app, err := newApplication(db, kind, cfg.BcryptCost, os.Stdin, os.Stdout)
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "snippetadmin: %s\n", err)
+    os.Exit(1)
+  }
+
+  err = app.run(fs.Args())
+  switch {
+  case err == nil, errors.Is(err, flag.ErrHelp):
+  case errors.Is(err, errUsage):
+    fmt.Fprint(os.Stderr, usage)
+    os.Exit(2)
+  default:
+    fmt.Fprintf(os.Stderr, "snippetadmin: %s\n", err)
+    os.Exit(1)
+  }
+}
+
+// The newApplication() function returns an application which uses the models
+// for the given kind of database, in the same way as cmd/web. New passwords
+// are hashed with the given bcrypt cost, like the web application's. It's
+// separate from main() so that the tests can use it too.
+func newApplication(db *sql.DB, kind string, bcryptCost int, stdin io.Reader, stdout io.Writer) (*application, error) {
+  migrator, err := migrations.New(db, kind)
+  if err != nil {
+    return nil, err
+  }
+
+  app := &application{
+    migrator: migrator,
+    stdin:    stdin,
+    stdout:   stdout,
+  }
+
+  app.users = &models.UserModel{DB: db, Dialect: kind, BcryptCost: bcryptCost}
+  app.snippets = &models.SnippetModel{DB: db, Dialect: kind}
+  app.admin = &models.AdminModel{DB: db, Dialect: kind}
+
+  return app, nil
+}
+
+// The run() method runs the command given by args.
+func (app *application) run(args []string) error {
+  if len(args) == 0 {
+    return errUsage
+  }
+
+  switch {
+  case args[0] == "stats" && len(args) == 1:
+    return app.stats()
+  case args[0] == "migrate" && len(args) > 1:
+    return app.migrate(args[1], args[2:])
+  case args[0] == "user" && len(args) > 1:
+    return app.user(args[1], args[2:])
+  case args[0] == "snippet" && len(args) > 1:
+    return app.snippet(args[1], args[2:])
+  default:
+    return errUsage
+  }
+}
+
+// parseID() parses the single user ID argument taken by several commands.
+func parseID(fs *flag.FlagSet) (int, error) {
+  if fs.NArg() != 1 {
+    return 0, errUsage
+  }
+
+  id, err := strconv.Atoi(fs.Arg(0))
+  if err != nil || id < 1 {
+    return 0, fmt.Errorf("invalid user ID %q", fs.Arg(0))
+  }
+
+  return id, nil
+}
+
+// readPassword() reads a password from the first line of standard input, and
+// checks it against the same rules as the signup form.
+func (app *application) readPassword() (string, error) {
+  password, err := bufio.NewReader(app.stdin).ReadString('\n')
+  if err != nil && !errors.Is(err, io.EOF) {
+    return "", err
+  }
+  password = strings.TrimRight(password, "\r\n")
+
+  if !validator.MinChars(password, 8) {
+    return "", errors.New("the password must be at least 8 characters long")
+  }
+
+  return password, nil
+}
+
+func (app *application) user(command string, args []string) error {
+  fs := flag.NewFlagSet("user "+command, flag.ContinueOnError)
+
+  switch command {
+  case "create":
+    name := fs.String("name", "", "the user's name")
+    email := fs.String("email", "", "the user's email address")
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+
+    var v validator.Validator
+    v.CheckField(validator.NotBlank(*name), "name", "the -name flag is required")
+    v.CheckField(validator.Matches(*email, validator.EmailRX), "email", "the -email flag must be a valid email address")
+    if !v.Valid() {
+      return errors.New(v.FieldErrors["name"] + v.FieldErrors["email"])
+    }
+
+    password, err := app.readPassword()
+    if err != nil {
+      return err
+    }
+
+    err = app.users.Insert(context.Background(), *name, *email, password)
+    if err != nil {
+      if errors.Is(err, models.ErrDuplicateEmail) {
+        return fmt.Errorf("a user with the email address %s already exists", *email)
+      }
+      return err
+    }
+
+    fmt.Fprintf(app.stdout, "Created user %s <%s>\n", *name, *email)
+    return nil
+
+  case "list":
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+
+    users, err := app.users.All(context.Background())
+    if err != nil {
+      return err
+    }
+
+    tw := tabwriter.NewWriter(app.stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(tw, "ID\tNAME\tEMAIL\tCREATED\tSTATUS")
+    for _, u := range users {
+      status := "active"
+      if u.Disabled {
+        status = "disabled"
+      }
+      fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", u.ID, u.Name, u.Email, u.Created.Format(time.DateTime), status)
+    }
+    return tw.Flush()
+
+  case "disable", "enable":
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+    id, err := parseID(fs)
+    if err != nil {
+      return err
+    }
+
+    err = app.users.SetDisabled(context.Background(), id, command == "disable")
+    if err != nil {
+      return userError(id, err)
+    }
+
+    fmt.Fprintf(app.stdout, "User %d %sd\n", id, command)
+    return nil
+
+  case "passwd":
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+    id, err := parseID(fs)
+    if err != nil {
+      return err
+    }
+
+    password, err := app.readPassword()
+    if err != nil {
+      return err
+    }
+
+    err = app.users.SetPassword(context.Background(), id, password)
+    if err != nil {
+      return userError(id, err)
+    }
+
+    fmt.Fprintf(app.stdout, "Password changed for user %d\n", id)
+    return nil
+
+  case "delete":
+    yes := fs.Bool("yes", false, "confirm that the user and all their snippets should be deleted")
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+    id, err := parseID(fs)
+    if err != nil {
+      return err
+    }
+    if !*yes {
+      return errors.New("deleting a user also deletes all their snippets; add -yes to confirm")
+    }
+
+    err = app.users.Delete(context.Background(), id)
+    if err != nil {
+      return userError(id, err)
+    }
+
+    fmt.Fprintf(app.stdout, "Deleted user %d\n", id)
+    return nil
+
+  default:
+    return errUsage
+  }
+}
+
+// userError() turns ErrNoRecord into a message which says which user wasn't
+// found.
+func userError(id int, err error) error {
+  if errors.Is(err, models.ErrNoRecord) {
+    return fmt.Errorf("there is no user with ID %d", id)
+  }
+  return err
+}
+
+func (app *application) snippet(command string, args []string) error {
+  fs := flag.NewFlagSet("snippet "+command, flag.ContinueOnError)
+
+  switch command {
+  case "list":
+    userID := fs.Int("user", 0, "only list snippets belonging to the user with this ID")
+    limit := fs.Int("limit", 20, "maximum number of snippets to list")
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+
+    snippets, err := app.admin.Snippets(context.Background(), *userID, *limit)
+    if err != nil {
+      return err
+    }
+
+    tw := tabwriter.NewWriter(app.stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(tw, "SLUG\tTITLE\tAUTHOR\tVISIBILITY\tCREATED\tEXPIRES")
+    for _, s := range snippets {
+      expires := "never"
+      if !s.NeverExpires() {
+        expires = s.Expires.Format(time.DateTime)
+      }
+      fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Slug, s.Title, s.Author, s.Visibility,
+        s.Created.Format(time.DateTime), expires)
+    }
+    return tw.Flush()
+
+  case "delete":
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+    if fs.NArg() != 1 {
+      return errUsage
+    }
+    slug := fs.Arg(0)
+
+    err := app.admin.DeleteSnippet(context.Background(), slug)
+    if err != nil {
+      if errors.Is(err, models.ErrNoRecord) {
+        return fmt.Errorf("there is no snippet with slug %s", slug)
+      }
+      return err
+    }
+
+    fmt.Fprintf(app.stdout, "Deleted snippet %s\n", slug)
+    return nil
+
+  case "purge":
+    expired := fs.Bool("expired", false, "delete all expired snippets")
+    userID := fs.Int("user", 0, "delete all snippets belonging to the user with this ID")
+    yes := fs.Bool("yes", false, "confirm that all of the user's snippets should be deleted")
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+
+    var (
+      n   int
+      err error
+    )
+    switch {
+    case *expired && *userID == 0:
+      n, err = app.purgeExpired()
+    case *userID > 0 && !*expired:
+      if !*yes {
+        return fmt.Errorf("this deletes every snippet belonging to user %d; add -yes to confirm", *userID)
+      }
+      n, err = app.admin.PurgeUserSnippets(context.Background(), *userID)
+    default:
+      return errors.New("give exactly one of -expired or -user")
+    }
+    if err != nil {
+      return err
+    }
+
+    fmt.Fprintf(app.stdout, "Deleted %d snippets\n", n)
+    return nil
+
+  default:
+    return errUsage
+  }
+}
+
+// purgeBatchSize is the number of expired snippets deleted in each query,
+// which keeps each query (and the locks it holds) short.
+const purgeBatchSize = 500
+
+// purgeExpired() deletes all expired snippets, in batches, in the same way as
+// the reaper in cmd/web.
+func (app *application) purgeExpired() (int, error) {
+  before := time.Now()
+  total := 0
+
+  for {
+    n, err := app.snippets.PurgeExpired(context.Background(), before, purgeBatchSize)
+    total += n
+    if err != nil || n < purgeBatchSize {
+      return total, err
+    }
+  }
+}
+
+func (app *application) migrate(command string, args []string) error {
+  fs := flag.NewFlagSet("migrate "+command, flag.ContinueOnError)
+  ctx := context.Background()
+
+  switch command {
+  case "status":
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+
+    statuses, err := app.migrator.Status(ctx)
+    if err != nil {
+      return err
+    }
+
+    tw := tabwriter.NewWriter(app.stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(tw, "MIGRATION\tAPPLIED")
+    for _, s := range statuses {
+      applied := "pending"
+      if !s.Pending() {
+        applied = s.Applied.Format(time.DateTime)
+      }
+      fmt.Fprintf(tw, "%s\t%s\n", s.Migration, applied)
+    }
+    return tw.Flush()
+
+  case "up":
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+
+    applied, err := app.migrator.Up(ctx)
+    for _, m := range applied {
+      fmt.Fprintf(app.stdout, "Applied %s\n", m)
+    }
+    if err != nil {
+      return err
+    }
+
+    if len(applied) == 0 {
+      fmt.Fprintln(app.stdout, "The database is already up to date")
+    }
+    return nil
+
+  case "down":
+    yes := fs.Bool("yes", false, "confirm that the most recent migration should be rolled back")
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+    if !*yes {
+      return errors.New("rolling back a migration can delete data; add -yes to confirm")
+    }
+
+    m, err := app.migrator.Down(ctx)
+    if err != nil {
+      return err
+    }
+
+    fmt.Fprintf(app.stdout, "Rolled back %s\n", m)
+    return nil
+
+  // A database which was set up by hand, before snippetbox had migrations,
+  // already has the tables from the first migration. Recording it as applied
+  // lets "migrate up" take the database on from there.
+  case "baseline":
+    if err := fs.Parse(args); err != nil {
+      return err
+    }
+
+    m, err := app.migrator.Baseline(ctx)
+    if err != nil {
+      return err
+    }
+
+    fmt.Fprintf(app.stdout, "Marked %s as applied; run \"migrate up\" to apply the rest\n", m)
+    return nil
+
+  default:
+    return errUsage
+  }
+}
+
+func (app *application) stats() error {
+  stats, err := app.admin.Stats(context.Background())
+  if err != nil {
+    return err
+  }
+
+  tw := tabwriter.NewWriter(app.stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+  fmt.Fprintln(tw, "TABLE\tROWS\tDATA\tINDEXES\t")
+  for _, t := range stats {
+    fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t\n", t.Name, t.Rows, humanBytes(t.DataBytes), humanBytes(t.IndexBytes))
+  }
+  return tw.Flush()
+}
+
+// humanBytes() formats a number of bytes in the most suitable unit, like
+// "16.0 KiB".
+func humanBytes(n int64) string {
+  const unit = 1024
+  if n < unit {
+    return fmt.Sprintf("%d B", n)
+  }
+
+  div, exp := int64(unit), 0
+  for m := n / unit; m >= unit; m /= unit {
+    div *= unit
+    exp++
+  }
+
+  return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+  "bytes"
+  "context"
+  "database/sql"
+  "io"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+  "github.com/kjloveless/snippetbox/internal/database"
+  "github.com/kjloveless/snippetbox/internal/migrations"
+  "github.com/kjloveless/snippetbox/internal/models"
+
+  "golang.org/x/crypto/bcrypt"
+)
+
+// openTestDB() opens a new, empty SQLite database in a temporary directory,
+// which is removed when the test finishes. SQLite doesn't need a server, so
+// these tests run against a real database even with the "-short" flag.
+func openTestDB(t *testing.T) (*sql.DB, string) {
+  db, kind, err := database.Open("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() { db.Close() })
+
+  return db, kind
+}
+
+// newTestApplication() returns an application using a fresh database, with
+// the schema up to date and two snippets belonging to Alice: one which
+// hasn't expired, and one which has. stdin is what the commands read
+// passwords from, and the returned buffer holds what they print.
+func newTestApplication(t *testing.T, stdin string) (*application, *bytes.Buffer) {
+  db, kind := openTestDB(t)
+
+  var stdout bytes.Buffer
+  app, err := newApplication(db, kind, bcrypt.MinCost, strings.NewReader(stdin), &stdout)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  _, err = app.migrator.Up(context.Background())
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  _, err = db.Exec(`INSERT INTO users (name, email, hashed_password, created)
+  VALUES ('Alice Jones', 'alice@example.com',
+  '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
+  '2022-01-01 09:18:24')`)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  for _, s := range []models.Snippet{
+    {Title: "an old silent pond", Expires: time.Now().Add(time.Hour)},
+    {Title: "an expired snippet", Expires: time.Now().Add(-time.Hour)},
+  } {
+    s.Content = "..."
+    s.Visibility = models.VisibilityPublic
+    s.UserID = 1
+
+    _, err = app.snippets.Insert(context.Background(), s)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  return app, &stdout
+}
+
+func TestRun(t *testing.T) {
+  tests := []struct {
+    name    string
+    args    []string
+    stdin   string
+    wantOut string
+    wantErr string
+  }{
+    {
+      name:    "Create user",
+      args:    []string{"user", "create", "-name", "Bob", "-email", "bob@example.com"},
+      stdin:   "n3w-passw0rd\n",
+      wantOut: "Created user Bob <bob@example.com>\n",
+    },
+    {
+      name:    "Create user with a duplicate email",
+      args:    []string{"user", "create", "-name", "Alice", "-email", "alice@example.com"},
+      stdin:   "n3w-passw0rd\n",
+      wantErr: "a user with the email address alice@example.com already exists",
+    },
+    {
+      name:    "Create user with a short password",
+      args:    []string{"user", "create", "-name", "Bob", "-email", "bob@example.com"},
+      stdin:   "pa$$\n",
+      wantErr: "the password must be at least 8 characters long",
+    },
+    {
+      name:    "Create user without an email",
+      args:    []string{"user", "create", "-name", "Bob"},
+      wantErr: "the -email flag must be a valid email address",
+    },
+    {
+      name:    "List users",
+      args:    []string{"user", "list"},
+      wantOut: "1   Alice Jones  alice@example.com  2022-01-01 09:18:24  active\n",
+    },
+    {
+      name:    "Disable user",
+      args:    []string{"user", "disable", "1"},
+      wantOut: "User 1 disabled\n",
+    },
+    {
+      name:    "Enable user",
+      args:    []string{"user", "enable", "1"},
+      wantOut: "User 1 enabled\n",
+    },
+    {
+      name:    "Disable missing user",
+      args:    []string{"user", "disable", "9"},
+      wantErr: "there is no user with ID 9",
+    },
+    {
+      name:    "Invalid user ID",
+      args:    []string{"user", "disable", "alice"},
+      wantErr: `invalid user ID "alice"`,
+    },
+    {
+      name:    "Change password",
+      args:    []string{"user", "passwd", "1"},
+      stdin:   "n3w-passw0rd\n",
+      wantOut: "Password changed for user 1\n",
+    },
+    {
+      name:    "Delete user without -yes",
+      args:    []string{"user", "delete", "1"},
+      wantErr: "deleting a user also deletes all their snippets; add -yes to confirm",
+    },
+    {
+      name:    "Delete user",
+      args:    []string{"user", "delete", "-yes", "1"},
+      wantOut: "Deleted user 1\n",
+    },
+    {
+      name:    "List snippets",
+      args:    []string{"snippet", "list"},
+      wantOut: "an expired snippet",
+    },
+    {
+      name:    "List another user's snippets",
+      args:    []string{"snippet", "list", "-user", "2"},
+      wantOut: "SLUG  TITLE  AUTHOR  VISIBILITY  CREATED  EXPIRES\n",
+    },
+    {
+      name:    "Delete missing snippet",
+      args:    []string{"snippet", "delete", "m1ss1ng0"},
+      wantErr: "there is no snippet with slug m1ss1ng0",
+    },
+    {
+      name:    "Purge expired snippets",
+      args:    []string{"snippet", "purge", "-expired"},
+      wantOut: "Deleted 1 snippets\n",
+    },
+    {
+      name:    "Purge user's snippets without -yes",
+      args:    []string{"snippet", "purge", "-user", "1"},
+      wantErr: "this deletes every snippet belonging to user 1; add -yes to confirm",
+    },
+    {
+      name:    "Purge user's snippets",
+      args:    []string{"snippet", "purge", "-user", "1", "-yes"},
+      wantOut: "Deleted 2 snippets\n",
+    },
+    {
+      name:    "Purge without a flag",
+      args:    []string{"snippet", "purge"},
+      wantErr: "give exactly one of -expired or -user",
+    },
+    {
+      name:    "Stats",
+      args:    []string{"stats"},
+      wantOut: "snippets",
+    },
+    {
+      name:    "Migration status",
+      args:    []string{"migrate", "status"},
+      wantOut: "0006_create_tokens",
+    },
+    {
+      name:    "Migrate up when up to date",
+      args:    []string{"migrate", "up"},
+      wantOut: "The database is already up to date\n",
+    },
+    {
+      name:    "Migrate down without -yes",
+      args:    []string{"migrate", "down"},
+      wantErr: "rolling back a migration can delete data; add -yes to confirm",
+    },
+    {
+      name:    "Migrate down",
+      args:    []string{"migrate", "down", "-yes"},
+      wantOut: "Rolled back 0006_create_tokens\n",
+    },
+    {
+      name:    "Baseline a versioned database",
+      args:    []string{"migrate", "baseline"},
+      wantErr: migrations.ErrAlreadyVersioned.Error(),
+    },
+    {
+      name:    "Unknown command",
+      args:    []string{"frobnicate"},
+      wantErr: "invalid usage",
+    },
+    {
+      name:    "Missing subcommand",
+      args:    []string{"user"},
+      wantErr: "invalid usage",
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      app, stdout := newTestApplication(t, tt.stdin)
+
+      err := app.run(tt.args)
+
+      if tt.wantErr != "" {
+        if err == nil {
+          t.Fatalf("got no error; want %q", tt.wantErr)
+        }
+        assert.Equal(t, err.Error(), tt.wantErr)
+        return
+      }
+
+      assert.NilError(t, err)
+      assert.StringContains(t, stdout.String(), tt.wantOut)
+    })
+  }
+}
+
+func TestRunBcryptCost(t *testing.T) {
+  db, kind := openTestDB(t)
+
+  app, err := newApplication(db, kind, bcrypt.MinCost, strings.NewReader("n3w-passw0rd\n"), io.Discard)
+  assert.NilError(t, err)
+
+  _, err = app.migrator.Up(context.Background())
+  assert.NilError(t, err)
+
+  // cost() returns the bcrypt cost of the password hash stored for user 1.
+  cost := func() int {
+    var hash []byte
+    err := db.QueryRow("SELECT hashed_password FROM users WHERE id = 1").Scan(&hash)
+    assert.NilError(t, err)
+
+    cost, err := bcrypt.Cost(hash)
+    assert.NilError(t, err)
+    return cost
+  }
+
+  // New passwords are hashed with the configured cost, rather than the
+  // default.
+  err = app.run([]string{"user", "create", "-name", "Bob", "-email", "bob@example.com"})
+  assert.NilError(t, err)
+  assert.Equal(t, cost(), bcrypt.MinCost)
+
+  app.stdin = strings.NewReader("an0ther-passw0rd\n")
+  err = app.run([]string{"user", "passwd", "1"})
+  assert.NilError(t, err)
+  assert.Equal(t, cost(), bcrypt.MinCost)
+}
+
+func TestRunMigrateBaseline(t *testing.T) {
+  db, kind := openTestDB(t)
+
+  var stdout bytes.Buffer
+  app, err := newApplication(db, kind, bcrypt.MinCost, nil, &stdout)
+  assert.NilError(t, err)
+
+  // Make the tables by hand, as they were before there were migrations.
+  _, err = db.Exec(app.migrator.Migrations[0].Up)
+  assert.NilError(t, err)
+
+  err = app.run([]string{"migrate", "baseline"})
+  assert.NilError(t, err)
+  assert.Equal(t, stdout.String(), "Marked 0001_create_baseline as applied; run \"migrate up\" to apply the rest\n")
+
+  stdout.Reset()
+  err = app.run([]string{"migrate", "up"})
+  assert.NilError(t, err)
+  assert.StringContains(t, stdout.String(), "Applied 0002_add_users_disabled\n")
+  assert.StringContains(t, stdout.String(), "Applied 0006_create_tokens\n")
+}
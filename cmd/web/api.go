@@ -0,0 +1,620 @@
+package main
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "runtime/debug"
+  "slices"
+  "strings"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/models"
+  "github.com/kjloveless/snippetbox/internal/validator"
+)
+
+// maxAPIPageSize is the largest number of snippets which can be requested in
+// one page of the JSON API's list endpoint.
+const maxAPIPageSize = 100
+
+// maxJSONBytes is the largest request body which the JSON API will read.
+const maxJSONBytes = 1_048_576
+
+// Define an envelope type for the top-level objects in our JSON responses.
+// Wrapping everything in an object with a named key (like {"snippet": ...})
+// makes responses self-documenting, and leaves room to add extra fields later
+// without breaking clients.
+type envelope map[string]any
+
+// Define an apiSnippet type to hold the JSON representation of a snippet.
+// We don't expose the numeric ID or the author's user ID; clients identify
+// snippets by their slug, just like the web pages do. Expires is null if the
+// snippet never expires.
+type apiSnippet struct {
+  Slug             string     `json:"slug"`
+  Title            string     `json:"title"`
+  Content          string     `json:"content"`
+  Language         string     `json:"language"`
+  Visibility       string     `json:"visibility"`
+  BurnAfterReading bool       `json:"burn_after_reading"`
+  Tags             []string   `json:"tags"`
+  Author           string     `json:"author"`
+  Created          time.Time  `json:"created"`
+  Expires          *time.Time `json:"expires"`
+}
+
+// newAPISnippet() converts a models.Snippet to its JSON representation.
+func newAPISnippet(s models.Snippet) apiSnippet {
+  snippet := apiSnippet{
+    Slug:             s.Slug,
+    Title:            s.Title,
+    Content:          s.Content,
+    Language:         s.Language,
+    Visibility:       s.Visibility,
+    BurnAfterReading: s.BurnAfterReading,
+    Tags:             s.Tags,
+    Author:           s.Author,
+    Created:          s.Created,
+  }
+
+  // Always send an array for the tags, rather than null.
+  if snippet.Tags == nil {
+    snippet.Tags = []string{}
+  }
+
+  if !s.NeverExpires() {
+    snippet.Expires = &s.Expires
+  }
+
+  return snippet
+}
+
+// Define an apiSnippetInput type to hold the JSON body of create and update
+// requests. The fields match those in snippetCreateForm, except that tags are
+// given as an array and a custom expiry time (when Expires is "custom") is
+// given in RFC 3339 format, like "2030-01-02T15:04:05Z".
+type apiSnippetInput struct {
+  Title            string   `json:"title"`
+  Content          string   `json:"content"`
+  Language         string   `json:"language"`
+  Visibility       string   `json:"visibility"`
+  BurnAfterReading bool     `json:"burn_after_reading"`
+  Tags             []string `json:"tags"`
+  Expires          string   `json:"expires"`
+  ExpiresAt        string   `json:"expires_at"`
+}
+
+// The validate() method checks the input using the same rules as the HTML
+// snippet form, and returns the form (so that the caller can check Valid()
+// and get at the field errors) along with the time that the snippet should
+// expire.
+func (in apiSnippetInput) validate() (snippetCreateForm, time.Time) {
+  form := snippetCreateForm{
+    Title:            in.Title,
+    Content:          in.Content,
+    Language:         in.Language,
+    Visibility:       in.Visibility,
+    BurnAfterReading: in.BurnAfterReading,
+    Tags:             strings.Join(in.Tags, ","),
+    Expires:          in.Expires,
+  }
+
+  // The HTML form only has minute precision, so we give validateSnippetForm()
+  // the custom expiry time rounded up to the next minute, but keep the exact
+  // time for the snippet itself. Rounding up could move a time which has just
+  // passed into the future, so we check the exact time first. A time which
+  // can't be parsed is passed through unchanged, so that it fails validation.
+  var custom time.Time
+  if in.Expires == expiresCustom {
+    t, err := time.Parse(time.RFC3339, in.ExpiresAt)
+    if err == nil {
+      custom = t
+      form.CheckField(t.After(time.Now()), "expires_at", "this field must be in the future")
+      form.ExpiresAt = t.UTC().Add(time.Minute - 1).Truncate(time.Minute).
+        Format(datetimeLayout)
+    } else {
+      form.ExpiresAt = in.ExpiresAt
+    }
+  }
+
+  validateSnippetForm(&form)
+
+  if in.Expires == expiresCustom {
+    return form, custom
+  }
+  return form, expiryTime(form, time.Now())
+}
+
+// The writeJSON() helper encodes data as JSON and sends it with the given
+// status code and any extra headers. We encode to a buffer first (in the same
+// way as render() does for templates) so that an encoding error doesn't leave
+// us with a half-written response.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+  js, err := json.MarshalIndent(data, "", "\t")
+  if err != nil {
+    return err
+  }
+  js = append(js, '\n')
+
+  for key, value := range headers {
+    w.Header()[key] = value
+  }
+
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  w.Write(js)
+
+  return nil
+}
+
+// The readJSON() helper decodes a JSON request body into dst. It rejects
+// bodies which are too large, contain unknown fields, or contain more than a
+// single JSON value, and turns the errors from the encoding/json package
+// into messages which make sense to the client.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+  r.Body = http.MaxBytesReader(w, r.Body, maxJSONBytes)
+
+  dec := json.NewDecoder(r.Body)
+  dec.DisallowUnknownFields()
+
+  err := dec.Decode(dst)
+  if err != nil {
+    var (
+      syntaxError           *json.SyntaxError
+      unmarshalTypeError    *json.UnmarshalTypeError
+      invalidUnmarshalError *json.InvalidUnmarshalError
+      maxBytesError         *http.MaxBytesError
+    )
+
+    switch {
+    case errors.As(err, &syntaxError):
+      return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+    case errors.Is(err, io.ErrUnexpectedEOF):
+      return errors.New("body contains badly-formed JSON")
+    case errors.As(err, &unmarshalTypeError):
+      if unmarshalTypeError.Field != "" {
+        return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+      }
+      return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+    case errors.Is(err, io.EOF):
+      return errors.New("body must not be empty")
+    case strings.HasPrefix(err.Error(), "json: unknown field "):
+      field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+      return fmt.Errorf("body contains unknown field %s", field)
+    case errors.As(err, &maxBytesError):
+      return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+    case errors.As(err, &invalidUnmarshalError):
+      // As with decodePostForm(), this means we've passed a bad destination,
+      // which is a bug in our code rather than a problem with the request.
+      panic(err)
+    default:
+      return err
+    }
+  }
+
+  // Make sure that the body only contained a single JSON value.
+  err = dec.Decode(&struct{}{})
+  if !errors.Is(err, io.EOF) {
+    return errors.New("body must only contain a single JSON value")
+  }
+
+  return nil
+}
+
+// The errorJSON() helper sends an error response in the standard JSON
+// envelope, which looks like {"error": {"status": 404, "message": "..."}}.
+// Every error from the JSON API uses this format, so that clients only need
+// one way of handling them.
+func (app *application) errorJSON(w http.ResponseWriter, r *http.Request, status int, message string) {
+  app.writeErrorJSON(w, r, status, envelope{"status": status, "message": message})
+}
+
+// The validationErrorJSON() helper sends a 422 Unprocessable Entity response
+// for a validator.Validator which failed its checks. The field errors are
+// included as a "fields" object, mapping each field name to its error
+// message, and any non-field errors as an "errors" array.
+func (app *application) validationErrorJSON(w http.ResponseWriter, r *http.Request, v validator.Validator) {
+  status := http.StatusUnprocessableEntity
+
+  body := envelope{
+    "status":  status,
+    "message": "the request contains invalid fields",
+    "fields":  v.FieldErrors,
+  }
+  if len(v.NonFieldErrors) > 0 {
+    body["errors"] = v.NonFieldErrors
+  }
+
+  app.writeErrorJSON(w, r, status, body)
+}
+
+// The serverErrorJSON() helper is the JSON API's equivalent of serverError().
+// It logs the error and stack trace, and sends a generic 500 Internal Server
+// Error response without giving away any details (or a 503 Service
+// Unavailable response if a database query timed out).
+func (app *application) serverErrorJSON(w http.ResponseWriter, r *http.Request, err error) {
+  var (
+    method = r.Method
+    uri    = r.URL.RequestURI()
+    trace  = string(debug.Stack())
+  )
+
+  app.logger.ErrorContext(r.Context(), err.Error(), "method", method, "uri", uri, "trace", trace)
+
+  if errors.Is(err, models.ErrTimeout) {
+    app.errorJSON(w, r, http.StatusServiceUnavailable,
+      "the server is too busy to process your request; please try again later")
+    return
+  }
+
+  app.errorJSON(w, r, http.StatusInternalServerError,
+    "the server encountered a problem and could not process your request")
+}
+
+// The invalidTokenJSON() helper sends a 401 Unauthorized response for a
+// request with a missing, malformed or unknown API token.
+func (app *application) invalidTokenJSON(w http.ResponseWriter, r *http.Request) {
+  w.Header().Set("WWW-Authenticate", "Bearer")
+  app.errorJSON(w, r, http.StatusUnauthorized, "invalid or missing authentication token")
+}
+
+func (app *application) writeErrorJSON(w http.ResponseWriter, r *http.Request, status int, body envelope) {
+  err := app.writeJSON(w, status, envelope{"error": body}, nil)
+  if err != nil {
+    app.logger.ErrorContext(r.Context(), err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+    w.WriteHeader(http.StatusInternalServerError)
+  }
+}
+
+// The apiSnippetFromPath() helper is the JSON API's equivalent of
+// snippetFromPath(). Old numeric IDs aren't redirected here, because the API
+// has only ever used slugs.
+func (app *application) apiSnippetFromPath(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+  slug := r.PathValue("slug")
+  if !models.ValidSlug(slug) {
+    app.errorJSON(w, r, http.StatusNotFound, "the requested snippet could not be found")
+    return models.Snippet{}, false
+  }
+
+  snippet, err := app.snippets.Get(r.Context(), slug, app.authenticatedUserID(r))
+  if err != nil {
+    if errors.Is(err, models.ErrNoRecord) {
+      app.errorJSON(w, r, http.StatusNotFound, "the requested snippet could not be found")
+    } else {
+      app.serverErrorJSON(w, r, err)
+    }
+    return models.Snippet{}, false
+  }
+
+  return snippet, true
+}
+
+// The apiOwnedSnippet() helper is the JSON API's equivalent of
+// ownedSnippet().
+func (app *application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+  snippet, ok := app.apiSnippetFromPath(w, r)
+  if !ok {
+    return models.Snippet{}, false
+  }
+
+  if snippet.UserID != app.authenticatedUserID(r) {
+    app.errorJSON(w, r, http.StatusForbidden, "you can only change your own snippets")
+    return models.Snippet{}, false
+  }
+
+  return snippet, true
+}
+
+// The apiSnippetList() handler returns a page of snippets. It takes the same
+// filters as the archive page in its query string, plus a "limit" for the
+// page size.
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+  qs := r.URL.Query()
+
+  var form snippetFilterForm
+
+  err := app.formDecoder.Decode(&form, qs)
+  if err != nil {
+    app.errorJSON(w, r, http.StatusBadRequest, "the query string could not be decoded")
+    return
+  }
+
+  filter := form.filter(app.authenticatedUserID(r))
+
+  filter.Limit, err = readInt(qs, "limit", models.ListPageSize)
+  if err != nil {
+    form.AddFieldError("limit", "this field must be a positive integer")
+  }
+  form.CheckField(
+    filter.Limit <= maxAPIPageSize,
+    "limit",
+    fmt.Sprintf("this field must not be more than %d", maxAPIPageSize))
+
+  if !form.Valid() {
+    app.validationErrorJSON(w, r, form.Validator)
+    return
+  }
+
+  page, err := app.snippets.List(r.Context(), filter)
+  if err != nil {
+    if errors.Is(err, models.ErrInvalidCursor) {
+      app.errorJSON(w, r, http.StatusBadRequest, "the cursor is invalid")
+    } else {
+      app.serverErrorJSON(w, r, err)
+    }
+    return
+  }
+
+  snippets := make([]apiSnippet, len(page.Snippets))
+  for i, s := range page.Snippets {
+    snippets[i] = newAPISnippet(s)
+  }
+
+  data := envelope{
+    "snippets":    snippets,
+    "next_cursor": page.NextCursor,
+    "prev_cursor": page.PrevCursor,
+  }
+
+  err = app.writeJSON(w, http.StatusOK, data, nil)
+  if err != nil {
+    app.serverErrorJSON(w, r, err)
+  }
+}
+
+// The apiSearch() handler returns a page of full-text search results for the
+// "q" query string parameter. next_page is 0 when there are no more results.
+func (app *application) apiSearch(w http.ResponseWriter, r *http.Request) {
+  qs := r.URL.Query()
+  query := strings.TrimSpace(qs.Get("q"))
+
+  var v validator.Validator
+
+  v.CheckField(validator.NotBlank(query), "q", "this field cannot be blank")
+
+  page, err := readInt(qs, "page", 1)
+  if err != nil {
+    v.AddFieldError("page", "this field must be a positive integer")
+  }
+
+  if !v.Valid() {
+    app.validationErrorJSON(w, r, v)
+    return
+  }
+
+  results, err := app.snippets.Search(r.Context(), query, page, app.authenticatedUserID(r))
+  if err != nil {
+    app.serverErrorJSON(w, r, err)
+    return
+  }
+
+  snippets := make([]apiSnippet, len(results))
+  for i, s := range results {
+    snippets[i] = newAPISnippet(s)
+  }
+
+  // Just like the search page, a full page of results means that there may
+  // be more.
+  nextPage := 0
+  if len(results) == models.SearchPageSize {
+    nextPage = page + 1
+  }
+
+  err = app.writeJSON(w, http.StatusOK, envelope{"snippets": snippets, "next_page": nextPage}, nil)
+  if err != nil {
+    app.serverErrorJSON(w, r, err)
+  }
+}
+
+// The apiSnippetView() handler returns a single snippet. Like snippetView(),
+// it uses View() so that burn-after-reading snippets can be read (once).
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+  slug := r.PathValue("slug")
+  if !models.ValidSlug(slug) {
+    app.errorJSON(w, r, http.StatusNotFound, "the requested snippet could not be found")
+    return
+  }
+
+  snippet, err := app.snippets.View(r.Context(), slug, app.authenticatedUserID(r))
+  if err != nil {
+    switch {
+    case errors.Is(err, models.ErrBurned):
+      app.errorJSON(w, r, http.StatusGone, "the requested snippet has been burned after reading")
+    case errors.Is(err, models.ErrNoRecord):
+      app.errorJSON(w, r, http.StatusNotFound, "the requested snippet could not be found")
+    default:
+      app.serverErrorJSON(w, r, err)
+    }
+    return
+  }
+
+  if snippet.BurnAfterReading {
+    w.Header().Set("Cache-Control", "no-store")
+  }
+
+  err = app.writeJSON(w, http.StatusOK, envelope{"snippet": newAPISnippet(snippet)}, nil)
+  if err != nil {
+    app.serverErrorJSON(w, r, err)
+  }
+}
+
+// The apiSnippetCreate() handler creates a new snippet owned by the
+// authenticated user. Visibility defaults to public and the expiry to one
+// year, the same as on the create page. It responds with 201 Created, the new
+// snippet, and its URL in the Location header.
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+  input := apiSnippetInput{
+    Visibility: models.VisibilityPublic,
+    Expires:    "365d",
+  }
+
+  err := app.readJSON(w, r, &input)
+  if err != nil {
+    app.errorJSON(w, r, http.StatusBadRequest, err.Error())
+    return
+  }
+
+  form, expires := input.validate()
+  if !form.Valid() {
+    app.validationErrorJSON(w, r, form.Validator)
+    return
+  }
+
+  snippet := models.Snippet{
+    Title:            form.Title,
+    Content:          form.Content,
+    Language:         form.Language,
+    Visibility:       form.Visibility,
+    BurnAfterReading: form.BurnAfterReading,
+    UserID:           app.authenticatedUserID(r),
+    Tags:             parseTags(form.Tags),
+    Expires:          expires,
+  }
+
+  snippet.Slug, err = app.snippets.Insert(r.Context(), snippet)
+  if err != nil {
+    app.serverErrorJSON(w, r, err)
+    return
+  }
+
+  app.metrics.snippetsCreated.WithLabelValues("api").Inc()
+
+  // Fetch the snippet back, so that the response includes the values filled
+  // in by the database (like the created time and author's name).
+  snippet, err = app.snippets.Get(r.Context(), snippet.Slug, snippet.UserID)
+  if err != nil {
+    app.serverErrorJSON(w, r, err)
+    return
+  }
+
+  headers := make(http.Header)
+  headers.Set("Location", fmt.Sprintf("/api/v1/snippets/%s", snippet.Slug))
+
+  err = app.writeJSON(w, http.StatusCreated, envelope{"snippet": newAPISnippet(snippet)}, headers)
+  if err != nil {
+    app.serverErrorJSON(w, r, err)
+  }
+}
+
+// The apiSnippetUpdate() handler updates one of the authenticated user's
+// snippets. It's a partial update: any fields left out of the request body
+// keep their current values.
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+  snippet, ok := app.apiOwnedSnippet(w, r)
+  if !ok {
+    return
+  }
+
+  // Start from the current values, and let the request body overwrite
+  // whichever fields it contains. The tags are copied because the JSON
+  // decoder reuses a slice's backing array.
+  input := apiSnippetInput{
+    Title:            snippet.Title,
+    Content:          snippet.Content,
+    Language:         snippet.Language,
+    Visibility:       snippet.Visibility,
+    BurnAfterReading: snippet.BurnAfterReading,
+    Tags:             slices.Clone(snippet.Tags),
+    Expires:          expiresNever,
+  }
+  if !snippet.NeverExpires() {
+    input.Expires = expiresCustom
+    input.ExpiresAt = snippet.Expires.UTC().Format(time.RFC3339)
+  }
+
+  err := app.readJSON(w, r, &input)
+  if err != nil {
+    app.errorJSON(w, r, http.StatusBadRequest, err.Error())
+    return
+  }
+
+  form, expires := input.validate()
+  if !form.Valid() {
+    app.validationErrorJSON(w, r, form.Validator)
+    return
+  }
+
+  snippet.Title = form.Title
+  snippet.Content = form.Content
+  snippet.Language = form.Language
+  snippet.Visibility = form.Visibility
+  snippet.BurnAfterReading = form.BurnAfterReading
+  snippet.Tags = parseTags(form.Tags)
+  snippet.Expires = expires
+
+  err = app.snippets.Update(r.Context(), snippet, app.authenticatedUserID(r))
+  if err != nil {
+    if errors.Is(err, models.ErrNoRecord) {
+      app.errorJSON(w, r, http.StatusNotFound, "the requested snippet could not be found")
+    } else {
+      app.serverErrorJSON(w, r, err)
+    }
+    return
+  }
+
+  err = app.writeJSON(w, http.StatusOK, envelope{"snippet": newAPISnippet(snippet)}, nil)
+  if err != nil {
+    app.serverErrorJSON(w, r, err)
+  }
+}
+
+// The apiSnippetDelete() handler deletes one of the authenticated user's
+// snippets, and responds with 204 No Content.
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+  snippet, ok := app.apiOwnedSnippet(w, r)
+  if !ok {
+    return
+  }
+
+  err := app.snippets.Delete(r.Context(), snippet.ID)
+  if err != nil {
+    if errors.Is(err, models.ErrNoRecord) {
+      app.errorJSON(w, r, http.StatusNotFound, "the requested snippet could not be found")
+    } else {
+      app.serverErrorJSON(w, r, err)
+    }
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+// apiMethods are the methods which apiNotFound() checks the API routes for.
+var apiMethods = []string{
+  http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+  http.MethodPatch, http.MethodDelete,
+}
+
+// The apiNotFound() method returns a handler which sends a JSON 404 Not Found
+// response for any request under /api/ which doesn't match one of the API
+// routes in mux. The /api/ catch-all matches every method, so if the path
+// does match a route and only the method is wrong, we have to do what the
+// servemux would have done: send 405 Method Not Allowed, with an Allow header
+// listing the methods the route does accept.
+func (app *application) apiNotFound(mux *http.ServeMux) http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    var allowed []string
+
+    for _, method := range apiMethods {
+      probe := r.Clone(r.Context())
+      probe.Method = method
+
+      _, pattern := mux.Handler(probe)
+      if pattern != "" && pattern != "/api/" {
+        allowed = append(allowed, method)
+      }
+    }
+
+    if len(allowed) > 0 {
+      slices.Sort(allowed)
+      w.Header().Set("Allow", strings.Join(allowed, ", "))
+      app.errorJSON(w, r, http.StatusMethodNotAllowed,
+        fmt.Sprintf("the %s method is not supported for this resource", r.Method))
+      return
+    }
+
+    app.errorJSON(w, r, http.StatusNotFound, "the requested resource could not be found")
+  }
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+  "encoding/json"
+  "net/http"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+)
+
+// aliceToken and aliceReadToken are the API tokens which the mocked
+// TokenModel accepts for the user from our mocked UserModel. The first has the
+// write scope, and the second is read-only.
+const (
+  aliceToken     = "alice-token"
+  aliceReadToken = "alice-read-token"
+)
+
+func TestAPISnippetList(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name         string
+    urlPath      string
+    token        string
+    wantCode     int
+    wantBody     []string
+    dontWantBody string
+  }{
+    {
+      name:         "Anonymous",
+      urlPath:      "/api/v1/snippets",
+      wantCode:     http.StatusOK,
+      wantBody:     []string{`"slug": "s1lentPd"`, `"slug": "w1ntryFr"`, `"next_cursor": ""`},
+      dontWantBody: `"slug": "l1ghtnFl"`,
+    },
+    {
+      name:     "Authenticated",
+      urlPath:  "/api/v1/snippets",
+      token:    aliceToken,
+      wantCode: http.StatusOK,
+      wantBody: []string{`"slug": "l1ghtnFl"`},
+    },
+    {
+      name:     "Read-only token",
+      urlPath:  "/api/v1/snippets",
+      token:    aliceReadToken,
+      wantCode: http.StatusOK,
+      wantBody: []string{`"slug": "l1ghtnFl"`},
+    },
+    {
+      name:         "By author",
+      urlPath:      "/api/v1/snippets?author=2",
+      wantCode:     http.StatusOK,
+      wantBody:     []string{`"slug": "w1ntryFr"`},
+      dontWantBody: `"slug": "s1lentPd"`,
+    },
+    {
+      name:     "Limit",
+      urlPath:  "/api/v1/snippets?limit=1",
+      wantCode: http.StatusOK,
+      wantBody: []string{`"next_cursor": "`},
+    },
+    {
+      name:     "No results",
+      urlPath:  "/api/v1/snippets?before=2000-01-01",
+      wantCode: http.StatusOK,
+      wantBody: []string{`"snippets": []`},
+    },
+    {
+      name:     "Invalid filters",
+      urlPath:  "/api/v1/snippets?after=yesterday&limit=1000",
+      wantCode: http.StatusUnprocessableEntity,
+      wantBody: []string{
+        `"after": "this field must be a date"`,
+        `"limit": "this field must not be more than 100"`,
+      },
+    },
+    {
+      name:     "Invalid cursor",
+      urlPath:  "/api/v1/snippets?cursor=foo",
+      wantCode: http.StatusBadRequest,
+      wantBody: []string{`"message": "the cursor is invalid"`},
+    },
+    {
+      name:     "Invalid token",
+      urlPath:  "/api/v1/snippets",
+      token:    "wrong",
+      wantCode: http.StatusUnauthorized,
+      wantBody: []string{`"status": 401`},
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, header, body := ts.apiRequest(t, http.MethodGet, tt.urlPath, tt.token, "")
+
+      assert.Equal(t, code, tt.wantCode)
+      assert.Equal(t, header.Get("Content-Type"), "application/json")
+
+      for _, want := range tt.wantBody {
+        assert.StringContains(t, body, want)
+      }
+      if tt.dontWantBody != "" {
+        assert.Equal(t, strings.Contains(body, tt.dontWantBody), false)
+      }
+    })
+  }
+}
+
+func TestAPISnippetView(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name     string
+    urlPath  string
+    token    string
+    wantCode int
+    wantBody string
+  }{
+    {
+      name:     "Valid slug",
+      urlPath:  "/api/v1/snippets/s1lentPd",
+      wantCode: http.StatusOK,
+      wantBody: `"content": "an old silent pond..."`,
+    },
+    {
+      name:     "Never expires",
+      urlPath:  "/api/v1/snippets/l1ghtnFl",
+      token:    aliceToken,
+      wantCode: http.StatusOK,
+      wantBody: `"expires": null`,
+    },
+    {
+      name:     "Private snippet",
+      urlPath:  "/api/v1/snippets/l1ghtnFl",
+      wantCode: http.StatusNotFound,
+      wantBody: `"status": 404`,
+    },
+    {
+      name:     "Burned snippet",
+      urlPath:  "/api/v1/snippets/bUrnEd0n",
+      wantCode: http.StatusGone,
+      wantBody: `"status": 410`,
+    },
+    {
+      name:     "Non-existent slug",
+      urlPath:  "/api/v1/snippets/n0tF0und",
+      wantCode: http.StatusNotFound,
+    },
+    {
+      name:     "Query timeout",
+      urlPath:  "/api/v1/snippets/sl0wQury",
+      wantCode: http.StatusServiceUnavailable,
+      wantBody: `"status": 503`,
+    },
+    {
+      name:     "Invalid slug",
+      urlPath:  "/api/v1/snippets/1",
+      wantCode: http.StatusNotFound,
+    },
+    {
+      name:     "Unknown path",
+      urlPath:  "/api/v1/foo",
+      wantCode: http.StatusNotFound,
+      wantBody: `"message": "the requested resource could not be found"`,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, header, body := ts.apiRequest(t, http.MethodGet, tt.urlPath, tt.token, "")
+
+      assert.Equal(t, code, tt.wantCode)
+      assert.Equal(t, header.Get("Content-Type"), "application/json")
+
+      if tt.wantBody != "" {
+        assert.StringContains(t, body, tt.wantBody)
+      }
+    })
+  }
+}
+
+func TestAPISearch(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name         string
+    urlPath      string
+    wantCode     int
+    wantBody     string
+    dontWantBody string
+  }{
+    {
+      name:         "Matching snippets",
+      urlPath:      "/api/v1/search?q=pond",
+      wantCode:     http.StatusOK,
+      wantBody:     `"slug": "s1lentPd"`,
+      dontWantBody: `"slug": "w1ntryFr"`,
+    },
+    {
+      name:     "Blank query",
+      urlPath:  "/api/v1/search?q=+",
+      wantCode: http.StatusUnprocessableEntity,
+      wantBody: `"q": "this field cannot be blank"`,
+    },
+    {
+      name:     "Invalid page",
+      urlPath:  "/api/v1/search?q=pond&page=0",
+      wantCode: http.StatusUnprocessableEntity,
+      wantBody: `"page": "this field must be a positive integer"`,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, _, body := ts.apiRequest(t, http.MethodGet, tt.urlPath, "", "")
+
+      assert.Equal(t, code, tt.wantCode)
+      assert.StringContains(t, body, tt.wantBody)
+      if tt.dontWantBody != "" {
+        assert.Equal(t, strings.Contains(body, tt.dontWantBody), false)
+      }
+    })
+  }
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  justPassed := time.Now().Add(-time.Second).UTC().Format(time.RFC3339)
+
+  tests := []struct {
+    name         string
+    token        string
+    body         string
+    wantCode     int
+    wantFields   map[string]string
+    wantLocation string
+  }{
+    {
+      name:         "Valid submission",
+      token:        aliceToken,
+      body:         `{"title": "an old silent pond", "content": "an old silent pond...", "tags": ["haiku"]}`,
+      wantCode:     http.StatusCreated,
+      wantLocation: "/api/v1/snippets/s1lentPd",
+    },
+    {
+      name:         "Custom expiry",
+      token:        aliceToken,
+      body:         `{"title": "a", "content": "b", "expires": "custom", "expires_at": "2999-01-02T15:04:05Z"}`,
+      wantCode:     http.StatusCreated,
+      wantLocation: "/api/v1/snippets/s1lentPd",
+    },
+    {
+      name:     "Invalid fields",
+      token:    aliceToken,
+      body:     `{"title": "", "content": "b", "visibility": "secret", "expires": "custom", "expires_at": "tomorrow"}`,
+      wantCode: http.StatusUnprocessableEntity,
+      wantFields: map[string]string{
+        "title":      "this field cannot be blank",
+        "visibility": "this field must equal public, unlisted, or private",
+        "expires_at": "this field must be a date and time",
+      },
+    },
+    {
+      // Rounded up to the minute, this would usually be in the future.
+      name:     "Custom expiry which has just passed",
+      token:    aliceToken,
+      body:     `{"title": "a", "content": "b", "expires": "custom", "expires_at": "` + justPassed + `"}`,
+      wantCode: http.StatusUnprocessableEntity,
+      wantFields: map[string]string{
+        "expires_at": "this field must be in the future",
+      },
+    },
+    {
+      name:     "Unknown field",
+      token:    aliceToken,
+      body:     `{"title": "a", "content": "b", "author": "Bob"}`,
+      wantCode: http.StatusBadRequest,
+    },
+    {
+      name:     "Badly-formed JSON",
+      token:    aliceToken,
+      body:     `{"title": "a",`,
+      wantCode: http.StatusBadRequest,
+    },
+    {
+      name:     "No token",
+      body:     `{"title": "a", "content": "b"}`,
+      wantCode: http.StatusUnauthorized,
+    },
+    {
+      name:     "Read-only token",
+      token:    aliceReadToken,
+      body:     `{"title": "a", "content": "b"}`,
+      wantCode: http.StatusForbidden,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, header, body := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", tt.token, tt.body)
+
+      assert.Equal(t, code, tt.wantCode)
+      assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+      if tt.wantFields != nil {
+        var rs struct {
+          Error struct {
+            Status int               `json:"status"`
+            Fields map[string]string `json:"fields"`
+          } `json:"error"`
+        }
+        err := json.Unmarshal([]byte(body), &rs)
+        assert.NilError(t, err)
+
+        assert.Equal(t, rs.Error.Status, tt.wantCode)
+        assert.Equal(t, len(rs.Error.Fields), len(tt.wantFields))
+        for field, message := range tt.wantFields {
+          assert.Equal(t, rs.Error.Fields[field], message)
+        }
+      }
+    })
+  }
+}
+
+func TestAPISnippetUpdate(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name     string
+    urlPath  string
+    token    string
+    body     string
+    wantCode int
+    wantBody []string
+  }{
+    {
+      name:     "Partial update",
+      urlPath:  "/api/v1/snippets/s1lentPd",
+      token:    aliceToken,
+      body:     `{"title": "a new title"}`,
+      wantCode: http.StatusOK,
+      wantBody: []string{`"title": "a new title"`, `"content": "an old silent pond..."`},
+    },
+    {
+      name:     "Invalid fields",
+      urlPath:  "/api/v1/snippets/s1lentPd",
+      token:    aliceToken,
+      body:     `{"tags": ["Not A Tag!"]}`,
+      wantCode: http.StatusUnprocessableEntity,
+      wantBody: []string{`"tags": "tags must be`},
+    },
+    {
+      name:     "Somebody else's snippet",
+      urlPath:  "/api/v1/snippets/w1ntryFr",
+      token:    aliceToken,
+      body:     `{"title": "mine now"}`,
+      wantCode: http.StatusForbidden,
+    },
+    {
+      name:     "Non-existent snippet",
+      urlPath:  "/api/v1/snippets/n0tF0und",
+      token:    aliceToken,
+      body:     `{"title": "a new title"}`,
+      wantCode: http.StatusNotFound,
+    },
+    {
+      name:     "No token",
+      urlPath:  "/api/v1/snippets/s1lentPd",
+      body:     `{"title": "a new title"}`,
+      wantCode: http.StatusUnauthorized,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, _, body := ts.apiRequest(t, http.MethodPatch, tt.urlPath, tt.token, tt.body)
+
+      assert.Equal(t, code, tt.wantCode)
+
+      for _, want := range tt.wantBody {
+        assert.StringContains(t, body, want)
+      }
+    })
+  }
+}
+
+func TestAPISnippetDelete(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name     string
+    urlPath  string
+    token    string
+    wantCode int
+  }{
+    {
+      name:     "Own snippet",
+      urlPath:  "/api/v1/snippets/s1lentPd",
+      token:    aliceToken,
+      wantCode: http.StatusNoContent,
+    },
+    {
+      name:     "Somebody else's snippet",
+      urlPath:  "/api/v1/snippets/w1ntryFr",
+      token:    aliceToken,
+      wantCode: http.StatusForbidden,
+    },
+    {
+      name:     "No token",
+      urlPath:  "/api/v1/snippets/s1lentPd",
+      wantCode: http.StatusUnauthorized,
+    },
+    {
+      name:     "Read-only token",
+      urlPath:  "/api/v1/snippets/s1lentPd",
+      token:    aliceReadToken,
+      wantCode: http.StatusForbidden,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, _, _ := ts.apiRequest(t, http.MethodDelete, tt.urlPath, tt.token, "")
+
+      assert.Equal(t, code, tt.wantCode)
+    })
+  }
+}
+
+func TestAPINotFound(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name      string
+    method    string
+    urlPath   string
+    wantCode  int
+    wantAllow string
+    wantBody  string
+  }{
+    {
+      name:     "Unknown path",
+      method:   http.MethodPost,
+      urlPath:  "/api/v1/foo",
+      wantCode: http.StatusNotFound,
+      wantBody: `"message": "the requested resource could not be found"`,
+    },
+    {
+      name:      "Wrong method for a collection",
+      method:    http.MethodDelete,
+      urlPath:   "/api/v1/search",
+      wantCode:  http.StatusMethodNotAllowed,
+      wantAllow: "GET, HEAD",
+      wantBody:  `"message": "the DELETE method is not supported for this resource"`,
+    },
+    {
+      name:      "Wrong method for a snippet",
+      method:    http.MethodPut,
+      urlPath:   "/api/v1/snippets/s1lentPd",
+      wantCode:  http.StatusMethodNotAllowed,
+      wantAllow: "DELETE, GET, HEAD, PATCH",
+      wantBody:  `"status": 405`,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, header, body := ts.apiRequest(t, tt.method, tt.urlPath, aliceToken, "")
+
+      assert.Equal(t, code, tt.wantCode)
+      assert.Equal(t, header.Get("Allow"), tt.wantAllow)
+      assert.StringContains(t, body, tt.wantBody)
+    })
+  }
+}
@@ -3,3 +3,11 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+// Also store the ID of the authenticated user in the request context, so that
+// handlers can check who owns a resource without going back to the session.
+const authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+
+// Requests authenticated with an API token also carry the token's scope, so
+// that read-only tokens can be stopped from changing anything.
+const tokenScopeContextKey = contextKey("tokenScope")
@@ -1,11 +1,17 @@
 package main
 
 import (
+  "crypto/sha256"
   "errors"
   "fmt"
   "net/http"
+  "net/url"
   "strconv"
+  "strings"
+  "time"
 
+  "github.com/kjloveless/snippetbox/internal/diff"
+  "github.com/kjloveless/snippetbox/internal/syntax"
   "github.com/kjloveless/snippetbox/internal/models"
   "github.com/kjloveless/snippetbox/internal/validator"
 )
@@ -27,7 +33,13 @@ import (
 type snippetCreateForm struct {
   Title               string  `form:"title"`
   Content             string  `form:"content"`
-  Expires             int     `form:"expires"`
+  Language            string  `form:"language"`
+  Visibility          string  `form:"visibility"`
+  BurnAfterReading    bool    `form:"burn"`
+  Tags                string  `form:"tags"`
+  Expires             string  `form:"expires"`
+  ExpiresAt           string  `form:"expires_at"`
+  TZOffset            int     `form:"tz_offset"`
   validator.Validator         `form:"-"`
 }
 
@@ -46,8 +58,235 @@ type userLoginForm struct {
   validator.Validator `form:"-"`
 }
 
+// Create a tokenCreateForm struct to hold the form data for creating a new
+// API token on the account page.
+type tokenCreateForm struct {
+  Name                string  `form:"name"`
+  Scope               string  `form:"scope"`
+  Expires             string  `form:"expires"`
+  validator.Validator         `form:"-"`
+}
+
+// Create a snippetFilterForm struct to hold the filters for the snippet
+// archive page. Unlike our other forms, this is decoded from the query string
+// of a GET request.
+type snippetFilterForm struct {
+  Author              int     `form:"author"`
+  After               string  `form:"after"`
+  Before              string  `form:"before"`
+  Tag                 string  `form:"tag"`
+  Sort                string  `form:"sort"`
+  Cursor              string  `form:"cursor"`
+  validator.Validator         `form:"-"`
+}
+
+// The url() method returns the URL of the archive page with the same filters
+// as the form, but at the given cursor position.
+func (f snippetFilterForm) url(cursor string) string {
+  v := url.Values{}
+  if f.Author != 0 {
+    v.Set("author", strconv.Itoa(f.Author))
+  }
+  if f.After != "" {
+    v.Set("after", f.After)
+  }
+  if f.Before != "" {
+    v.Set("before", f.Before)
+  }
+  if f.Tag != "" {
+    v.Set("tag", f.Tag)
+  }
+  if f.Sort != "" {
+    v.Set("sort", f.Sort)
+  }
+  v.Set("cursor", cursor)
+
+  return "/snippets?" + v.Encode()
+}
+
+// The filter() method validates the filters in the form, and converts them to
+// a models.SnippetFilter for the given viewer. Any problems are recorded in
+// the form's field errors, so call Valid() before using the result.
+func (f *snippetFilterForm) filter(viewerID int) models.SnippetFilter {
+  var err error
+
+  filter := models.SnippetFilter{
+    ViewerID: viewerID,
+    AuthorID: f.Author,
+    Tag:      f.Tag,
+    Sort:     f.Sort,
+    Cursor:   f.Cursor,
+  }
+
+  if f.After != "" {
+    filter.CreatedAfter, err = time.Parse(dateLayout, f.After)
+    f.CheckField(err == nil, "after", "this field must be a date")
+  }
+  if f.Before != "" {
+    filter.CreatedBefore, err = time.Parse(dateLayout, f.Before)
+    f.CheckField(err == nil, "before", "this field must be a date")
+  }
+  f.CheckField(
+    validator.PermittedValue(f.Sort, "", models.SortNewest, models.SortOldest),
+    "sort",
+    "this field must equal newest or oldest")
+
+  return filter
+}
+
+// maxTags is the maximum number of tags that can be added to a snippet.
+const maxTags = 5
+
+// Define an expiryOption type to describe one of the choices for when a
+// snippet expires. Value is what the form sends, and Duration is how long from
+// now the snippet expires. Duration is zero for the "never" and "custom"
+// options; a custom expiry is given as a date and time in the ExpiresAt field.
+type expiryOption struct {
+  Value    string
+  Label    string
+  Duration time.Duration
+}
+
+const (
+  expiresNever  = "never"
+  expiresCustom = "custom"
+)
+
+var expiryOptions = []expiryOption{
+  {"10m", "Ten Minutes", 10 * time.Minute},
+  {"1h", "One Hour", time.Hour},
+  {"1d", "One Day", 24 * time.Hour},
+  {"7d", "One Week", 7 * 24 * time.Hour},
+  {"365d", "One Year", 365 * 24 * time.Hour},
+  {expiresNever, "Never", 0},
+  {expiresCustom, "At a Set Time", 0},
+}
+
+// tokenExpiryOptions holds the choices for when an API token expires. Tokens
+// are meant to be long-lived, so the options are longer than for snippets.
+var tokenExpiryOptions = []expiryOption{
+  {"30d", "30 Days", 30 * 24 * time.Hour},
+  {"90d", "90 Days", 90 * 24 * time.Hour},
+  {"365d", "One Year", 365 * 24 * time.Hour},
+  {expiresNever, "Never", 0},
+}
+
+// datetimeLayout is the format used by <input type='datetime-local'>.
+const datetimeLayout = "2006-01-02T15:04"
+
+// maxTZOffset is the furthest from UTC, in minutes, that any time zone is.
+const maxTZOffset = 14 * 60
+
+// The customExpiry() helper parses the date and time given for a custom
+// expiry. A datetime-local input gives the time on the clock where the browser
+// is, without a time zone, so main.js sends the browser's offset from UTC
+// along with it. TZOffset is in minutes and has the same sign as JavaScript's
+// getTimezoneOffset(), so UTC+1 is -60. Browsers without JavaScript don't send
+// an offset, and the time is read as UTC, which is what the form tells them.
+func customExpiry(form snippetCreateForm) (time.Time, error) {
+  zone := time.FixedZone("", -form.TZOffset*60)
+  t, err := time.ParseInLocation(datetimeLayout, form.ExpiresAt, zone)
+  return t.UTC(), err
+}
+
+// The expiryTime() helper works out when a snippet submitted with the form
+// should expire, relative to now. The zero time means it never expires. It
+// assumes the form has already been validated.
+func expiryTime(form snippetCreateForm, now time.Time) time.Time {
+  switch form.Expires {
+  case expiresNever:
+    return time.Time{}
+  case expiresCustom:
+    t, _ := customExpiry(form)
+    return t
+  }
+
+  for _, option := range expiryOptions {
+    if option.Value == form.Expires {
+      return now.Add(option.Duration)
+    }
+  }
+
+  return time.Time{}
+}
+
+// The validateSnippetForm() helper runs the validation checks which apply to
+// a snippetCreateForm. We use it both when creating a new snippet and when
+// editing an existing one.
+func validateSnippetForm(form *snippetCreateForm) {
+  // Because the Validator struct is embedded by the snippetCreateForm struct,
+  // we can call CheckField() directly on it to execute our validation checks.
+  // CheckField() will add the provided key and error message to the 
+  // FieldErrors map if the check does not evaluate to true. For example, in
+  // the first line here we "check that the form.Title field is not blank". In
+  // the second, we "check that the form.Title field has a maximum character
+  // length of 100" and so on.
+  form.CheckField(
+    validator.NotBlank(form.Title), 
+    "title", 
+    "this field cannot be blank")
+  form.CheckField(
+    validator.MaxChars(form.Title, 100), 
+    "title", 
+    "this field cannot be more than 100 characters long")
+  form.CheckField(
+    validator.NotBlank(form.Content),
+    "content",
+    "this field cannot be blank")
+  form.CheckField(
+    validator.PermittedValue(form.Language, syntax.Names()...),
+    "language",
+    "this field must be one of the listed languages")
+  form.CheckField(
+    validator.PermittedValue(form.Visibility, models.Visibilities...),
+    "visibility",
+    "this field must equal public, unlisted, or private")
+
+  var values []string
+  for _, option := range expiryOptions {
+    values = append(values, option.Value)
+  }
+  form.CheckField(
+    validator.PermittedValue(form.Expires, values...),
+    "expires",
+    "this field must be one of the listed options")
+
+  if form.Expires == expiresCustom {
+    t, err := customExpiry(*form)
+    form.CheckField(
+      form.TZOffset >= -maxTZOffset && form.TZOffset <= maxTZOffset,
+      "expires_at",
+      "this field has an invalid time zone")
+    form.CheckField(
+      err == nil,
+      "expires_at",
+      "this field must be a date and time")
+    form.CheckField(
+      err != nil || t.After(time.Now()),
+      "expires_at",
+      "this field must be in the future")
+  }
+
+  tags := parseTags(form.Tags)
+  form.CheckField(
+    validator.MaxItems(tags, maxTags),
+    "tags",
+    fmt.Sprintf("there cannot be more than %d tags", maxTags))
+  form.CheckField(
+    validator.AllMatch(tags, validator.TagRX),
+    "tags",
+    "tags must be up to 32 lower-case letters, numbers, or + . _ - characters")
+}
+
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-  snippets, err := app.snippets.Latest()
+  snippets, err := app.snippets.Latest(r.Context(), app.authenticatedUserID(r))
+  if err != nil {
+    app.serverError(w, r, err)
+    return
+  }
+
+  // Fetch the most popular tags for the tag cloud.
+  tags, err := app.snippets.Tags(r.Context(), 30)
   if err != nil {
     app.serverError(w, r, err)
     return
@@ -58,22 +297,166 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
   // snippets slice to it.
   data := app.newTemplateData(r)
   data.Snippets = snippets
+  data.Tags = tags
 
   // Use the new render helper.
   app.render(w, r, http.StatusOK, "home.tmpl", data)
 }
 
-func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-  id, err := strconv.Atoi(r.PathValue("id"))
-  if err != nil || id < 1 {
-    http.NotFound(w, r)
+// dateLayout is the format used for dates in the snippet archive filters (and
+// by <input type='date'> fields).
+const dateLayout = "2006-01-02"
+
+func (app *application) snippetList(w http.ResponseWriter, r *http.Request) {
+  var form snippetFilterForm
+
+  err := app.formDecoder.Decode(&form, r.URL.Query())
+  if err != nil {
+    app.clientError(w, http.StatusBadRequest)
+    return
+  }
+
+  app.listSnippets(w, r, form)
+}
+
+func (app *application) tagView(w http.ResponseWriter, r *http.Request) {
+  var form snippetFilterForm
+
+  err := app.formDecoder.Decode(&form, r.URL.Query())
+  if err != nil {
+    app.clientError(w, http.StatusBadRequest)
+    return
+  }
+
+  // The tag comes from the URL path rather than the query string.
+  form.Tag = r.PathValue("tag")
+
+  app.listSnippets(w, r, form)
+}
+
+// The listSnippets() helper validates the filters in a snippetFilterForm,
+// and then renders the matching page of snippets on the archive page.
+func (app *application) listSnippets(w http.ResponseWriter, r *http.Request, form snippetFilterForm) {
+  filter := form.filter(app.authenticatedUserID(r))
+
+  data := app.newTemplateData(r)
+  data.Form = form
+
+  if !form.Valid() {
+    app.render(w, r, http.StatusUnprocessableEntity, "archive.tmpl", data)
+    return
+  }
+
+  page, err := app.snippets.List(r.Context(), filter)
+  if err != nil {
+    if errors.Is(err, models.ErrInvalidCursor) {
+      app.clientError(w, http.StatusBadRequest)
+    } else {
+      app.serverError(w, r, err)
+    }
     return
   }
 
+  data.Snippets = page.Snippets
+  if page.NextCursor != "" {
+    data.NextURL = form.url(page.NextCursor)
+  }
+  if page.PrevCursor != "" {
+    data.PrevURL = form.url(page.PrevCursor)
+  }
+
+  app.render(w, r, http.StatusOK, "archive.tmpl", data)
+}
+
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+  qs := r.URL.Query()
+  query := strings.TrimSpace(qs.Get("q"))
+
+  page, err := readInt(qs, "page", 1)
+  if err != nil {
+    app.clientError(w, http.StatusBadRequest)
+    return
+  }
+
+  data := app.newTemplateData(r)
+  data.Query = query
+  data.Page = page
+
+  // Only hit the database if there is actually something to search for.
+  if query != "" {
+    snippets, err := app.snippets.Search(r.Context(), query, page, app.authenticatedUserID(r))
+    if err != nil {
+      app.serverError(w, r, err)
+      return
+    }
+    data.Snippets = snippets
+
+    // If we got a full page of results there may be more, so link to the
+    // next page.
+    if len(snippets) == models.SearchPageSize {
+      data.NextPage = page + 1
+    }
+    data.PrevPage = page - 1
+  }
+
+  app.render(w, r, http.StatusOK, "search.tmpl", data)
+}
+
+// The slugFromPath() helper returns the {slug} wildcard from the request URL.
+// If it isn't a valid slug it sends a 404 Not Found response, except that old
+// URLs which use a numeric ID instead of a slug are permanently redirected to
+// the new URL. In both cases the returned bool will be false and the calling
+// handler should return straight away.
+func (app *application) slugFromPath(w http.ResponseWriter, r *http.Request) (string, bool) {
+  slug := r.PathValue("slug")
+  if !models.ValidSlug(slug) {
+    id, err := strconv.Atoi(slug)
+    if err == nil && id > 0 && r.Method == http.MethodGet {
+      app.redirectLegacySnippet(w, r, id)
+    } else {
+      http.NotFound(w, r)
+    }
+    return "", false
+  }
+
+  return slug, true
+}
+
+// The snippetFromPath() helper fetches the snippet identified by the {slug}
+// wildcard in the request URL. If the slug isn't valid or there is no matching
+// snippet it sends a 404 Not Found response, and if something else goes wrong
+// it sends a 500 Internal Server Error response. In both cases the returned
+// bool will be false and the calling handler should return straight away.
+func (app *application) snippetFromPath(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+  slug, ok := app.slugFromPath(w, r)
+  if !ok {
+    return models.Snippet{}, false
+  }
+
   // Use the SnippetModel's Get() method to retrieve the data for a specific
-  // record based on its ID. If no matching record is found, return a 404 Not
-  // Found response.
-  snippet, err := app.snippets.Get(id)
+  // record based on its slug. If no matching record is found (or the snippet
+  // is private and belongs to somebody else), return a 404 Not Found
+  // response.
+  snippet, err := app.snippets.Get(r.Context(), slug, app.authenticatedUserID(r))
+  if err != nil {
+    if errors.Is(err, models.ErrNoRecord) {
+      http.NotFound(w, r)
+    } else {
+      app.serverError(w, r, err)
+    }
+    return models.Snippet{}, false
+  }
+
+  return snippet, true
+}
+
+// The redirectLegacySnippet() helper sends a 301 Moved Permanently response
+// from an old URL containing a snippet's numeric ID to the same page with the
+// snippet's slug in its place, keeping any query string. We work out the new
+// path from the pattern which matched the request, so this works for every
+// route with a {slug} wildcard.
+func (app *application) redirectLegacySnippet(w http.ResponseWriter, r *http.Request, id int) {
+  slug, err := app.snippets.LegacySlug(r.Context(), id, app.authenticatedUserID(r))
   if err != nil {
     if errors.Is(err, models.ErrNoRecord) {
       http.NotFound(w, r)
@@ -83,22 +466,211 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
     return
   }
 
+  // Patterns look like "GET /snippet/view/{slug}/history", so we drop the
+  // method before filling in the wildcard.
+  _, path, _ := strings.Cut(r.Pattern, " ")
+  target := strings.Replace(path, "{slug}", slug, 1)
+  if r.URL.RawQuery != "" {
+    target += "?" + r.URL.RawQuery
+  }
+
+  http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
+
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+  slug, ok := app.slugFromPath(w, r)
+  if !ok {
+    return
+  }
+
+  // Use View() rather than Get() here, because this is the only page which
+  // shows the content of a burn-after-reading snippet to somebody other than
+  // its author (and destroys the snippet in the process). If the snippet has
+  // already been destroyed, we say so with a 410 Gone response.
+  snippet, err := app.snippets.View(r.Context(), slug, app.authenticatedUserID(r))
+  if err != nil {
+    switch {
+    case errors.Is(err, models.ErrBurned):
+      data := app.newTemplateData(r)
+      app.render(w, r, http.StatusGone, "burned.tmpl", data)
+    case errors.Is(err, models.ErrNoRecord):
+      http.NotFound(w, r)
+    default:
+      app.serverError(w, r, err)
+    }
+    return
+  }
+
+  // Make sure that browsers and proxies don't keep a copy of a snippet which
+  // is supposed to be read only once.
+  if snippet.BurnAfterReading {
+    w.Header().Set("Cache-Control", "no-store")
+  }
+
+  // Render the content as syntax highlighted HTML. If the author didn't
+  // choose a language, Render() detects one and tells us what it picked.
+  content, language, err := syntax.Render(snippet.Content, snippet.Language)
+  if err != nil {
+    app.serverError(w, r, err)
+    return
+  }
+
   data := app.newTemplateData(r)
   data.Snippet = snippet
+  data.HighlightedContent = content
+  data.Language = syntax.Label(language)
 
   // Use the new render helper.
   app.render(w, r, http.StatusOK, "view.tmpl", data)
 }
 
+// rawMaxAge is the longest that browsers and proxies may cache the raw
+// content of a public snippet before checking that it hasn't changed.
+const rawMaxAge = 5 * time.Minute
+
+// The snippetRaw() handler serves the content of a snippet exactly as it was
+// written, as plain text. Like snippetView(), it burns burn-after-reading
+// snippets.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+  slug, ok := app.slugFromPath(w, r)
+  if !ok {
+    return
+  }
+
+  snippet, err := app.snippets.View(r.Context(), slug, app.authenticatedUserID(r))
+  if err != nil {
+    switch {
+    case errors.Is(err, models.ErrBurned):
+      http.Error(w, "this snippet has been burned after reading", http.StatusGone)
+    case errors.Is(err, models.ErrNoRecord):
+      http.NotFound(w, r)
+    default:
+      app.serverError(w, r, err)
+    }
+    return
+  }
+
+  // Burn-after-reading snippets must never be cached. Public snippets can be
+  // cached by anybody for a little while (but never beyond their expiry), and
+  // other snippets only by the viewer's own browser, which has to check back
+  // each time. The ETag lets those checks get a 304 Not Modified response if
+  // the content is unchanged.
+  switch {
+  case snippet.BurnAfterReading:
+    w.Header().Set("Cache-Control", "no-store")
+  case snippet.Visibility == models.VisibilityPublic:
+    maxAge := rawMaxAge
+    if !snippet.NeverExpires() {
+      maxAge = min(maxAge, time.Until(snippet.Expires))
+    }
+    w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+  default:
+    w.Header().Set("Cache-Control", "private, no-cache")
+  }
+
+  if !snippet.BurnAfterReading {
+    w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(snippet.Content))))
+  }
+  w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+  // http.ServeContent() takes care of conditional and range requests for us.
+  http.ServeContent(w, r, "", time.Time{}, strings.NewReader(snippet.Content))
+}
+
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+  snippet, ok := app.snippetFromPath(w, r)
+  if !ok {
+    return
+  }
+
+  revisions, err := app.revisions.All(r.Context(), snippet.ID)
+  if err != nil {
+    app.serverError(w, r, err)
+    return
+  }
+
+  data := app.newTemplateData(r)
+  data.Snippet = snippet
+  data.Revisions = revisions
+
+  app.render(w, r, http.StatusOK, "history.tmpl", data)
+}
+
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+  snippet, ok := app.snippetFromPath(w, r)
+  if !ok {
+    return
+  }
+
+  // Read the from and to revision numbers from the query string. If they
+  // aren't provided we default to comparing the latest revision with the one
+  // before it.
+  qs := r.URL.Query()
+
+  var from, to int
+  if qs.Get("from") == "" || qs.Get("to") == "" {
+    revisions, err := app.revisions.All(r.Context(), snippet.ID)
+    if err != nil {
+      app.serverError(w, r, err)
+      return
+    }
+    if len(revisions) > 0 {
+      to = revisions[0].Version
+      from = max(to-1, 1)
+    }
+  }
+
+  from, err := readInt(qs, "from", from)
+  if err != nil {
+    app.clientError(w, http.StatusBadRequest)
+    return
+  }
+
+  to, err = readInt(qs, "to", to)
+  if err != nil {
+    app.clientError(w, http.StatusBadRequest)
+    return
+  }
+
+  fromRevision, err := app.revisions.Get(r.Context(), snippet.ID, from)
+  if err != nil {
+    if errors.Is(err, models.ErrNoRecord) {
+      http.NotFound(w, r)
+    } else {
+      app.serverError(w, r, err)
+    }
+    return
+  }
+
+  toRevision, err := app.revisions.Get(r.Context(), snippet.ID, to)
+  if err != nil {
+    if errors.Is(err, models.ErrNoRecord) {
+      http.NotFound(w, r)
+    } else {
+      app.serverError(w, r, err)
+    }
+    return
+  }
+
+  data := app.newTemplateData(r)
+  data.Snippet = snippet
+  data.FromRevision = fromRevision
+  data.ToRevision = toRevision
+  data.Diff = diff.Unified(fromRevision.Content, toRevision.Content, 3)
+
+  app.render(w, r, http.StatusOK, "diff.tmpl", data)
+}
+
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
   data := app.newTemplateData(r)
 
   // Initialize a new snippetCreateForm instance and pass it to the template.
   // Notice how this is also a great opportunity to set any default or
   // `initial` values for the form... here we set the initial value for the
-  // snippet expiry to 365 days.
+  // snippet expiry to one year.
   data.Form = snippetCreateForm{
-    Expires: 365,
+    Visibility: models.VisibilityPublic,
+    Expires:    "365d",
   }
 
   app.render(w, r, http.StatusOK, "create.tmpl", data)
@@ -114,29 +686,8 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
     return
   }
 
-  // Because the Validator struct is embedded by the snippetCreateForm struct,
-  // we can call CheckField() directly on it to execute our validation checks.
-  // CheckField() will add the provided key and error message to the 
-  // FieldErrors map if the check does not evaluate to true. For example, in
-  // the first line here we "check that the form.Title field is not blank". In
-  // the second, we "check that the form.Title field has a maximum character
-  // length of 100" and so on.
-  form.CheckField(
-    validator.NotBlank(form.Title), 
-    "title", 
-    "this field cannot be blank")
-  form.CheckField(
-    validator.MaxChars(form.Title, 100), 
-    "title", 
-    "this field cannot be more than 100 characters long")
-  form.CheckField(
-    validator.NotBlank(form.Content),
-    "content",
-    "this field cannot be blank")
-  form.CheckField(
-    validator.PermittedValue(form.Expires, 1, 7, 365),
-    "expires",
-    "this field must equal 1, 7, or 365")
+  // Run the validation checks against the form contents.
+  validateSnippetForm(&form)
 
   // Use tha Valid() method to see if any of the checks failed. If they did
   // then re-render the template passing in the form in the same way as before.
@@ -147,20 +698,155 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
     return
   }
 
+  // Retrieve the ID of the currently authenticated user from the session, so
+  // that we can record them as the author of the snippet.
+  userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+  snippet := models.Snippet{
+    Title:            form.Title,
+    Content:          form.Content,
+    Language:         form.Language,
+    Visibility:       form.Visibility,
+    BurnAfterReading: form.BurnAfterReading,
+    UserID:           userID,
+    Tags:             parseTags(form.Tags),
+    Expires:          expiryTime(form, time.Now()),
+  }
+
   // Pass the data to the SnippetModel.Insert() method, receiving the
-  // ID of the new record back.
-  id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+  // slug of the new record back.
+  slug, err := app.snippets.Insert(r.Context(), snippet)
   if err != nil {
     app.serverError(w, r, err)
     return
   }
 
+  app.metrics.snippetsCreated.WithLabelValues("web").Inc()
+
   // Use the Put() method to add a string value ("snippet successfully
   // created...")
   app.sessionManager.Put(r.Context(), "flash", "snippet successfully created...")
 
   // Redirect the user to the relevant page for the snippet.
-  http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+  http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", slug), http.StatusSeeOther)
+}
+
+// The ownedSnippet() helper works like snippetFromPath(), but also checks
+// that the snippet belongs to the currently authenticated user. If it belongs
+// to somebody else it sends a 403 Forbidden response and the returned bool
+// will be false.
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+  snippet, ok := app.snippetFromPath(w, r)
+  if !ok {
+    return models.Snippet{}, false
+  }
+
+  if snippet.UserID != app.authenticatedUserID(r) {
+    app.clientError(w, http.StatusForbidden)
+    return models.Snippet{}, false
+  }
+
+  return snippet, true
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+  snippet, ok := app.ownedSnippet(w, r)
+  if !ok {
+    return
+  }
+
+  // Pre-populate the form with the current snippet data. We keep the current
+  // expiry by default, by giving it as a custom expiry time. The form only
+  // has minute precision, so we round it up to the next minute to avoid
+  // making it earlier.
+  data := app.newTemplateData(r)
+  data.Snippet = snippet
+  form := snippetCreateForm{
+    Title:            snippet.Title,
+    Content:          snippet.Content,
+    Language:         snippet.Language,
+    Visibility:       snippet.Visibility,
+    BurnAfterReading: snippet.BurnAfterReading,
+    Tags:             strings.Join(snippet.Tags, ", "),
+    Expires:          expiresNever,
+  }
+  if !snippet.NeverExpires() {
+    form.Expires = expiresCustom
+    form.ExpiresAt = snippet.Expires.UTC().Add(time.Minute - 1).
+      Truncate(time.Minute).Format(datetimeLayout)
+  }
+  data.Form = form
+
+  app.render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+  snippet, ok := app.ownedSnippet(w, r)
+  if !ok {
+    return
+  }
+
+  var form snippetCreateForm
+
+  err := app.decodePostForm(r, &form)
+  if err != nil {
+    app.clientError(w, http.StatusBadRequest)
+    return
+  }
+
+  validateSnippetForm(&form)
+
+  if !form.Valid() {
+    data := app.newTemplateData(r)
+    data.Snippet = snippet
+    data.Form = form
+    app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl", data)
+    return
+  }
+
+  snippet.Title = form.Title
+  snippet.Content = form.Content
+  snippet.Language = form.Language
+  snippet.Visibility = form.Visibility
+  snippet.BurnAfterReading = form.BurnAfterReading
+  snippet.Tags = parseTags(form.Tags)
+  snippet.Expires = expiryTime(form, time.Now())
+
+  // The snippet could have expired or been deleted since we fetched it.
+  err = app.snippets.Update(r.Context(), snippet, app.authenticatedUserID(r))
+  if err != nil {
+    if errors.Is(err, models.ErrNoRecord) {
+      http.NotFound(w, r)
+    } else {
+      app.serverError(w, r, err)
+    }
+    return
+  }
+
+  app.sessionManager.Put(r.Context(), "flash", "snippet successfully updated...")
+
+  http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+  snippet, ok := app.ownedSnippet(w, r)
+  if !ok {
+    return
+  }
+
+  err := app.snippets.Delete(r.Context(), snippet.ID)
+  if err != nil {
+    if errors.Is(err, models.ErrNoRecord) {
+      http.NotFound(w, r)
+    } else {
+      app.serverError(w, r, err)
+    }
+    return
+  }
+
+  app.sessionManager.Put(r.Context(), "flash", "snippet successfully deleted...")
+
+  http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
@@ -213,7 +899,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 
   // Try to create a new user record in the database. If the email already
   // exists then add an error message to the form and re-display it.
-  err = app.users.Insert(form.Name, form.Email, form.Password)
+  err = app.users.Insert(r.Context(), form.Name, form.Email, form.Password)
   if err != nil {
     if errors.Is(err, models.ErrDuplicateEmail) {
       form.AddFieldError("email", "email address is already in use")
@@ -280,7 +966,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 
   // Check whether the credentials are valid. If they're not, add a generic
   // non-field error message and re-display the login page.
-  id, err := app.users.Authenticate(form.Email, form.Password)
+  id, err := app.users.Authenticate(r.Context(), form.Email, form.Password)
   if err != nil {
     if errors.Is(err, models.ErrInvalidCredentials) {
       form.AddNonFieldError("email or password is incorrect")
@@ -333,6 +1019,111 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
   http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+func (app *application) account(w http.ResponseWriter, r *http.Request) {
+  data := app.newTemplateData(r)
+  data.Form = tokenCreateForm{
+    Scope:   models.ScopeRead,
+    Expires: "90d",
+  }
+
+  app.renderAccount(w, r, http.StatusOK, data)
+}
+
+// The renderAccount() helper adds the user's API tokens to the template data,
+// and renders the account page.
+func (app *application) renderAccount(w http.ResponseWriter, r *http.Request, status int, data templateData) {
+  tokens, err := app.tokens.ForUser(r.Context(), app.authenticatedUserID(r))
+  if err != nil {
+    app.serverError(w, r, err)
+    return
+  }
+
+  data.Tokens = tokens
+  // A newly created token is passed through the session, in the same way as
+  // a flash message. This is the only time it's ever shown.
+  data.NewToken = app.sessionManager.PopString(r.Context(), "newToken")
+
+  app.render(w, r, status, "account.tmpl", data)
+}
+
+func (app *application) accountTokenCreatePost(w http.ResponseWriter, r *http.Request) {
+  var form tokenCreateForm
+
+  err := app.decodePostForm(r, &form)
+  if err != nil {
+    app.clientError(w, http.StatusBadRequest)
+    return
+  }
+
+  form.CheckField(
+    validator.NotBlank(form.Name),
+    "name",
+    "this field cannot be blank")
+  form.CheckField(
+    validator.MaxChars(form.Name, 100),
+    "name",
+    "this field cannot be more than 100 characters long")
+  form.CheckField(
+    validator.PermittedValue(form.Scope, models.Scopes...),
+    "scope",
+    "this field must equal read or write")
+
+  var expires time.Time
+  var values []string
+  for _, option := range tokenExpiryOptions {
+    values = append(values, option.Value)
+    if option.Value == form.Expires && option.Duration > 0 {
+      expires = time.Now().Add(option.Duration)
+    }
+  }
+  form.CheckField(
+    validator.PermittedValue(form.Expires, values...),
+    "expires",
+    "this field must be one of the listed options")
+
+  if !form.Valid() {
+    data := app.newTemplateData(r)
+    data.Form = form
+    app.renderAccount(w, r, http.StatusUnprocessableEntity, data)
+    return
+  }
+
+  token, err := app.tokens.Insert(r.Context(), app.authenticatedUserID(r), form.Name, form.Scope, expires)
+  if err != nil {
+    app.serverError(w, r, err)
+    return
+  }
+
+  app.sessionManager.Put(r.Context(), "newToken", token)
+  app.sessionManager.Put(r.Context(), "flash", "token successfully created...")
+
+  http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+  id, err := strconv.Atoi(r.PathValue("id"))
+  if err != nil || id < 1 {
+    http.NotFound(w, r)
+    return
+  }
+
+  // Revoke() only deletes the token if it belongs to the authenticated user,
+  // so there's no need to check who owns it first.
+  err = app.tokens.Revoke(r.Context(), id, app.authenticatedUserID(r))
+  if err != nil {
+    if errors.Is(err, models.ErrNoRecord) {
+      http.NotFound(w, r)
+    } else {
+      app.serverError(w, r, err)
+    }
+    return
+  }
+
+  app.sessionManager.Put(r.Context(), "flash", "token successfully revoked...")
+
+  http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
 func ping(w http.ResponseWriter, r *http.Request) {
   w.Write([]byte("OK"))
 }
@@ -3,9 +3,13 @@ package main
 import (
   "net/http"
   "net/url"
+  "strings"
   "testing"
+  "time"
 
   "github.com/kjloveless/snippetbox/internal/assert"
+  "github.com/kjloveless/snippetbox/internal/models"
+  "github.com/kjloveless/snippetbox/internal/models/mocks"
 )
 
 func TestPing(t *testing.T) {
@@ -39,15 +43,55 @@ func TestSnippetView(t *testing.T) {
   }{
     {
       name:     "Valid ID",
-      urlPath:  "/snippet/view/1",
+      urlPath:  "/snippet/view/s1lentPd",
       wantCode: http.StatusOK,
       wantBody: "an old silent pond...",
     },
+    {
+      name:     "Shows author",
+      urlPath:  "/snippet/view/s1lentPd",
+      wantCode: http.StatusOK,
+      wantBody: "by <a href='/snippets?author=1'>Alice Jones</a>",
+    },
+    {
+      name:     "Linkable line numbers",
+      urlPath:  "/snippet/view/s1lentPd",
+      wantCode: http.StatusOK,
+      wantBody: `id="L1"`,
+    },
+    {
+      name:     "Shows language",
+      urlPath:  "/snippet/view/w1ntryFr",
+      wantCode: http.StatusOK,
+      wantBody: "<span class='language'>Plain text</span>",
+    },
+    {
+      name:     "Shows expiry countdown",
+      urlPath:  "/snippet/view/s1lentPd",
+      wantCode: http.StatusOK,
+      wantBody: "(in <span data-expires=",
+    },
+    {
+      name:     "Unlisted snippet",
+      urlPath:  "/snippet/view/c0ldShwr",
+      wantCode: http.StatusOK,
+      wantBody: "the first cold shower...",
+    },
+    {
+      name:     "Someone else's private snippet",
+      urlPath:  "/snippet/view/l1ghtnFl",
+      wantCode: http.StatusNotFound,
+    },
     {
       name:     "Non-existent ID",
-      urlPath:  "/snippet/view/2",
+      urlPath:  "/snippet/view/m1ss1ngX",
       wantCode: http.StatusNotFound,
     },
+    {
+      name:     "Query timeout",
+      urlPath:  "/snippet/view/sl0wQury",
+      wantCode: http.StatusServiceUnavailable,
+    },
     {
       name:     "Negative ID",
       urlPath:  "/snippet/view/-1",
@@ -201,3 +245,1096 @@ func TestUserSignup(t *testing.T) {
     })
   }
 }
+
+func TestSnippetEdit(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  // Check that unauthenticated users are redirected to the login page.
+  t.Run("Unauthenticated", func(t *testing.T) {
+    code, header, _ := ts.get(t, "/snippet/edit/s1lentPd")
+
+    assert.Equal(t, code, http.StatusSeeOther)
+    assert.Equal(t, header.Get("Location"), "/user/login")
+  })
+
+  ts.login(t)
+
+  tests := []struct {
+    name      string
+    urlPath   string
+    wantCode  int
+    wantBody  string
+  }{
+    {
+      name:     "Own snippet",
+      urlPath:  "/snippet/edit/s1lentPd",
+      wantCode: http.StatusOK,
+      wantBody: "<form action='/snippet/edit/s1lentPd' method='POST'>",
+    },
+    {
+      name:     "Someone else's snippet",
+      urlPath:  "/snippet/edit/w1ntryFr",
+      wantCode: http.StatusForbidden,
+    },
+    {
+      name:     "Non-existent ID",
+      urlPath:  "/snippet/edit/m1ss1ngX",
+      wantCode: http.StatusNotFound,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, _, body := ts.get(t, tt.urlPath)
+
+      assert.Equal(t, code, tt.wantCode)
+
+      if tt.wantBody != "" {
+        assert.StringContains(t, body, tt.wantBody)
+      }
+    })
+  }
+}
+
+func TestSnippetCreatePost(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  ts.login(t)
+
+  _, _, body := ts.get(t, "/snippet/create")
+  validCSRFToken := extractCSRFToken(t, body)
+
+  // The mock model keeps hold of the snippet that the handler inserts, so we
+  // can check that the form was turned into the snippet we expect.
+  snippets := app.snippets.(*mocks.SnippetModel)
+
+  tests := []struct {
+    name          string
+    title         string
+    tags          string
+    language      string
+    visibility    string
+    burn          bool
+    expires       string
+    expiresAt     string
+    tzOffset      string
+    wantCode      int
+    wantLocation  string
+    wantTags      string
+    wantExpiresIn time.Duration
+    wantExpires   time.Time
+  }{
+    {
+      name:          "Valid submission",
+      title:         "an old silent pond",
+      tags:          "haiku, Nature, haiku",
+      visibility:    "public",
+      expires:       "7d",
+      wantCode:      http.StatusSeeOther,
+      wantLocation:  "/snippet/view/s1lentPd",
+      wantTags:      "haiku,nature",
+      wantExpiresIn: 7 * 24 * time.Hour,
+    },
+    {
+      name:          "With language",
+      title:         "an old silent pond",
+      language:      "plaintext",
+      visibility:    "unlisted",
+      expires:       "1h",
+      wantCode:      http.StatusSeeOther,
+      wantLocation:  "/snippet/view/s1lentPd",
+      wantExpiresIn: time.Hour,
+    },
+    {
+      name:          "Burn after reading",
+      title:         "the door code",
+      visibility:    "private",
+      burn:          true,
+      expires:       "1d",
+      wantCode:      http.StatusSeeOther,
+      wantLocation:  "/snippet/view/s1lentPd",
+      wantExpiresIn: 24 * time.Hour,
+    },
+    {
+      name:         "Never expires",
+      title:        "an old silent pond",
+      visibility:   "public",
+      expires:      "never",
+      wantCode:     http.StatusSeeOther,
+      wantLocation: "/snippet/view/s1lentPd",
+    },
+    {
+      name:         "Custom expiry",
+      title:        "an old silent pond",
+      visibility:   "public",
+      expires:      "custom",
+      expiresAt:    "2099-01-01T09:00",
+      tzOffset:     "-60",
+      wantCode:     http.StatusSeeOther,
+      wantLocation: "/snippet/view/s1lentPd",
+      wantExpires:  time.Date(2099, 1, 1, 8, 0, 0, 0, time.UTC),
+    },
+    {
+      name:       "Invalid visibility",
+      title:      "an old silent pond",
+      visibility: "secret",
+      expires:    "7d",
+      wantCode:   http.StatusUnprocessableEntity,
+    },
+    {
+      name:     "Invalid language",
+      title:    "an old silent pond",
+      language: "cobol",
+      expires:  "7d",
+      wantCode: http.StatusUnprocessableEntity,
+    },
+    {
+      name:     "Too many tags",
+      title:    "an old silent pond",
+      tags:     "a, b, c, d, e, f",
+      expires:  "7d",
+      wantCode: http.StatusUnprocessableEntity,
+    },
+    {
+      name:     "Empty title",
+      title:    "",
+      expires:  "7d",
+      wantCode: http.StatusUnprocessableEntity,
+    },
+    {
+      name:      "Custom expiry in the past",
+      title:     "an old silent pond",
+      expires:   "custom",
+      expiresAt: "2000-01-01T00:00",
+      wantCode:  http.StatusUnprocessableEntity,
+    },
+    {
+      name:     "Invalid expiry",
+      title:    "an old silent pond",
+      expires:  "30",
+      wantCode: http.StatusUnprocessableEntity,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      form := url.Values{}
+      form.Add("title", tt.title)
+      form.Add("content", "a frog jumps into the pond")
+      form.Add("tags", tt.tags)
+      form.Add("language", tt.language)
+      form.Add("visibility", tt.visibility)
+      if tt.burn {
+        form.Add("burn", "true")
+      }
+      form.Add("expires", tt.expires)
+      form.Add("expires_at", tt.expiresAt)
+      form.Add("tz_offset", tt.tzOffset)
+      form.Add("csrf_token", validCSRFToken)
+
+      start := time.Now()
+      code, header, _ := ts.postForm(t, "/snippet/create", form)
+
+      assert.Equal(t, code, tt.wantCode)
+      assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+      if code != http.StatusSeeOther {
+        return
+      }
+
+      // The snippet belongs to the user in the session, which is the one
+      // that ts.login() logged in as.
+      snippet := snippets.Inserted()
+      assert.Equal(t, snippet.UserID, 1)
+      assert.Equal(t, snippet.Title, tt.title)
+      assert.Equal(t, strings.Join(snippet.Tags, ","), tt.wantTags)
+      assert.Equal(t, snippet.Language, tt.language)
+      assert.Equal(t, snippet.Visibility, tt.visibility)
+      assert.Equal(t, snippet.BurnAfterReading, tt.burn)
+
+      // Relative expiry times are counted from when the request was handled,
+      // so we can only check that they fall within the time it took.
+      switch {
+      case tt.wantExpiresIn != 0:
+        earliest := start.Add(tt.wantExpiresIn)
+        latest := time.Now().Add(tt.wantExpiresIn)
+        if snippet.Expires.Before(earliest) || snippet.Expires.After(latest) {
+          t.Errorf("got expiry %v; want between %v and %v", snippet.Expires, earliest, latest)
+        }
+      default:
+        assert.Equal(t, snippet.Expires, tt.wantExpires)
+      }
+    })
+  }
+}
+
+func TestSnippetEditPost(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  ts.login(t)
+
+  _, _, body := ts.get(t, "/snippet/edit/s1lentPd")
+  validCSRFToken := extractCSRFToken(t, body)
+
+  tests := []struct {
+    name          string
+    urlPath       string
+    title         string
+    tags          string
+    language      string
+    visibility    string
+    expires       string
+    expiresAt     string
+    wantCode      int
+    wantLocation  string
+  }{
+    {
+      name:         "Valid submission",
+      urlPath:      "/snippet/edit/s1lentPd",
+      title:        "an old silent pond",
+      tags:         "haiku, Nature, haiku",
+      visibility:   "public",
+      expires:      "7d",
+      wantCode:     http.StatusSeeOther,
+      wantLocation: "/snippet/view/s1lentPd",
+    },
+    {
+      name:         "With language",
+      urlPath:      "/snippet/edit/s1lentPd",
+      title:        "an old silent pond",
+      language:     "plaintext",
+      visibility:   "unlisted",
+      expires:      "7d",
+      wantCode:     http.StatusSeeOther,
+      wantLocation: "/snippet/view/s1lentPd",
+    },
+    {
+      name:       "Invalid visibility",
+      urlPath:    "/snippet/edit/s1lentPd",
+      title:      "an old silent pond",
+      visibility: "secret",
+      expires:    "7d",
+      wantCode:   http.StatusUnprocessableEntity,
+    },
+    {
+      name:     "Invalid language",
+      urlPath:  "/snippet/edit/s1lentPd",
+      title:    "an old silent pond",
+      language: "cobol",
+      expires:  "7d",
+      wantCode: http.StatusUnprocessableEntity,
+    },
+    {
+      name:     "Too many tags",
+      urlPath:  "/snippet/edit/s1lentPd",
+      title:    "an old silent pond",
+      tags:     "a, b, c, d, e, f",
+      expires:  "7d",
+      wantCode: http.StatusUnprocessableEntity,
+    },
+    {
+      name:     "Invalid tag",
+      urlPath:  "/snippet/edit/s1lentPd",
+      title:    "an old silent pond",
+      tags:     "haiku, on call",
+      expires:  "7d",
+      wantCode: http.StatusUnprocessableEntity,
+    },
+    {
+      name:     "Empty title",
+      urlPath:  "/snippet/edit/s1lentPd",
+      title:    "",
+      expires:  "7d",
+      wantCode: http.StatusUnprocessableEntity,
+    },
+    {
+      name:         "Never expires",
+      urlPath:      "/snippet/edit/s1lentPd",
+      title:        "an old silent pond",
+      visibility:   "public",
+      expires:      "never",
+      wantCode:     http.StatusSeeOther,
+      wantLocation: "/snippet/view/s1lentPd",
+    },
+    {
+      name:         "Custom expiry",
+      urlPath:      "/snippet/edit/s1lentPd",
+      title:        "an old silent pond",
+      visibility:   "public",
+      expires:      "custom",
+      expiresAt:    time.Now().UTC().AddDate(0, 1, 0).Format("2006-01-02T15:04"),
+      wantCode:     http.StatusSeeOther,
+      wantLocation: "/snippet/view/s1lentPd",
+    },
+    {
+      name:      "Custom expiry in the past",
+      urlPath:   "/snippet/edit/s1lentPd",
+      title:     "an old silent pond",
+      expires:   "custom",
+      expiresAt: "2000-01-01T00:00",
+      wantCode:  http.StatusUnprocessableEntity,
+    },
+    {
+      name:      "Invalid custom expiry",
+      urlPath:   "/snippet/edit/s1lentPd",
+      title:     "an old silent pond",
+      expires:   "custom",
+      expiresAt: "tomorrow",
+      wantCode:  http.StatusUnprocessableEntity,
+    },
+    {
+      name:     "Invalid expiry",
+      urlPath:  "/snippet/edit/s1lentPd",
+      title:    "an old silent pond",
+      expires:  "30",
+      wantCode: http.StatusUnprocessableEntity,
+    },
+    {
+      name:     "Someone else's snippet",
+      urlPath:  "/snippet/edit/w1ntryFr",
+      title:    "an old silent pond",
+      expires:  "7d",
+      wantCode: http.StatusForbidden,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      form := url.Values{}
+      form.Add("title", tt.title)
+      form.Add("content", "a frog jumps into the pond")
+      form.Add("tags", tt.tags)
+      form.Add("language", tt.language)
+      form.Add("visibility", tt.visibility)
+      form.Add("expires", tt.expires)
+      form.Add("expires_at", tt.expiresAt)
+      form.Add("csrf_token", validCSRFToken)
+
+      code, header, _ := ts.postForm(t, tt.urlPath, form)
+
+      assert.Equal(t, code, tt.wantCode)
+      assert.Equal(t, header.Get("Location"), tt.wantLocation)
+    })
+  }
+}
+
+func TestExpiryTime(t *testing.T) {
+  now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+  tests := []struct {
+    name  string
+    form  snippetCreateForm
+    want  time.Time
+  }{
+    {
+      name: "Ten minutes",
+      form: snippetCreateForm{Expires: "10m"},
+      want: time.Date(2024, 1, 1, 12, 10, 0, 0, time.UTC),
+    },
+    {
+      name: "One week",
+      form: snippetCreateForm{Expires: "7d"},
+      want: time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC),
+    },
+    {
+      name: "Custom",
+      form: snippetCreateForm{Expires: "custom", ExpiresAt: "2024-02-03T04:05"},
+      want: time.Date(2024, 2, 3, 4, 5, 0, 0, time.UTC),
+    },
+    {
+      // A browser in UTC+2 sends an offset of -120 minutes.
+      name: "Custom in the browser's time zone",
+      form: snippetCreateForm{Expires: "custom", ExpiresAt: "2024-02-03T04:05", TZOffset: -120},
+      want: time.Date(2024, 2, 3, 2, 5, 0, 0, time.UTC),
+    },
+    {
+      name: "Never",
+      form: snippetCreateForm{Expires: "never"},
+      want: time.Time{},
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      assert.Equal(t, expiryTime(tt.form, now), tt.want)
+    })
+  }
+}
+
+func TestSnippetDeletePost(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  ts.login(t)
+
+  _, _, body := ts.get(t, "/snippet/view/s1lentPd")
+  validCSRFToken := extractCSRFToken(t, body)
+
+  tests := []struct {
+    name      string
+    urlPath   string
+    wantCode  int
+  }{
+    {
+      name:     "Own snippet",
+      urlPath:  "/snippet/delete/s1lentPd",
+      wantCode: http.StatusSeeOther,
+    },
+    {
+      name:     "Someone else's snippet",
+      urlPath:  "/snippet/delete/w1ntryFr",
+      wantCode: http.StatusForbidden,
+    },
+    {
+      name:     "Non-existent ID",
+      urlPath:  "/snippet/delete/m1ss1ngX",
+      wantCode: http.StatusNotFound,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      form := url.Values{}
+      form.Add("csrf_token", validCSRFToken)
+
+      code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+      assert.Equal(t, code, tt.wantCode)
+    })
+  }
+}
+
+func TestSnippetViewBurn(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  t.Run("First view", func(t *testing.T) {
+    code, header, body := ts.get(t, "/snippet/view/bUrn1tNw")
+
+    assert.Equal(t, code, http.StatusOK)
+    assert.Equal(t, header.Get("Cache-Control"), "no-store")
+    assert.StringContains(t, body, "This snippet has now been destroyed.")
+  })
+
+  t.Run("Already burned", func(t *testing.T) {
+    code, _, body := ts.get(t, "/snippet/view/bUrnEd0n")
+
+    assert.Equal(t, code, http.StatusGone)
+    assert.StringContains(t, body, "<h2>This snippet has been destroyed</h2>")
+  })
+
+  t.Run("History", func(t *testing.T) {
+    // Only the view page can show a burn-after-reading snippet to somebody
+    // other than its author.
+    code, _, _ := ts.get(t, "/snippet/view/bUrn1tNw/history")
+
+    assert.Equal(t, code, http.StatusNotFound)
+  })
+}
+
+func TestSnippetLegacyRedirect(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name          string
+    urlPath       string
+    wantCode      int
+    wantLocation  string
+  }{
+    {
+      name:         "View",
+      urlPath:      "/snippet/view/1",
+      wantCode:     http.StatusMovedPermanently,
+      wantLocation: "/snippet/view/s1lentPd",
+    },
+    {
+      name:         "Diff with query string",
+      urlPath:      "/snippet/view/1/diff?from=1&to=2",
+      wantCode:     http.StatusMovedPermanently,
+      wantLocation: "/snippet/view/s1lentPd/diff?from=1&to=2",
+    },
+    {
+      name:     "Non-existent ID",
+      urlPath:  "/snippet/view/2",
+      wantCode: http.StatusNotFound,
+    },
+    {
+      name:     "Unlisted snippet",
+      urlPath:  "/snippet/view/4",
+      wantCode: http.StatusNotFound,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, header, _ := ts.get(t, tt.urlPath)
+
+      assert.Equal(t, code, tt.wantCode)
+      assert.Equal(t, header.Get("Location"), tt.wantLocation)
+    })
+  }
+}
+
+func TestSnippetHistory(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name      string
+    urlPath   string
+    wantCode  int
+    wantBody  string
+  }{
+    {
+      name:     "Valid ID",
+      urlPath:  "/snippet/view/s1lentPd/history",
+      wantCode: http.StatusOK,
+      wantBody: "<a href='/snippet/view/s1lentPd/diff?from=1&to=2'>diff</a>",
+    },
+    {
+      name:     "Non-existent ID",
+      urlPath:  "/snippet/view/m1ss1ngX/history",
+      wantCode: http.StatusNotFound,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, _, body := ts.get(t, tt.urlPath)
+
+      assert.Equal(t, code, tt.wantCode)
+
+      if tt.wantBody != "" {
+        assert.StringContains(t, body, tt.wantBody)
+      }
+    })
+  }
+}
+
+func TestSnippetDiff(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name      string
+    urlPath   string
+    wantCode  int
+    wantBody  string
+  }{
+    {
+      name:     "Explicit revisions",
+      urlPath:  "/snippet/view/s1lentPd/diff?from=1&to=2",
+      wantCode: http.StatusOK,
+      wantBody: "<span class='delete'>-a frog jumps in,</span>",
+    },
+    {
+      name:     "Default revisions",
+      urlPath:  "/snippet/view/s1lentPd/diff",
+      wantCode: http.StatusOK,
+      wantBody: "Revision #1 by Alice Jones",
+    },
+    {
+      name:     "Same revision",
+      urlPath:  "/snippet/view/s1lentPd/diff?from=2&to=2",
+      wantCode: http.StatusOK,
+      wantBody: "The content of these revisions is identical.",
+    },
+    {
+      name:     "Non-existent revision",
+      urlPath:  "/snippet/view/s1lentPd/diff?from=1&to=5",
+      wantCode: http.StatusNotFound,
+    },
+    {
+      name:     "Invalid revision",
+      urlPath:  "/snippet/view/s1lentPd/diff?from=foo&to=2",
+      wantCode: http.StatusBadRequest,
+    },
+    {
+      name:     "Non-existent ID",
+      urlPath:  "/snippet/view/m1ss1ngX/diff?from=1&to=2",
+      wantCode: http.StatusNotFound,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, _, body := ts.get(t, tt.urlPath)
+
+      assert.Equal(t, code, tt.wantCode)
+
+      if tt.wantBody != "" {
+        assert.StringContains(t, body, tt.wantBody)
+      }
+    })
+  }
+}
+
+func TestSearch(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name        string
+    urlPath     string
+    wantCode    int
+    wantBody    string
+  }{
+    {
+      name:     "No query",
+      urlPath:  "/search",
+      wantCode: http.StatusOK,
+      wantBody: "<form action='/search' method='GET' class='search'>",
+    },
+    {
+      name:     "Matching query",
+      urlPath:  "/search?q=pond",
+      wantCode: http.StatusOK,
+      wantBody: "an old silent <mark>pond</mark>",
+    },
+    {
+      name:     "No matches",
+      urlPath:  "/search?q=tortoise",
+      wantCode: http.StatusOK,
+      wantBody: "No snippets matched your search.",
+    },
+    {
+      name:     "Invalid page",
+      urlPath:  "/search?q=pond&page=0",
+      wantCode: http.StatusBadRequest,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, _, body := ts.get(t, tt.urlPath)
+
+      assert.Equal(t, code, tt.wantCode)
+
+      if tt.wantBody != "" {
+        assert.StringContains(t, body, tt.wantBody)
+      }
+    })
+  }
+}
+
+func TestSnippetList(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name          string
+    urlPath       string
+    wantCode      int
+    wantBody      []string
+    dontWantBody  string
+  }{
+    {
+      name:     "All snippets",
+      urlPath:  "/snippets",
+      wantCode: http.StatusOK,
+      wantBody: []string{"an old silent pond", "over the wintry forest"},
+    },
+    {
+      name:         "By author",
+      urlPath:      "/snippets?author=2",
+      wantCode:     http.StatusOK,
+      wantBody:     []string{"over the wintry forest"},
+      dontWantBody: "an old silent pond",
+    },
+    {
+      name:         "Created before",
+      urlPath:      "/snippets?before=2000-01-01",
+      wantCode:     http.StatusOK,
+      wantBody:     []string{"There are no snippets matching these filters."},
+    },
+    {
+      name:     "Created after",
+      urlPath:  "/snippets?after=2000-01-01&sort=oldest",
+      wantCode: http.StatusOK,
+      wantBody: []string{"an old silent pond", "over the wintry forest"},
+    },
+    {
+      name:     "Invalid date",
+      urlPath:  "/snippets?after=yesterday",
+      wantCode: http.StatusUnprocessableEntity,
+      wantBody: []string{"this field must be a date"},
+    },
+    {
+      name:     "Invalid sort",
+      urlPath:  "/snippets?sort=random",
+      wantCode: http.StatusUnprocessableEntity,
+    },
+    {
+      name:     "Invalid author",
+      urlPath:  "/snippets?author=alice",
+      wantCode: http.StatusBadRequest,
+    },
+    {
+      name:     "Invalid cursor",
+      urlPath:  "/snippets?cursor=foo",
+      wantCode: http.StatusBadRequest,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, _, body := ts.get(t, tt.urlPath)
+
+      assert.Equal(t, code, tt.wantCode)
+
+      for _, want := range tt.wantBody {
+        assert.StringContains(t, body, want)
+      }
+
+      if tt.dontWantBody != "" {
+        assert.Equal(t, strings.Contains(body, tt.dontWantBody), false)
+      }
+    })
+  }
+}
+
+func TestSnippetVisibility(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  // Anonymous users shouldn't see unlisted or private snippets in the
+  // archive.
+  _, _, body := ts.get(t, "/snippets")
+  assert.Equal(t, strings.Contains(body, "the first cold shower"), false)
+  assert.Equal(t, strings.Contains(body, "a lightning flash"), false)
+
+  ts.login(t)
+
+  // Once logged in, the author can view their private snippet and find it in
+  // the archive, but other people's unlisted snippets are still not listed.
+  code, _, body := ts.get(t, "/snippet/view/l1ghtnFl")
+  assert.Equal(t, code, http.StatusOK)
+  assert.StringContains(t, body, "<span class='visibility'>private</span>")
+
+  _, _, body = ts.get(t, "/snippets")
+  assert.StringContains(t, body, "a lightning flash")
+  assert.Equal(t, strings.Contains(body, "the first cold shower"), false)
+}
+
+func TestSnippetListPagination(t *testing.T) {
+  // Page through the mock snippets one at a time, following the next and
+  // previous links.
+  page, err := (&mocks.SnippetModel{}).List(t.Context(), models.SnippetFilter{Limit: 1})
+  assert.NilError(t, err)
+  assert.Equal(t, len(page.Snippets), 1)
+  assert.Equal(t, page.Snippets[0].ID, 3)
+  assert.Equal(t, page.PrevCursor, "")
+
+  page, err = (&mocks.SnippetModel{}).List(t.Context(), models.SnippetFilter{Limit: 1, Cursor: page.NextCursor})
+  assert.NilError(t, err)
+  assert.Equal(t, len(page.Snippets), 1)
+  assert.Equal(t, page.Snippets[0].ID, 1)
+  assert.Equal(t, page.NextCursor, "")
+
+  page, err = (&mocks.SnippetModel{}).List(t.Context(), models.SnippetFilter{Limit: 1, Cursor: page.PrevCursor})
+  assert.NilError(t, err)
+  assert.Equal(t, len(page.Snippets), 1)
+  assert.Equal(t, page.Snippets[0].ID, 3)
+  assert.Equal(t, page.PrevCursor, "")
+}
+
+func TestTagView(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name          string
+    urlPath       string
+    wantBody      []string
+    dontWantBody  string
+  }{
+    {
+      name:     "Shared tag",
+      urlPath:  "/tags/haiku",
+      wantBody: []string{"an old silent pond", "over the wintry forest"},
+    },
+    {
+      name:         "Single snippet",
+      urlPath:      "/tags/winter",
+      wantBody:     []string{"Snippets tagged <span class='tag'>winter</span>", "over the wintry forest"},
+      dontWantBody: "an old silent pond",
+    },
+    {
+      name:     "Unused tag",
+      urlPath:  "/tags/sql",
+      wantBody: []string{"There are no snippets matching these filters."},
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, _, body := ts.get(t, tt.urlPath)
+
+      assert.Equal(t, code, http.StatusOK)
+
+      for _, want := range tt.wantBody {
+        assert.StringContains(t, body, want)
+      }
+
+      if tt.dontWantBody != "" {
+        assert.Equal(t, strings.Contains(body, tt.dontWantBody), false)
+      }
+    })
+  }
+}
+
+func TestHome(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  code, _, body := ts.get(t, "/")
+
+  assert.Equal(t, code, http.StatusOK)
+  assert.StringContains(t, body, "an old silent pond")
+  assert.StringContains(t, body, "<a class='tag weight-4' href='/tags/haiku'>haiku</a>")
+  assert.StringContains(t, body, "<a class='tag weight-2' href='/tags/winter'>winter</a>")
+}
+
+func TestAccount(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  t.Run("Unauthenticated", func(t *testing.T) {
+    code, header, _ := ts.get(t, "/account")
+
+    assert.Equal(t, code, http.StatusSeeOther)
+    assert.Equal(t, header.Get("Location"), "/user/login")
+  })
+
+  ts.login(t)
+
+  t.Run("Lists tokens", func(t *testing.T) {
+    code, _, body := ts.get(t, "/account")
+
+    assert.Equal(t, code, http.StatusOK)
+    assert.StringContains(t, body, "laptop")
+    assert.StringContains(t, body, "editor plugin")
+    assert.StringContains(t, body, "<form action='/account/tokens/revoke/2' method='POST'>")
+  })
+}
+
+func TestAccountTokenCreatePost(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  ts.login(t)
+
+  _, _, body := ts.get(t, "/account")
+  validCSRFToken := extractCSRFToken(t, body)
+
+  tests := []struct {
+    name      string
+    tokenName string
+    scope     string
+    expires   string
+    wantCode  int
+    wantBody  string
+  }{
+    {
+      name:      "Valid submission",
+      tokenName: "laptop",
+      scope:     "write",
+      expires:   "never",
+      wantCode:  http.StatusSeeOther,
+    },
+    {
+      name:      "Blank name",
+      tokenName: "",
+      scope:     "read",
+      expires:   "30d",
+      wantCode:  http.StatusUnprocessableEntity,
+      wantBody:  "this field cannot be blank",
+    },
+    {
+      name:      "Invalid scope",
+      tokenName: "laptop",
+      scope:     "admin",
+      expires:   "30d",
+      wantCode:  http.StatusUnprocessableEntity,
+      wantBody:  "this field must equal read or write",
+    },
+    {
+      name:      "Invalid expiry",
+      tokenName: "laptop",
+      scope:     "read",
+      expires:   "10m",
+      wantCode:  http.StatusUnprocessableEntity,
+      wantBody:  "this field must be one of the listed options",
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      form := url.Values{}
+      form.Add("name", tt.tokenName)
+      form.Add("scope", tt.scope)
+      form.Add("expires", tt.expires)
+      form.Add("csrf_token", validCSRFToken)
+
+      code, header, body := ts.postForm(t, "/account/tokens", form)
+
+      assert.Equal(t, code, tt.wantCode)
+
+      if tt.wantCode == http.StatusSeeOther {
+        assert.Equal(t, header.Get("Location"), "/account")
+
+        // The new token is shown on the account page once, and only once.
+        _, _, body = ts.get(t, "/account")
+        assert.StringContains(t, body, "<code class='token'>n3w-t0ken</code>")
+
+        _, _, body = ts.get(t, "/account")
+        assert.Equal(t, strings.Contains(body, "n3w-t0ken"), false)
+      }
+
+      if tt.wantBody != "" {
+        assert.StringContains(t, body, tt.wantBody)
+      }
+    })
+  }
+}
+
+func TestAccountTokenRevokePost(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  ts.login(t)
+
+  _, _, body := ts.get(t, "/account")
+  validCSRFToken := extractCSRFToken(t, body)
+
+  tests := []struct {
+    name     string
+    urlPath  string
+    wantCode int
+  }{
+    {
+      name:     "Own token",
+      urlPath:  "/account/tokens/revoke/1",
+      wantCode: http.StatusSeeOther,
+    },
+    {
+      name:     "Non-existent token",
+      urlPath:  "/account/tokens/revoke/99",
+      wantCode: http.StatusNotFound,
+    },
+    {
+      name:     "Invalid ID",
+      urlPath:  "/account/tokens/revoke/foo",
+      wantCode: http.StatusNotFound,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      form := url.Values{}
+      form.Add("csrf_token", validCSRFToken)
+
+      code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+      assert.Equal(t, code, tt.wantCode)
+    })
+  }
+}
+
+func TestSnippetRaw(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name             string
+    urlPath          string
+    wantCode         int
+    wantBody         string
+    wantCacheControl string
+  }{
+    {
+      name:             "Public snippet",
+      urlPath:          "/snippet/raw/s1lentPd",
+      wantCode:         http.StatusOK,
+      wantBody:         "an old silent pond...",
+      wantCacheControl: "public, max-age=300",
+    },
+    {
+      name:             "Unlisted snippet",
+      urlPath:          "/snippet/raw/c0ldShwr",
+      wantCode:         http.StatusOK,
+      wantBody:         "the first cold shower...",
+      wantCacheControl: "private, no-cache",
+    },
+    {
+      name:             "Burn-after-reading snippet",
+      urlPath:          "/snippet/raw/bUrn1tNw",
+      wantCode:         http.StatusOK,
+      wantBody:         "1234",
+      wantCacheControl: "no-store",
+    },
+    {
+      name:     "Burned snippet",
+      urlPath:  "/snippet/raw/bUrnEd0n",
+      wantCode: http.StatusGone,
+    },
+    {
+      name:     "Private snippet",
+      urlPath:  "/snippet/raw/l1ghtnFl",
+      wantCode: http.StatusNotFound,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, header, body := ts.get(t, tt.urlPath)
+
+      assert.Equal(t, code, tt.wantCode)
+
+      if tt.wantCode == http.StatusOK {
+        assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+        assert.Equal(t, header.Get("Cache-Control"), tt.wantCacheControl)
+        assert.Equal(t, body, tt.wantBody)
+      }
+    })
+  }
+
+  t.Run("Not modified", func(t *testing.T) {
+    _, header, _ := ts.get(t, "/snippet/raw/s1lentPd")
+
+    req, err := http.NewRequest(http.MethodGet, ts.URL + "/snippet/raw/s1lentPd", nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+    req.Header.Set("If-None-Match", header.Get("ETag"))
+
+    rs, err := ts.Client().Do(req)
+    if err != nil {
+      t.Fatal(err)
+    }
+    rs.Body.Close()
+
+    assert.Equal(t, rs.StatusCode, http.StatusNotModified)
+  })
+}
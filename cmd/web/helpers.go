@@ -5,9 +5,15 @@ import (
   "errors"
   "fmt"
   "net/http"
+  "net/url"
   "runtime/debug"
+  "slices"
+  "strconv"
+  "strings"
   "time"
 
+  "github.com/kjloveless/snippetbox/internal/models"
+
   "github.com/go-playground/form/v4"
   "github.com/justinas/nosurf"
 )
@@ -23,6 +29,8 @@ func (app *application) newTemplateData(r *http.Request) templateData {
     Flash:        app.sessionManager.PopString(r.Context(), "flash"),
     // Add the authentication status to the template data.
     IsAuthenticated:  app.isAuthenticated(r),
+    // And the ID of the authenticated user (or 0 if there isn't one).
+    AuthenticatedUserID:  app.authenticatedUserID(r),
     CSRFToken:        nosurf.Token(r),
   }
 }
@@ -76,7 +84,16 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
   // Write the template to the buffer, instead of straight to the
   // http.ResponseWriter. If there's an error, call our serviceError() helper
   // and then return.
+  // We time this, and record a span for it, so that we can see which pages
+  // are slow to render.
+  _, span := tracer().Start(r.Context(), "render " + page)
+  start := time.Now()
   err := ts.ExecuteTemplate(buf, "base", data)
+  app.metrics.templateRender.WithLabelValues(page).Observe(time.Since(start).Seconds())
+  if err != nil {
+    span.RecordError(err)
+  }
+  span.End()
   if err != nil {
     app.serverError(w, r, err)
     return
@@ -95,7 +112,9 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 
 // The serverError helper writes a log entry at Error level (including the
 // request method and URI as attributes), then sends a generic 500 Internal
-// Server Error response to the user.
+// Server Error response to the user. If the error was a database query timing
+// out, we send a 503 Service Unavailable response instead, because the
+// database is probably just busy and trying again later may well work.
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, 
   err error) {
   var (
@@ -107,9 +126,15 @@ func (app *application) serverError(w http.ResponseWriter, r *http.Request,
     trace = string(debug.Stack())
   )
 
-  app.logger.Error(err.Error(), "method", method, "uri", uri, "trace", trace)
-  http.Error(w, http.StatusText(http.StatusInternalServerError), 
-    http.StatusInternalServerError)
+  // We pass the request context along, so that the log entry includes the
+  // ID of the request's trace.
+  app.logger.ErrorContext(r.Context(), err.Error(), "method", method, "uri", uri, "trace", trace)
+
+  status := http.StatusInternalServerError
+  if errors.Is(err, models.ErrTimeout) {
+    status = http.StatusServiceUnavailable
+  }
+  http.Error(w, http.StatusText(status), status)
 }
 
 // The clientError helper sends a specific status code and corresponding
@@ -129,3 +154,56 @@ func (app *application) isAuthenticated(r *http.Request) bool {
   }
   return isAuthenticated
 }
+
+// Return the ID of the authenticated user making the current request, or 0 if
+// the request is not from an authenticated user.
+func (app *application) authenticatedUserID(r *http.Request) int {
+  id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+  if !ok {
+    return 0
+  }
+  return id
+}
+
+// Return the scope of the API token used to authenticate the current request,
+// or the empty string if the request wasn't authenticated with a token.
+func (app *application) tokenScope(r *http.Request) string {
+  scope, ok := r.Context().Value(tokenScopeContextKey).(string)
+  if !ok {
+    return ""
+  }
+  return scope
+}
+
+// The readInt() helper reads an integer value from the query string. If the
+// key doesn't exist the provided default value is returned. If the value
+// can't be converted to a positive integer, an error is returned.
+func readInt(qs url.Values, key string, defaultValue int) (int, error) {
+  s := qs.Get(key)
+  if s == "" {
+    return defaultValue, nil
+  }
+
+  i, err := strconv.Atoi(s)
+  if err != nil || i < 1 {
+    return 0, fmt.Errorf("%s must be a positive integer", key)
+  }
+
+  return i, nil
+}
+
+// The parseTags() helper splits a comma-separated list of tags, as entered in
+// the snippet form, into a slice. Tags are trimmed and converted to lower
+// case, and any empty or duplicate tags are dropped.
+func parseTags(s string) []string {
+  var tags []string
+
+  for _, tag := range strings.Split(s, ",") {
+    tag = strings.ToLower(strings.TrimSpace(tag))
+    if tag != "" && !slices.Contains(tags, tag) {
+      tags = append(tags, tag)
+    }
+  }
+
+  return tags
+}
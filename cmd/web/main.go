@@ -1,6 +1,7 @@
 package main
 
 import (
+  "context"
   "crypto/tls"
   "database/sql"
   "flag"
@@ -8,6 +9,8 @@ import (
   "log/slog"
   "net/http"
   "os"
+  "strings"
+  "sync"
   "time"
 
   // Import the models package that we just created. You need to prefix this
@@ -16,11 +19,16 @@ import (
   // "{your-module-path}/internal/models". If you can't remember what module
   // path you used, you can find it at the top of the go.mod file.
   "github.com/kjloveless/snippetbox/internal/models"
+  "github.com/kjloveless/snippetbox/internal/config"
+  "github.com/kjloveless/snippetbox/internal/database"
+  "github.com/kjloveless/snippetbox/internal/migrations"
 
   "github.com/alexedwards/scs/mysqlstore"
+  "github.com/alexedwards/scs/postgresstore"
+  "github.com/alexedwards/scs/sqlite3store"
   "github.com/alexedwards/scs/v2"
   "github.com/go-playground/form/v4"
-  _ "github.com/go-sql-driver/mysql"
+  "github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 // Define an application struct to hold the application-wide dependencies for
@@ -30,39 +38,54 @@ import (
 // the SnippetModel object available to our handlers.
 // Add a templateCache field to the application struct.
 // Add a formDecoder field to hold a pointer to a form.Decoder instance.
+// The baseURL field holds the site's public URL, without a trailing slash,
+// or is empty if it hasn't been configured.
 type application struct {
   logger          *slog.Logger
   snippets        models.SnippetModelInterface
   users           models.UserModelInterface
+  revisions       models.RevisionModelInterface
+  tokens          models.TokenModelInterface
   templateCache   map[string]*template.Template
   formDecoder     *form.Decoder
   sessionManager  *scs.SessionManager
+  metrics         *metrics
+  baseURL         string
 }
 
 func main() {
-  // Define a new command-line flag with the name 'addr', a default value of
-  // ":4000" and some short help text explaining what the flag controls. The
-  // value of the flag will be stored in the addr variable at runtime.
-  addr := flag.String("addr", ":4000", "HTTP network address")
-  pass := flag.String("pass", "toor", "Password to use in DSN")
-  // Define a new command-line flag for the MySQL DSN string.
-  dsn := flag.String("dsn", "web:" + *pass + "@/snippetbox?parseTime=true", "MySQL data source name")
-
-  // Importantly, we use the flag.Parse() function to parse the command-line
-  // flag. This reads in the command-line flag value and assigns it to the addr
-  // variable. You need to call this *before* you use the addr variable 
-  // otherwise it will always contain the default value of ":4000". If any
-  // errors are encountered during parsing the application will be terminated.
-  flag.Parse()
+  // The settings come from command-line flags, SNIPPETBOX_* environment
+  // variables and an optional TOML config file, in that order of precedence.
+  // See the config package for the details. If anything is wrong with them we
+  // find out here, before we've connected to the database or started
+  // listening.
+  cfg := config.MustLoad(flag.CommandLine, os.Args[1:])
 
   // Use the slog.New() function to initialize a new structured logger, which
   // writes to the standard out stream and uses the default settings.
-  logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+  // The handler is wrapped so that entries logged with a request's context
+  // include its trace ID.
+  logger := slog.New(newTraceHandler(slog.NewTextHandler(os.Stdout, nil)))
 
-  // To keep the main() function tidy I've put the code for creating a
-  // connection pool into the separate openDB() function below. We pass
-  // openDB() the DSN from the command-line flag.
-  db, err := openDB(*dsn)
+  // Make it the default logger too. The session stores log any errors from
+  // their cleanup goroutines with the standard log package, and this sends
+  // those through our logger as well.
+  slog.SetDefault(logger)
+
+  // Set up tracing. With the default "none" exporter this only passes on the
+  // trace context from incoming requests, so that their trace IDs still show
+  // up in the logs.
+  shutdownTracing, err := setupTracing(context.Background(), cfg.TraceExporter,
+    cfg.OTLPEndpoint, cfg.TraceSampleRatio, os.Stdout)
+  if err != nil {
+    logger.Error(err.Error())
+    os.Exit(1)
+  }
+
+  // The code for creating a connection pool is shared with the snippetadmin
+  // command, in the database package. We pass database.Open() the database
+  // URL if there is one, or the DSN if not.
+  db, kind, err := database.Open(cfg.Source())
   if err != nil {
     logger.Error(err.Error())
     os.Exit(1)
@@ -72,6 +95,21 @@ func main() {
   // before the main() function exists.
   defer db.Close()
 
+  // If the migrate setting is on, bring the database schema up to date
+  // before anything uses it. The migrations package holds a database lock
+  // while it does this, so when several instances start at once only one of
+  // them migrates and the others wait for it to finish.
+  //
+  // A SQLite database is created empty the first time it's opened, and
+  // nobody else is going to set it up, so we always migrate it.
+  if cfg.Migrate || kind == migrations.SQLite {
+    err = migrateDB(db, kind, logger)
+    if err != nil {
+      logger.Error(err.Error())
+      os.Exit(1)
+    }
+  }
+
   // Initialize a new template cache...
   templateCache, err := newTemplateCache()
   if err != nil {
@@ -83,12 +121,12 @@ func main() {
   formDecoder := form.NewDecoder()
 
   // Use the scs.New() function to initialize a new session manager. Then we
-  // configure it to use our MySQL database as the session store, and set a
-  // lifetime of 12 hours (so that sessions automatically expire 12 hours after
-  // first being created).
+  // set the configured lifetime (12 hours by default), so that sessions
+  // automatically expire that long after first being created. The session
+  // store is set up below, along with the models, because it depends on which
+  // database we're using.
   sessionManager := scs.New()
-  sessionManager.Store = mysqlstore.New(db)
-  sessionManager.Lifetime = 12 * time.Hour
+  sessionManager.Lifetime = cfg.SessionLifetime
   // Make sure the the Secure attribute is set on our session cookies.
   // Setting this means that the cookie will only be sent by a user's web
   // browser when a HTTPS connections is being used (and won't be sent over an
@@ -101,13 +139,47 @@ func main() {
   // and add it to the application dependencies.
   app := &application{ 
     logger:         logger, 
-    snippets:       &models.SnippetModel{DB: db},
-    users:          &models.UserModel{DB: db},
     templateCache:  templateCache,
     formDecoder:    formDecoder,
     sessionManager: sessionManager,
+    metrics:        newMetrics(),
+    baseURL:        strings.TrimSuffix(cfg.BaseURL, "/"),
   }
 
+  // The models work with any of the databases, once they're told which one
+  // they're connected to. The session store has to match the database too.
+  app.snippets = &models.SnippetModel{DB: db, Dialect: kind}
+  app.users = &models.UserModel{DB: db, Dialect: kind, BcryptCost: cfg.BcryptCost}
+  app.revisions = &models.RevisionModel{DB: db, Dialect: kind}
+  app.tokens = &models.TokenModel{DB: db, Dialect: kind}
+
+  switch kind {
+  case migrations.Postgres:
+    sessionManager.Store = postgresstore.New(db)
+  case migrations.SQLite:
+    sessionManager.Store = sqlite3store.New(db)
+  default:
+    sessionManager.Store = mysqlstore.New(db)
+  }
+
+  // Count the session store's operations, and export the connection pool
+  // statistics, labelled with which database we're using.
+  sessionManager.Store = app.metrics.instrumentStore(sessionManager.Store)
+  app.metrics.registry.MustRegister(collectors.NewDBStatsCollector(db, kind))
+
+  // Give every query a deadline, so that a slow query is cancelled rather
+  // than left running after the request has been abandoned.
+  app.snippets = &models.TimedSnippetModel{Model: app.snippets, Timeout: cfg.QueryTimeout}
+  app.users = &models.TimedUserModel{Model: app.users, Timeout: cfg.QueryTimeout}
+  app.revisions = &models.TimedRevisionModel{Model: app.revisions, Timeout: cfg.QueryTimeout}
+  app.tokens = &models.TimedTokenModel{Model: app.tokens, Timeout: cfg.QueryTimeout}
+
+  // And record a span for each one, so that slow queries show up in traces.
+  app.snippets = &models.TracedSnippetModel{Model: app.snippets}
+  app.users = &models.TracedUserModel{Model: app.users}
+  app.revisions = &models.TracedRevisionModel{Model: app.revisions}
+  app.tokens = &models.TracedTokenModel{Model: app.tokens}
+
   // Initialize a tls.Config struct to hold the non-default TLS setttings we
   // want the server to use. In this case that only thing that we're changing
   // is the curve preferences value, so that only elliptic curves with assembly
@@ -119,7 +191,7 @@ func main() {
   // Initialize a new http.Server struct. We set the Addr and Handler fields so
   // that the server uses the same network address and routes as before.
   srv := &http.Server{
-    Addr:       *addr,
+    Addr:       cfg.Addr,
     Handler:    app.routes(),
     // Create a *log.Logger from our structured logger handler, which writes
     // log entries at Error level, and assigns it to the ErrorLog field. If
@@ -128,40 +200,99 @@ func main() {
     ErrorLog:   slog.NewLogLogger(logger.Handler(), slog.LevelError),
     TLSConfig:  tlsConfig,
     // Add Idle, Read, and Write timeouts to the server.
-    IdleTimeout:    time.Minute,
-    ReadTimeout:    5 * time.Second,
-    WriteTimeout:   10 * time.Second,
+    IdleTimeout:    cfg.IdleTimeout,
+    ReadTimeout:    cfg.ReadTimeout,
+    WriteTimeout:   cfg.WriteTimeout,
+  }
+
+  // Start the reaper in a background goroutine. Cancelling the context stops
+  // it, and the WaitGroup lets us wait for it to finish before we exit, so
+  // that it isn't killed part way through a batch.
+  reaperCtx, stopReaper := context.WithCancel(context.Background())
+  var reaper sync.WaitGroup
+  if cfg.ReapInterval > 0 {
+    reaper.Add(1)
+    go func() {
+      defer reaper.Done()
+      app.reapExpired(reaperCtx, cfg.ReapInterval, cfg.ReapBatch, cfg.BurnedRetention)
+    }()
+  }
+
+  // Start the metrics server, unless it's been turned off. This listens on a
+  // separate (by default, local-only) address, so that the metrics aren't
+  // public.
+  var metricsSrv *http.Server
+  if cfg.MetricsAddr != "" {
+    metricsSrv, err = app.startMetricsServer(cfg.MetricsAddr)
+    if err != nil {
+      logger.Error(err.Error())
+      os.Exit(1)
+    }
   }
 
   // Use the Info() method to log the starting server message at Info severity
   // (along with the listen address as an attribute).
   logger.Info("starting server", "addr", srv.Addr)
 
-  // Use the ListenAndServeTLS() method to start the HTTPS server. We
-  // pass in the paths to the TLS certificate and corresponding private key as
-  // the two parameters.
-  err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+  // Run the HTTPS server with the serve() method, which we pass a function
+  // that starts it by calling ListenAndServeTLS() with the paths to the TLS
+  // certificate and private key. serve() blocks until we get a signal to shut
+  // down, and then lets in-flight requests finish before returning.
+  err = app.serve(srv, cfg.DrainTimeout, func() error {
+    return srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+  })
 
-  // And we also use the Error() method to log any error message returned by
-  // http.ListenAndServe() at Error severity (with no additional attributes),
-  // and then call os.Exit(1) to terminate the application with exit code 1.
-  logger.Error(err.Error())
-  os.Exit(1)
-}
+  // Once the server has stopped, stop the background workers too: the reaper,
+  // and the goroutine which deletes expired sessions (which all of our
+  // session stores have).
+  stopReaper()
+  reaper.Wait()
 
-// The openDB() function wraps sql.Open() and returns a sql.DB connection pool
-// for a given DSN.
-func openDB(dsn string) (*sql.DB, error) {
-  db, err := sql.Open("mysql", dsn)
-  if err != nil {
-    return nil, err
+  if metricsSrv != nil {
+    metricsSrv.Close()
+  }
+
+  if store, ok := sessionManager.Store.(interface{ StopCleanup() }); ok {
+    store.StopCleanup()
   }
 
-  err = db.Ping()
+  // Export any spans which are still waiting to be sent. We don't wait
+  // forever though, in case the collector has gone away.
+  flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancelFlush()
+
+  if flushErr := shutdownTracing(flushCtx); flushErr != nil {
+    logger.Error(flushErr.Error())
+  }
+
+  // If the server failed, we use the Error() method to log the error message
+  // at Error severity (with no additional attributes), and then call
+  // os.Exit(1) to terminate the application with exit code 1. os.Exit()
+  // doesn't run deferred calls, so we close the connection pool first.
   if err != nil {
+    logger.Error(err.Error())
     db.Close()
-    return nil, err
+    os.Exit(1)
+  }
+
+  // Otherwise we've shut down cleanly, so we return from main(), which closes
+  // the connection pool and exits with code 0. Our logger writes each entry
+  // to stdout as soon as it's logged, and the spans were flushed above, so
+  // there's nothing left to flush.
+}
+
+// The migrateDB() function applies any pending database migrations, and logs
+// each one that it applies.
+func migrateDB(db *sql.DB, kind string, logger *slog.Logger) error {
+  migrator, err := migrations.New(db, kind)
+  if err != nil {
+    return err
+  }
+
+  applied, err := migrator.Up(context.Background())
+  for _, m := range applied {
+    logger.Info("applied migration", "migration", m.String())
   }
 
-  return db, nil
+  return err
 }
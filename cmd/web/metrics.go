@@ -0,0 +1,173 @@
+package main
+
+import (
+  "errors"
+  "net"
+  "net/http"
+  "strconv"
+  "time"
+
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/client_golang/prometheus/collectors"
+  "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The metrics struct holds the Prometheus metrics which the application
+// records. They're kept in their own registry, rather than the global default
+// one, so that each test application gets a fresh set.
+type metrics struct {
+  registry         *prometheus.Registry
+  requests         *prometheus.CounterVec
+  requestDuration  *prometheus.HistogramVec
+  requestsInFlight prometheus.Gauge
+  sessionStoreOps  *prometheus.CounterVec
+  templateRender   *prometheus.HistogramVec
+  snippetsCreated  *prometheus.CounterVec
+}
+
+// The newMetrics() function creates and registers the application's metrics,
+// along with the standard ones for the Go runtime and the process.
+func newMetrics() *metrics {
+  m := &metrics{
+    registry: prometheus.NewRegistry(),
+    requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+      Name: "snippetbox_http_requests_total",
+      Help: "HTTP requests handled, by route pattern and status class.",
+    }, []string{"route", "status"}),
+    requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+      Name:    "snippetbox_http_request_duration_seconds",
+      Help:    "Time taken to handle HTTP requests, by route pattern and status class.",
+      Buckets: prometheus.DefBuckets,
+    }, []string{"route", "status"}),
+    requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+      Name: "snippetbox_http_requests_in_flight",
+      Help: "HTTP requests currently being handled.",
+    }),
+    sessionStoreOps: prometheus.NewCounterVec(prometheus.CounterOpts{
+      Name: "snippetbox_session_store_operations_total",
+      Help: "Session store operations, by operation and result.",
+    }, []string{"operation", "result"}),
+    templateRender: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+      Name:    "snippetbox_template_render_duration_seconds",
+      Help:    "Time taken to execute page templates, by page.",
+      Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25},
+    }, []string{"page"}),
+    snippetsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+      Name: "snippetbox_snippets_created_total",
+      Help: "Snippets created, by where they were created from (web, api or paste).",
+    }, []string{"source"}),
+  }
+
+  m.registry.MustRegister(
+    m.requests,
+    m.requestDuration,
+    m.requestsInFlight,
+    m.sessionStoreOps,
+    m.templateRender,
+    m.snippetsCreated,
+    collectors.NewGoCollector(),
+    collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+  )
+
+  return m
+}
+
+// The statusRecorder type wraps a http.ResponseWriter and remembers the
+// status code which was written. The Unwrap() method lets
+// http.ResponseController get at the underlying ResponseWriter, so that
+// things like flushing still work.
+type statusRecorder struct {
+  http.ResponseWriter
+  status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+  if rec.status == 0 {
+    rec.status = status
+  }
+  rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+  if rec.status == 0 {
+    rec.status = http.StatusOK
+  }
+  return rec.ResponseWriter.Write(b)
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+  return rec.ResponseWriter
+}
+
+// The instrument middleware records how many requests we handle, how long
+// they take, and how many are in flight. Requests are labelled with the
+// pattern of the route which handled them (like "GET /snippet/view/{slug}")
+// rather than their URL, so that there's one series per route instead of one
+// per snippet. The servemux sets r.Pattern when it picks a route, so this
+// only works if none of the middleware between here and the servemux replaces
+// the request -- which is why it goes right at the start of the chain.
+func (app *application) instrument(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    app.metrics.requestsInFlight.Inc()
+    defer app.metrics.requestsInFlight.Dec()
+
+    start := time.Now()
+    rec := &statusRecorder{ResponseWriter: w}
+
+    next.ServeHTTP(rec, r)
+
+    // Requests which don't match any route have an empty pattern.
+    route := r.Pattern
+    if route == "" {
+      route = "unmatched"
+    }
+
+    // A handler which doesn't write anything sends a 200 OK.
+    status := rec.status
+    if status == 0 {
+      status = http.StatusOK
+    }
+    class := strconv.Itoa(status/100) + "xx"
+
+    app.metrics.requests.WithLabelValues(route, class).Inc()
+    app.metrics.requestDuration.WithLabelValues(route, class).Observe(time.Since(start).Seconds())
+  })
+}
+
+// The startMetricsServer() method starts a plain HTTP server on addr which
+// serves the metrics at /metrics, in the Prometheus text format. It's kept
+// separate from the main server so that it can listen on an address which
+// isn't public. We start listening before returning, so that a problem with
+// the address is reported at startup.
+func (app *application) startMetricsServer(addr string) (*http.Server, error) {
+  ln, err := net.Listen("tcp", addr)
+  if err != nil {
+    return nil, err
+  }
+
+  mux := http.NewServeMux()
+  mux.Handle("GET /metrics", app.metricsHandler())
+
+  srv := &http.Server{
+    Handler:      mux,
+    ReadTimeout:  5 * time.Second,
+    WriteTimeout: 10 * time.Second,
+  }
+
+  app.logger.Info("starting metrics server", "addr", ln.Addr().String())
+
+  go func() {
+    err := srv.Serve(ln)
+    if err != nil && !errors.Is(err, http.ErrServerClosed) {
+      app.logger.Error(err.Error())
+    }
+  }()
+
+  return srv, nil
+}
+
+// The metricsHandler() method returns a handler which writes out the
+// metrics.
+func (app *application) metricsHandler() http.Handler {
+  return promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{})
+}
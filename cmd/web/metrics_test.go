@@ -0,0 +1,67 @@
+package main
+
+import (
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+)
+
+// scrapeMetrics() fetches the application's metrics in the Prometheus text
+// format, the same way Prometheus would.
+func scrapeMetrics(t *testing.T, app *application) string {
+  rr := httptest.NewRecorder()
+  r, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  app.metricsHandler().ServeHTTP(rr, r)
+  assert.Equal(t, rr.Code, http.StatusOK)
+
+  return rr.Body.String()
+}
+
+func TestInstrument(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  ts.get(t, "/snippet/view/s1lentPd")
+  ts.get(t, "/snippet/view/s1lentPd")
+  ts.get(t, "/snippet/view/m1ss1ng0")
+  ts.get(t, "/no/such/page")
+
+  metrics := scrapeMetrics(t, app)
+
+  // Requests are counted by the route's pattern, not their URL...
+  assert.StringContains(t, metrics, `snippetbox_http_requests_total{route="GET /snippet/view/{slug}",status="2xx"} 2`)
+  assert.StringContains(t, metrics, `snippetbox_http_requests_total{route="GET /snippet/view/{slug}",status="4xx"} 1`)
+  // ...and requests which don't match a route are counted together.
+  assert.StringContains(t, metrics, `snippetbox_http_requests_total{route="unmatched",status="4xx"} 1`)
+
+  assert.StringContains(t, metrics, `snippetbox_http_request_duration_seconds_count{route="GET /snippet/view/{slug}",status="2xx"} 2`)
+  assert.StringContains(t, metrics, `snippetbox_http_requests_in_flight 0`)
+  assert.StringContains(t, metrics, `snippetbox_template_render_duration_seconds_count{page="view.tmpl"} 2`)
+}
+
+func TestSnippetsCreatedMetric(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  code, _, _ := ts.apiRequest(t, http.MethodPost, "/p", aliceToken, "an old silent pond...")
+  assert.Equal(t, code, http.StatusCreated)
+
+  // A paste which fails validation isn't counted.
+  code, _, _ = ts.apiRequest(t, http.MethodPost, "/p?expires=soon", aliceToken, "an old silent pond...")
+  assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+  metrics := scrapeMetrics(t, app)
+  assert.StringContains(t, metrics, `snippetbox_snippets_created_total{source="paste"} 1`)
+  if strings.Contains(metrics, `source="web"`) {
+    t.Errorf("got a web snippet creation:\n%s", metrics)
+  }
+}
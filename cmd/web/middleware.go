@@ -2,8 +2,12 @@ package main
 
 import (
   "context"
+  "errors"
   "fmt"
   "net/http"
+  "strings"
+
+  "github.com/kjloveless/snippetbox/internal/models"
 
   "github.com/justinas/nosurf"
 )
@@ -35,7 +39,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
     // Otherwise, we check to see if a user with that ID exists in our
     // database.
-    exists, err := app.users.Exists(id)
+    exists, err := app.users.Exists(r.Context(), id)
     if err != nil {
       app.serverError(w, r, err)
       return
@@ -43,10 +47,11 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
     // If a matching user is found, we know that the request is coming from an
     // authenticated user who exists in our database. We create a new copy of
-    // the request (with an isAuthenticatedContextKey value of true in the
-    // request context) and assign it to r.
+    // the request (with an isAuthenticatedContextKey value of true and the
+    // user's ID in the request context) and assign it to r.
     if exists {
       ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+      ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
       r = r.WithContext(ctx)
     }
 
@@ -84,7 +89,7 @@ func (app *application) logRequest(next http.Handler) http.Handler {
       uri     = r.URL.RequestURI()
     )
 
-    app.logger.Info("received request", "ip", ip, "proto", proto, 
+    app.logger.InfoContext(r.Context(), "received request", "ip", ip, "proto", proto, 
       "method", method, "uri", uri)
 
     next.ServeHTTP(w, r)
@@ -127,3 +132,90 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
     next.ServeHTTP(w, r)
   })
 }
+
+// The authenticateToken() middleware is the JSON API's equivalent of
+// authenticate(). Instead of a session cookie, it looks for an API token in an
+// "Authorization: Bearer <token>" header. Requests without the header carry on
+// as anonymous requests, but a header with a bad token gets a 401
+// Unauthorized response, so that a client with a typo in its token finds out
+// straight away rather than silently seeing only public snippets.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    // Let caches know that the response depends on the Authorization header.
+    w.Header().Add("Vary", "Authorization")
+
+    header := r.Header.Get("Authorization")
+    if header == "" {
+      next.ServeHTTP(w, r)
+      return
+    }
+
+    token, ok := strings.CutPrefix(header, "Bearer ")
+    if !ok || token == "" {
+      app.invalidTokenJSON(w, r)
+      return
+    }
+
+    t, err := app.tokens.Authenticate(r.Context(), token)
+    if err != nil {
+      if errors.Is(err, models.ErrInvalidCredentials) {
+        app.invalidTokenJSON(w, r)
+      } else {
+        app.serverErrorJSON(w, r, err)
+      }
+      return
+    }
+
+    // Just like authenticate(), check that the user still exists before
+    // adding their ID to the request context.
+    exists, err := app.users.Exists(r.Context(), t.UserID)
+    if err != nil {
+      app.serverErrorJSON(w, r, err)
+      return
+    }
+    if !exists {
+      app.invalidTokenJSON(w, r)
+      return
+    }
+
+    // Fill in the same context values as authenticate() does, so that
+    // handlers don't need to care how the user was authenticated, and add
+    // the token's scope too.
+    ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+    ctx = context.WithValue(ctx, authenticatedUserIDContextKey, t.UserID)
+    ctx = context.WithValue(ctx, tokenScopeContextKey, t.Scope)
+
+    next.ServeHTTP(w, r.WithContext(ctx))
+  })
+}
+
+// The requireAPIAuthentication() middleware works like
+// requireAuthentication(), except that there's no login page to redirect to,
+// so it sends a 401 Unauthorized JSON response instead.
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if !app.isAuthenticated(r) {
+      w.Header().Set("WWW-Authenticate", "Bearer")
+      app.errorJSON(w, r, http.StatusUnauthorized, "you must be authenticated to access this resource")
+      return
+    }
+
+    w.Header().Add("Cache-Control", "no-store")
+
+    next.ServeHTTP(w, r)
+  })
+}
+
+// The requireWriteScope() middleware sends a 403 Forbidden JSON response if
+// the request was authenticated with a read-only API token. It should come
+// after requireAPIAuthentication() in the middleware chain.
+func (app *application) requireWriteScope(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if app.tokenScope(r) != models.ScopeWrite {
+      app.errorJSON(w, r, http.StatusForbidden, "your token doesn't have the write scope needed to access this resource")
+      return
+    }
+
+    next.ServeHTTP(w, r)
+  })
+}
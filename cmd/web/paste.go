@@ -0,0 +1,136 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "slices"
+  "strings"
+  "unicode/utf8"
+
+  "github.com/kjloveless/snippetbox/internal/models"
+)
+
+// maxPasteBytes is the largest snippet which can be created through the
+// plain-text paste endpoint.
+const maxPasteBytes = 1_048_576
+
+// defaultPasteTitle is used for pastes which don't say what their title is.
+const defaultPasteTitle = "Untitled"
+
+// pasteParam() returns a setting for the paste endpoint. Settings can be given
+// as a query string parameter (like ?expires_at=...) or as a header with an
+// "X-" prefix and dashes instead of underscores (like X-Expires-At), which is
+// handy when the URL is fixed in a shell alias. The query string wins if both
+// are given.
+func pasteParam(r *http.Request, name string) string {
+  if value := r.URL.Query().Get(name); value != "" {
+    return value
+  }
+  return r.Header.Get("X-" + strings.ReplaceAll(name, "_", "-"))
+}
+
+// The pastePost() handler creates a snippet from the raw request body, so that
+// the output of a command can be piped straight into curl:
+//
+//   some-command | curl -H "Authorization: Bearer $TOKEN" --data-binary @- https://host/p
+//
+// The title, language, visibility and expiry can be set with pasteParam(); by
+// default pastes are unlisted and expire in a year. The URL of the new snippet
+// is sent back as plain text, so that it can be used in a script. Everything
+// here is plain text (including errors), because the client is a terminal.
+func (app *application) pastePost(w http.ResponseWriter, r *http.Request) {
+  // Errors from the token middleware are JSON, but anything which gets this
+  // far is told what went wrong in plain text.
+  if !app.isAuthenticated(r) {
+    w.Header().Set("WWW-Authenticate", "Bearer")
+    http.Error(w, "you must send an API token to create a paste", http.StatusUnauthorized)
+    return
+  }
+  if app.tokenScope(r) != models.ScopeWrite {
+    http.Error(w, "your API token doesn't have the write scope", http.StatusForbidden)
+    return
+  }
+
+  // Read the body directly, rather than with decodePostForm(). Pastes aren't
+  // form-encoded, even though curl's --data-binary says they are.
+  r.Body = http.MaxBytesReader(w, r.Body, maxPasteBytes)
+
+  body, err := io.ReadAll(r.Body)
+  if err != nil {
+    var maxBytesError *http.MaxBytesError
+    if errors.As(err, &maxBytesError) {
+      http.Error(w, fmt.Sprintf("pastes must not be larger than %d bytes", maxBytesError.Limit),
+        http.StatusRequestEntityTooLarge)
+    } else {
+      app.clientError(w, http.StatusBadRequest)
+    }
+    return
+  }
+
+  if !utf8.Valid(body) {
+    http.Error(w, "pastes must be UTF-8 text", http.StatusBadRequest)
+    return
+  }
+
+  input := apiSnippetInput{
+    Title:      pasteParam(r, "title"),
+    Content:    string(body),
+    Language:   pasteParam(r, "language"),
+    Visibility: pasteParam(r, "visibility"),
+    Expires:    pasteParam(r, "expires"),
+    ExpiresAt:  pasteParam(r, "expires_at"),
+  }
+  if input.Title == "" {
+    input.Title = defaultPasteTitle
+  }
+  if input.Visibility == "" {
+    input.Visibility = models.VisibilityUnlisted
+  }
+  if input.Expires == "" {
+    input.Expires = "365d"
+  }
+
+  // The settings are checked with the same rules as the API and snippet form,
+  // and any problems are listed one per line.
+  form, expires := input.validate()
+  if !form.Valid() {
+    var lines []string
+    for field, message := range form.FieldErrors {
+      lines = append(lines, fmt.Sprintf("%s: %s", field, message))
+    }
+    slices.Sort(lines)
+
+    http.Error(w, strings.Join(lines, "\n"), http.StatusUnprocessableEntity)
+    return
+  }
+
+  snippet := models.Snippet{
+    Title:      form.Title,
+    Content:    form.Content,
+    Language:   form.Language,
+    Visibility: form.Visibility,
+    UserID:     app.authenticatedUserID(r),
+    Expires:    expires,
+  }
+
+  slug, err := app.snippets.Insert(r.Context(), snippet)
+  if err != nil {
+    app.serverError(w, r, err)
+    return
+  }
+
+  app.metrics.snippetsCreated.WithLabelValues("paste").Inc()
+
+  // Send back the full URL of the snippet, since a bare path isn't much use
+  // in a terminal. We can't trust the Host header to build it from (anyone
+  // can send any host they like), so it's only possible when the site's
+  // base URL has been configured. Otherwise we send the path on its own.
+  path := fmt.Sprintf("/snippet/view/%s", slug)
+
+  w.Header().Set("Location", path)
+  w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+  w.WriteHeader(http.StatusCreated)
+  fmt.Fprintf(w, "%s%s\n", app.baseURL, path)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+  "io"
+  "net/http"
+  "strings"
+  "testing"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+)
+
+func TestPastePost(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  tests := []struct {
+    name     string
+    urlPath  string
+    token    string
+    body     string
+    wantCode int
+    wantBody string
+  }{
+    {
+      name:     "Valid paste",
+      urlPath:  "/p",
+      token:    aliceToken,
+      body:     "an old silent pond...",
+      wantCode: http.StatusCreated,
+      wantBody: "/snippet/view/s1lentPd",
+    },
+    {
+      name:     "With settings",
+      urlPath:  "/p?title=haiku&language=plaintext&expires=1h&visibility=private",
+      token:    aliceToken,
+      body:     "an old silent pond...",
+      wantCode: http.StatusCreated,
+      wantBody: "/snippet/view/s1lentPd",
+    },
+    {
+      name:     "Invalid settings",
+      urlPath:  "/p?expires=soon&visibility=secret",
+      token:    aliceToken,
+      body:     "an old silent pond...",
+      wantCode: http.StatusUnprocessableEntity,
+      wantBody: "expires: this field must be one of the listed options\nvisibility: this field must equal public, unlisted, or private",
+    },
+    {
+      name:     "Empty body",
+      urlPath:  "/p",
+      token:    aliceToken,
+      wantCode: http.StatusUnprocessableEntity,
+      wantBody: "content: this field cannot be blank",
+    },
+    {
+      name:     "Too large",
+      urlPath:  "/p",
+      token:    aliceToken,
+      body:     strings.Repeat("a", maxPasteBytes+1),
+      wantCode: http.StatusRequestEntityTooLarge,
+    },
+    {
+      name:     "Not UTF-8",
+      urlPath:  "/p",
+      token:    aliceToken,
+      body:     "\xff\xfe",
+      wantCode: http.StatusBadRequest,
+    },
+    {
+      name:     "No token",
+      urlPath:  "/p",
+      body:     "an old silent pond...",
+      wantCode: http.StatusUnauthorized,
+    },
+    {
+      name:     "Read-only token",
+      urlPath:  "/p",
+      token:    aliceReadToken,
+      body:     "an old silent pond...",
+      wantCode: http.StatusForbidden,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      code, header, body := ts.apiRequest(t, http.MethodPost, tt.urlPath, tt.token, tt.body)
+
+      assert.Equal(t, code, tt.wantCode)
+      assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+
+      if tt.wantBody != "" {
+        assert.Equal(t, body, tt.wantBody)
+      }
+    })
+  }
+}
+
+func TestPastePostBaseURL(t *testing.T) {
+  app := newTestApplication(t)
+  app.baseURL = "https://snippets.example.com"
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  // With a base URL configured, the full URL of the snippet is sent back,
+  // whatever Host header the request had.
+  code, header, body := ts.apiRequest(t, http.MethodPost, "/p", aliceToken, "an old silent pond...")
+
+  assert.Equal(t, code, http.StatusCreated)
+  assert.Equal(t, header.Get("Location"), "/snippet/view/s1lentPd")
+  assert.Equal(t, body, "https://snippets.example.com/snippet/view/s1lentPd")
+}
+
+func TestPastePostHeaders(t *testing.T) {
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  // Settings can also be given as headers, and the query string wins when
+  // both are given.
+  req, err := http.NewRequest(http.MethodPost, ts.URL + "/p?expires=1h", strings.NewReader("an old silent pond..."))
+  if err != nil {
+    t.Fatal(err)
+  }
+  req.Header.Set("Authorization", "Bearer " + aliceToken)
+  req.Header.Set("X-Expires", "soon")
+  req.Header.Set("X-Visibility", "secret")
+
+  rs, err := ts.Client().Do(req)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer rs.Body.Close()
+
+  body, err := io.ReadAll(rs.Body)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  assert.Equal(t, rs.StatusCode, http.StatusUnprocessableEntity)
+  assert.Equal(t, strings.TrimSpace(string(body)), "visibility: this field must equal public, unlisted, or private")
+}
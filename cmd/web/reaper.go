@@ -0,0 +1,73 @@
+package main
+
+import (
+  "context"
+  "time"
+)
+
+// The reapExpired() method runs in a background goroutine, permanently
+// deleting expired snippets every interval until the context is cancelled.
+// Expired snippets are already hidden by the model queries, so this is only
+// about stopping the snippets table from growing forever. For the same
+// reason, it also forgets burn-after-reading snippets which were burned more
+// than burnedRetention ago.
+func (app *application) reapExpired(ctx context.Context, interval time.Duration, batchSize int, burnedRetention time.Duration) {
+  ticker := time.NewTicker(interval)
+  defer ticker.Stop()
+
+  for {
+    select {
+    case <-ctx.Done():
+      return
+    case <-ticker.C:
+      app.purgeExpired(ctx, batchSize)
+      app.purgeBurned(ctx, burnedRetention)
+    }
+  }
+}
+
+// The purgeExpired() method deletes every snippet which has expired, in
+// batches of at most batchSize, and logs how many were deleted.
+func (app *application) purgeExpired(ctx context.Context, batchSize int) {
+  // Use the same cut-off time for every batch, so that we don't chase
+  // snippets which expire while we're working.
+  before := time.Now()
+  total := 0
+
+  for {
+    n, err := app.snippets.PurgeExpired(ctx, before, batchSize)
+    total += n
+    if err != nil {
+      // Errors caused by the context being cancelled during shutdown aren't
+      // worth logging.
+      if ctx.Err() == nil {
+        app.logger.Error(err.Error(), "task", "reaper")
+      }
+      break
+    }
+
+    if n < batchSize {
+      break
+    }
+  }
+
+  if total > 0 {
+    app.logger.Info("purged expired snippets", "count", total)
+  }
+}
+
+// The purgeBurned() method forgets the burn-after-reading snippets which were
+// burned more than retention ago, and logs how many there were.
+func (app *application) purgeBurned(ctx context.Context, retention time.Duration) {
+  n, err := app.snippets.PurgeBurned(ctx, time.Now().Add(-retention))
+  if err != nil {
+    if ctx.Err() == nil {
+      app.logger.Error(err.Error(), "task", "reaper")
+    }
+    return
+  }
+
+  if n > 0 {
+    app.logger.Info("purged burned snippets", "count", n)
+  }
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+  "context"
+  "testing"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+  "github.com/kjloveless/snippetbox/internal/models/mocks"
+)
+
+// expiredSnippets wraps the mock snippet model with a count of expired
+// snippets, so that we can check how purgeExpired() works through them.
+type expiredSnippets struct {
+  mocks.SnippetModel
+  remaining int
+  calls     int
+}
+
+func (m *expiredSnippets) PurgeExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+  m.calls++
+  n := min(limit, m.remaining)
+  m.remaining -= n
+  return n, nil
+}
+
+func TestPurgeExpired(t *testing.T) {
+  app := newTestApplication(t)
+  snippets := &expiredSnippets{remaining: 25}
+  app.snippets = snippets
+
+  app.purgeExpired(context.Background(), 10)
+
+  // 25 snippets in batches of 10 takes three queries.
+  assert.Equal(t, snippets.remaining, 0)
+  assert.Equal(t, snippets.calls, 3)
+}
+
+// burnedSnippets wraps the mock snippet model to record the cut-off time
+// passed to PurgeBurned().
+type burnedSnippets struct {
+  mocks.SnippetModel
+  before time.Time
+}
+
+func (m *burnedSnippets) PurgeBurned(ctx context.Context, before time.Time) (int, error) {
+  m.before = before
+  return 1, nil
+}
+
+func TestPurgeBurned(t *testing.T) {
+  app := newTestApplication(t)
+  snippets := &burnedSnippets{}
+  app.snippets = snippets
+
+  start := time.Now()
+  app.purgeBurned(context.Background(), time.Hour)
+
+  // Snippets burned more than an hour ago are forgotten.
+  cutoff := start.Add(-time.Hour)
+  if snippets.before.Before(cutoff) || snippets.before.After(cutoff.Add(time.Second)) {
+    t.Errorf("got cut-off time %v; want about %v", snippets.before, cutoff)
+  }
+}
+
+func TestReapExpiredStops(t *testing.T) {
+  app := newTestApplication(t)
+
+  ctx, cancel := context.WithCancel(context.Background())
+  done := make(chan struct{})
+
+  go func() {
+    app.reapExpired(ctx, time.Millisecond, 10, time.Hour)
+    close(done)
+  }()
+
+  cancel()
+
+  select {
+  case <-done:
+  case <-time.After(time.Second):
+    t.Fatal("reaper didn't stop when its context was cancelled")
+  }
+}
@@ -36,7 +36,13 @@ func (app *application) routes() http.Handler {
   // method returns a http.Handler (rather tha a http.HandlerFunc) we also need
   // to switch to registering the route using the mux.Handle() method.
   mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
-  mux.Handle("GET /snippet/view/{id}", dynamic.ThenFunc(app.snippetView))
+  mux.Handle("GET /snippets", dynamic.ThenFunc(app.snippetList))
+  mux.Handle("GET /tags/{tag}", dynamic.ThenFunc(app.tagView))
+  mux.Handle("GET /search", dynamic.ThenFunc(app.search))
+  mux.Handle("GET /snippet/view/{slug}", dynamic.ThenFunc(app.snippetView))
+  mux.Handle("GET /snippet/raw/{slug}", dynamic.ThenFunc(app.snippetRaw))
+  mux.Handle("GET /snippet/view/{slug}/history", dynamic.ThenFunc(app.snippetHistory))
+  mux.Handle("GET /snippet/view/{slug}/diff", dynamic.ThenFunc(app.snippetDiff))
   mux.Handle("GET /user/signup", dynamic.ThenFunc(app.userSignup))
   mux.Handle("POST /user/signup", dynamic.ThenFunc(app.userSignupPost))
   mux.Handle("GET /user/login", dynamic.ThenFunc(app.userLogin))
@@ -48,11 +54,40 @@ func (app *application) routes() http.Handler {
   
   mux.Handle("GET /snippet/create", protected.ThenFunc(app.snippetCreate))
   mux.Handle("POST /snippet/create", protected.ThenFunc(app.snippetCreatePost))
+  mux.Handle("GET /snippet/edit/{slug}", protected.ThenFunc(app.snippetEdit))
+  mux.Handle("POST /snippet/edit/{slug}", protected.ThenFunc(app.snippetEditPost))
+  mux.Handle("POST /snippet/delete/{slug}", protected.ThenFunc(app.snippetDeletePost))
   mux.Handle("POST /user/logout", protected.ThenFunc(app.userLogoutPost))
+  mux.Handle("GET /account", protected.ThenFunc(app.account))
+  mux.Handle("POST /account/tokens", protected.ThenFunc(app.accountTokenCreatePost))
+  mux.Handle("POST /account/tokens/revoke/{id}", protected.ThenFunc(app.accountTokenRevokePost))
+
+  // The JSON API gets its own middleware chain. API clients aren't browsers,
+  // so there is no session cookie and no CSRF check -- instead requests are
+  // authenticated with an API token in the Authorization header. Routes which
+  // change anything also require the token to have the write scope.
+  api := alice.New(app.authenticateToken)
+  apiProtected := api.Append(app.requireAPIAuthentication, app.requireWriteScope)
+
+  mux.Handle("GET /api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+  mux.Handle("GET /api/v1/snippets/{slug}", api.ThenFunc(app.apiSnippetView))
+  mux.Handle("GET /api/v1/search", api.ThenFunc(app.apiSearch))
+  mux.Handle("POST /api/v1/snippets", apiProtected.ThenFunc(app.apiSnippetCreate))
+  mux.Handle("PATCH /api/v1/snippets/{slug}", apiProtected.ThenFunc(app.apiSnippetUpdate))
+  mux.Handle("DELETE /api/v1/snippets/{slug}", apiProtected.ThenFunc(app.apiSnippetDelete))
+  mux.Handle("/api/", api.ThenFunc(app.apiNotFound(mux)))
+
+  // The plain-text paste endpoint is for use from the command line, so like
+  // the API it authenticates with a token rather than a session.
+  mux.Handle("POST /p", api.ThenFunc(app.pastePost))
 
   // Create a middleware chain containing our 'standard' middleware which will
-  // be used for every request our application receives.
-  standard := alice.New(app.recoverPanic, app.logRequest, commonHeaders)
+  // be used for every request our application receives. The trace and
+  // instrument middleware come first, so that they see every response
+  // (including the ones written by recoverPanic) and the route pattern set by
+  // the servemux, and so that the logs written by the rest of the chain
+  // include the trace ID.
+  standard := alice.New(app.trace, app.instrument, app.recoverPanic, app.logRequest, commonHeaders)
 
   return standard.Then(mux)
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+  "context"
+  "errors"
+  "net/http"
+  "os"
+  "os/signal"
+  "syscall"
+  "time"
+)
+
+// The serve() method runs the HTTP server until it's told to stop. listen
+// should start the server (e.g. by calling srv.ListenAndServeTLS()) and block
+// until it stops.
+//
+// When the process receives SIGINT or SIGTERM (which is what most process
+// managers send during a deploy), we call srv.Shutdown(). This closes the
+// listeners so that no new connections are accepted, then waits for the
+// requests which are already in flight to finish. If they take longer than
+// drainTimeout the remaining connections are cut off and an error is
+// returned. A clean shutdown returns nil.
+func (app *application) serve(srv *http.Server, drainTimeout time.Duration, listen func() error) error {
+  // Start listening for signals before the server starts, so that there's no
+  // moment when a signal would kill the process outright.
+  quit := make(chan os.Signal, 1)
+  signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+  defer signal.Stop(quit)
+
+  // The done channel stops the goroutine below if the server fails to start.
+  done := make(chan struct{})
+  defer close(done)
+
+  shutdownErr := make(chan error, 1)
+
+  go func() {
+    select {
+    case s := <-quit:
+      app.logger.Info("shutting down server", "signal", s.String())
+
+      ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+      defer cancel()
+
+      shutdownErr <- srv.Shutdown(ctx)
+    case <-done:
+    }
+  }()
+
+  // Once Shutdown() has been called, listen returns http.ErrServerClosed
+  // straight away, without waiting for the in-flight requests. So it's only
+  // when Shutdown() returns that we know they've all finished.
+  err := listen()
+  if !errors.Is(err, http.ErrServerClosed) {
+    return err
+  }
+
+  err = <-shutdownErr
+  if err != nil {
+    return err
+  }
+
+  app.logger.Info("stopped server", "addr", srv.Addr)
+  return nil
+}
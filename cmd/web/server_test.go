@@ -0,0 +1,87 @@
+package main
+
+import (
+  "io"
+  "net"
+  "net/http"
+  "os"
+  "syscall"
+  "testing"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+)
+
+func TestServeGracefulShutdown(t *testing.T) {
+  app := newTestApplication(t)
+
+  // The slow handler lets us know when it has started, and then takes a
+  // while to respond, so that the shutdown happens while it's in flight.
+  started := make(chan struct{})
+  mux := http.NewServeMux()
+  mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+    close(started)
+    time.Sleep(200 * time.Millisecond)
+    w.Write([]byte("finished"))
+  })
+
+  ln, err := net.Listen("tcp", "127.0.0.1:0")
+  assert.NilError(t, err)
+
+  srv := &http.Server{Handler: mux}
+
+  served := make(chan error, 1)
+  go func() {
+    served <- app.serve(srv, 5*time.Second, func() error {
+      return srv.Serve(ln)
+    })
+  }()
+
+  type response struct {
+    body string
+    err  error
+  }
+  responses := make(chan response, 1)
+
+  url := "http://" + ln.Addr().String() + "/slow"
+
+  go func() {
+    res, err := http.Get(url)
+    if err != nil {
+      responses <- response{err: err}
+      return
+    }
+    defer res.Body.Close()
+
+    body, err := io.ReadAll(res.Body)
+    responses <- response{body: string(body), err: err}
+  }()
+
+  // Once the handler has started, serve() must already be listening for
+  // signals, so it's safe to send one to ourselves.
+  <-started
+
+  p, err := os.FindProcess(os.Getpid())
+  assert.NilError(t, err)
+  err = p.Signal(syscall.SIGTERM)
+  assert.NilError(t, err)
+
+  // The request which was in flight still gets its response...
+  res := <-responses
+  assert.NilError(t, res.err)
+  assert.Equal(t, res.body, "finished")
+
+  // ...and then serve() returns without an error.
+  select {
+  case err := <-served:
+    assert.NilError(t, err)
+  case <-time.After(5 * time.Second):
+    t.Fatal("serve() didn't return after the signal")
+  }
+
+  // New connections are refused once the server has stopped.
+  _, err = http.Get(url)
+  if err == nil {
+    t.Error("got no error connecting to the stopped server")
+  }
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+  "context"
+  "time"
+
+  "github.com/alexedwards/scs/v2"
+  "github.com/prometheus/client_golang/prometheus"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/trace"
+)
+
+// The instrumentedStore type wraps a session store. It counts the operations
+// made on it, and records a span for each one, so that we can see how long
+// the session manager spends loading sessions (Find) and saving them (Commit).
+//
+// It implements scs.CtxStore, which the session manager uses in preference
+// to the plain Store methods, so that we're given the request's context and
+// the spans become part of the request's trace.
+type instrumentedStore struct {
+  scs.Store
+  ops *prometheus.CounterVec
+}
+
+// The instrumentStore() method wraps the session store so that its
+// operations are counted and traced.
+func (m *metrics) instrumentStore(store scs.Store) scs.Store {
+  return &instrumentedStore{Store: store, ops: m.sessionStoreOps}
+}
+
+func (s *instrumentedStore) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+  ctx, span := s.start(ctx, "session.Find")
+
+  var b []byte
+  var found bool
+  var err error
+  if store, ok := s.Store.(scs.CtxStore); ok {
+    b, found, err = store.FindCtx(ctx, token)
+  } else {
+    b, found, err = s.Store.Find(token)
+  }
+
+  s.end(span, "find", err)
+  return b, found, err
+}
+
+func (s *instrumentedStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+  ctx, span := s.start(ctx, "session.Commit")
+
+  var err error
+  if store, ok := s.Store.(scs.CtxStore); ok {
+    err = store.CommitCtx(ctx, token, b, expiry)
+  } else {
+    err = s.Store.Commit(token, b, expiry)
+  }
+
+  s.end(span, "commit", err)
+  return err
+}
+
+func (s *instrumentedStore) DeleteCtx(ctx context.Context, token string) error {
+  ctx, span := s.start(ctx, "session.Delete")
+
+  var err error
+  if store, ok := s.Store.(scs.CtxStore); ok {
+    err = store.DeleteCtx(ctx, token)
+  } else {
+    err = s.Store.Delete(token)
+  }
+
+  s.end(span, "delete", err)
+  return err
+}
+
+// The plain Store methods are still needed to satisfy scs.Store, and are
+// instrumented in the same way, just without a parent span.
+func (s *instrumentedStore) Find(token string) ([]byte, bool, error) {
+  return s.FindCtx(context.Background(), token)
+}
+
+func (s *instrumentedStore) Commit(token string, b []byte, expiry time.Time) error {
+  return s.CommitCtx(context.Background(), token, b, expiry)
+}
+
+func (s *instrumentedStore) Delete(token string) error {
+  return s.DeleteCtx(context.Background(), token)
+}
+
+func (s *instrumentedStore) start(ctx context.Context, name string) (context.Context, trace.Span) {
+  return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+func (s *instrumentedStore) end(span trace.Span, operation string, err error) {
+  result := "ok"
+  if err != nil {
+    result = "error"
+    span.RecordError(err)
+    span.SetStatus(codes.Error, err.Error())
+  }
+  span.End()
+
+  s.ops.WithLabelValues(operation, result).Inc()
+}
+
+// StopCleanup() stops the wrapped store's cleanup goroutine, if it has one,
+// so that wrapping the store doesn't hide it from main().
+func (s *instrumentedStore) StopCleanup() {
+  if store, ok := s.Store.(interface{ StopCleanup() }); ok {
+    store.StopCleanup()
+  }
+}
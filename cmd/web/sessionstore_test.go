@@ -0,0 +1,50 @@
+package main
+
+import (
+  "testing"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+
+  "github.com/alexedwards/scs/v2"
+  "github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestInstrumentedStore(t *testing.T) {
+  recorder := recordSpans(t)
+
+  m := newMetrics()
+  store := m.instrumentStore(memstore.NewWithCleanupInterval(0))
+
+  // The session manager uses the context-aware methods when a store has
+  // them, so those are the ones we use here.
+  ctxStore, ok := store.(scs.CtxStore)
+  if !ok {
+    t.Fatal("instrumented store doesn't implement scs.CtxStore")
+  }
+
+  ctx, parent := tracer().Start(t.Context(), "request")
+
+  err := ctxStore.CommitCtx(ctx, "t0ken", []byte("data"), time.Now().Add(time.Hour))
+  assert.NilError(t, err)
+
+  _, found, err := ctxStore.FindCtx(ctx, "t0ken")
+  assert.NilError(t, err)
+  assert.Equal(t, found, true)
+
+  err = ctxStore.DeleteCtx(ctx, "t0ken")
+  assert.NilError(t, err)
+
+  parent.End()
+
+  // Each operation is counted...
+  metrics := scrapeMetrics(t, &application{metrics: m})
+
+  assert.StringContains(t, metrics, `snippetbox_session_store_operations_total{operation="commit",result="ok"} 1`)
+  assert.StringContains(t, metrics, `snippetbox_session_store_operations_total{operation="find",result="ok"} 1`)
+  assert.StringContains(t, metrics, `snippetbox_session_store_operations_total{operation="delete",result="ok"} 1`)
+
+  // ...and traced as part of the request.
+  find := findSpan(t, recorder, "session.Find")
+  assert.Equal(t, find.Parent().SpanID(), parent.SpanContext().SpanID())
+}
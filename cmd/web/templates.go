@@ -1,12 +1,19 @@
 package main
 
 import (
+  "fmt"
   "html/template"
   "io/fs"
   "path/filepath"
+  "regexp"
+  "strings"
   "time"
+  "unicode"
+  "unicode/utf8"
 
+  "github.com/kjloveless/snippetbox/internal/diff"
   "github.com/kjloveless/snippetbox/internal/models"
+  "github.com/kjloveless/snippetbox/internal/syntax"
   "github.com/kjloveless/snippetbox/ui"
 )
 
@@ -22,11 +29,149 @@ func humanDate(t time.Time) string {
   return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// Create an until function which returns a short, approximate description of
+// how long is left until t, such as "3h" or "2d". It's used to show when a
+// snippet expires.
+func until(t time.Time) string {
+  d := time.Until(t)
+
+  switch {
+  case d < time.Minute:
+    return "less than a minute"
+  case d < time.Hour:
+    return fmt.Sprintf("%dm", int(d.Minutes()))
+  case d < 48*time.Hour:
+    return fmt.Sprintf("%dh", int(d.Hours()))
+  default:
+    return fmt.Sprintf("%dd", int(d.Hours()/24))
+  }
+}
+
+// Create a sub function which subtracts one integer from another. This is
+// handy for working out things like the previous revision number in templates.
+func sub(a, b int) int {
+  return a - b
+}
+
+// searchTermsRX returns a case-insensitive regular expression which matches
+// any of the words in a search query, or nil if the query has no words in it.
+// Any characters which aren't letters or numbers are treated as separators.
+func searchTermsRX(query string) *regexp.Regexp {
+  terms := strings.FieldsFunc(query, func(r rune) bool {
+    return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+  })
+  if len(terms) == 0 {
+    return nil
+  }
+
+  for i, term := range terms {
+    terms[i] = regexp.QuoteMeta(term)
+  }
+
+  return regexp.MustCompile("(?i)" + strings.Join(terms, "|"))
+}
+
+// Create a highlight function which HTML-escapes some text and wraps any words
+// from the search query in <mark> tags. Because it returns a template.HTML
+// value, the html/template package won't escape the result again.
+func highlight(text, query string) template.HTML {
+  rx := searchTermsRX(query)
+  if rx == nil {
+    return template.HTML(template.HTMLEscapeString(text))
+  }
+
+  var b strings.Builder
+  last := 0
+  for _, loc := range rx.FindAllStringIndex(text, -1) {
+    b.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+    b.WriteString("<mark>")
+    b.WriteString(template.HTMLEscapeString(text[loc[0]:loc[1]]))
+    b.WriteString("</mark>")
+    last = loc[1]
+  }
+  b.WriteString(template.HTMLEscapeString(text[last:]))
+
+  return template.HTML(b.String())
+}
+
+// excerptLength is the number of characters of content shown by excerpt().
+const excerptLength = 200
+
+// Create an excerpt function which returns a short piece of the text, centered
+// (roughly) on the first match for the search query. An ellipsis is added to
+// either end if the text has been cut short there.
+func excerpt(text, query string) string {
+  runes := []rune(text)
+  if len(runes) <= excerptLength {
+    return text
+  }
+
+  start := 0
+  if rx := searchTermsRX(query); rx != nil {
+    if loc := rx.FindStringIndex(text); loc != nil {
+      start = utf8.RuneCountInString(text[:loc[0]]) - excerptLength/2
+    }
+  }
+  start = max(0, min(start, len(runes)-excerptLength))
+  end := start + excerptLength
+
+  result := string(runes[start:end])
+  if start > 0 {
+    result = "…" + result
+  }
+  if end < len(runes) {
+    result += "…"
+  }
+
+  return result
+}
+
+// Create a tagWeight function which returns a number from 1 to 4 indicating
+// how popular a tag is compared to the most popular tag in the list. This is
+// used to size the tags in the tag cloud.
+func tagWeight(tag models.Tag, tags []models.Tag) int {
+  most := 0
+  for _, t := range tags {
+    most = max(most, t.Count)
+  }
+  if most == 0 {
+    return 1
+  }
+
+  return 1 + (tag.Count*3)/most
+}
+
+// The expiries() function returns the choices for when a snippet expires, for
+// the snippet form.
+func expiries() []expiryOption {
+  return expiryOptions
+}
+
+// The tokenExpiries() function returns the choices for when an API token
+// expires, for the form on the account page.
+func tokenExpiries() []expiryOption {
+  return tokenExpiryOptions
+}
+
+// The languages() function returns the languages a snippet can be written in,
+// for the language select box in the snippet form.
+func languages() []syntax.Language {
+  return syntax.Languages
+}
+
 // Initialize a template.FuncMap object and store it in a global variable. This
 // is essentially a string-keyed map which acts as a lookup between the names
 // of our custom template functions and the functions themselves.
 var functions = template.FuncMap{
-  "humanDate": humanDate,
+  "humanDate":     humanDate,
+  "sub":           sub,
+  "highlight":     highlight,
+  "excerpt":       excerpt,
+  "tagWeight":     tagWeight,
+  "languages":     languages,
+  "expiries":      expiries,
+  "tokenExpiries": tokenExpiries,
+  "until":         until,
 }
 
 // Define a templateData type to act as the holding structure for
@@ -34,13 +179,29 @@ var functions = template.FuncMap{
 // At the moment it only contains one field, but we'll add more to it
 // as the build progresses.
 type templateData struct {
-  CurrentYear     int
-  Snippet         models.Snippet
-  Snippets        []models.Snippet
-  Form            any
-  Flash           string
-  IsAuthenticated bool
-  CSRFToken       string
+  CurrentYear         int
+  Snippet             models.Snippet
+  HighlightedContent  template.HTML
+  Language            string
+  Snippets            []models.Snippet
+  Revisions           []models.Revision
+  Tags                []models.Tag
+  Tokens              []models.Token
+  NewToken            string
+  FromRevision        models.Revision
+  ToRevision          models.Revision
+  Diff                []diff.Hunk
+  Form                any
+  Flash               string
+  IsAuthenticated     bool
+  AuthenticatedUserID int
+  CSRFToken           string
+  Query               string
+  Page                int
+  NextPage            int
+  PrevPage            int
+  NextURL             string
+  PrevURL             string
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -1,6 +1,7 @@
 package main
 
 import (
+  "strings"
   "testing"
   "time"
 
@@ -48,3 +49,126 @@ func TestHumanDate(t *testing.T) {
     })
   }
 }
+
+func TestHighlight(t *testing.T) {
+  tests := []struct {
+    name  string
+    text  string
+    query string
+    want  string
+  }{
+    {
+      name:  "Single match",
+      text:  "an old silent pond",
+      query: "silent",
+      want:  "an old <mark>silent</mark> pond",
+    },
+    {
+      name:  "Case insensitive",
+      text:  "An Old Silent Pond",
+      query: "old pond",
+      want:  "An <mark>Old</mark> Silent <mark>Pond</mark>",
+    },
+    {
+      name:  "Escapes HTML",
+      text:  "<b>pond</b>",
+      query: "pond",
+      want:  "&lt;b&gt;<mark>pond</mark>&lt;/b&gt;",
+    },
+    {
+      name:  "Ignores punctuation in query",
+      text:  "a.b",
+      query: ".",
+      want:  "a.b",
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      assert.Equal(t, string(highlight(tt.text, tt.query)), tt.want)
+    })
+  }
+}
+
+func TestExcerpt(t *testing.T) {
+  long := strings.Repeat("a ", 150) + "frog " + strings.Repeat("b ", 150)
+
+  tests := []struct {
+    name       string
+    text       string
+    query      string
+    wantPrefix string
+    wantSuffix string
+    wantMatch  string
+  }{
+    {
+      name:       "Short text",
+      text:       "an old silent pond",
+      query:      "frog",
+      wantPrefix: "an old",
+      wantSuffix: "pond",
+    },
+    {
+      name:       "Match in middle",
+      text:       long,
+      query:      "frog",
+      wantPrefix: "…",
+      wantSuffix: "…",
+      wantMatch:  "frog",
+    },
+    {
+      name:       "No match",
+      text:       long,
+      query:      "pond",
+      wantPrefix: "a a",
+      wantSuffix: "…",
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      e := excerpt(tt.text, tt.query)
+
+      assert.Equal(t, strings.HasPrefix(e, tt.wantPrefix), true)
+      assert.Equal(t, strings.HasSuffix(e, tt.wantSuffix), true)
+      assert.StringContains(t, e, tt.wantMatch)
+    })
+  }
+}
+
+func TestUntil(t *testing.T) {
+  // Add a little slack to each duration, so that the time which passes while
+  // the test runs doesn't matter.
+  tests := []struct {
+    name string
+    d    time.Duration
+    want string
+  }{
+    {
+      name: "Seconds",
+      d:    30 * time.Second,
+      want: "less than a minute",
+    },
+    {
+      name: "Minutes",
+      d:    10*time.Minute + 30*time.Second,
+      want: "10m",
+    },
+    {
+      name: "Hours",
+      d:    3*time.Hour + 30*time.Second,
+      want: "3h",
+    },
+    {
+      name: "Days",
+      d:    7*24*time.Hour + 30*time.Second,
+      want: "7d",
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      assert.Equal(t, until(time.Now().Add(tt.d)), tt.want)
+    })
+  }
+}
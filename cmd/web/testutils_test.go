@@ -10,6 +10,7 @@ import (
   "net/http/httptest"
   "net/url"
   "regexp"
+  "strings"
   "testing"
   "time"
 
@@ -43,9 +44,12 @@ func newTestApplication(t *testing.T) *application {
     logger:           slog.New(slog.DiscardHandler),
     snippets:         &mocks.SnippetModel{},
     users:            &mocks.UserModel{},
+    revisions:        &mocks.RevisionModel{},
+    tokens:           &mocks.TokenModel{},
     templateCache:    templateCache,
     formDecoder:      formDecoder,
     sessionManager:   sessionManager,
+    metrics:          newMetrics(),
   }
 }
 
@@ -138,3 +142,51 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
   // Return the response status, headers, and body
   return rs.StatusCode, rs.Header, string(body)
 }
+
+// Create a login method which logs in as the user from our mocked UserModel
+// (alice@example.com). Because the test server client has a cookie jar, the
+// resulting session cookie will be sent with any subsequent requests.
+func (ts *testServer) login(t *testing.T) {
+  _, _, body := ts.get(t, "/user/login")
+
+  form := url.Values{}
+  form.Add("email", "alice@example.com")
+  form.Add("password", "pa$$word")
+  form.Add("csrf_token", extractCSRFToken(t, body))
+
+  code, _, _ := ts.postForm(t, "/user/login", form)
+  if code != http.StatusSeeOther {
+    t.Fatalf("login failed with status %d", code)
+  }
+}
+
+// Create an apiRequest method for sending requests to the JSON API. If token
+// isn't empty it's sent as a bearer token in the Authorization header, and if
+// body isn't empty it's sent as the request body.
+func (ts *testServer) apiRequest(t *testing.T, method, urlPath, token, body string) (int, http.Header, string) {
+  req, err := http.NewRequest(method, ts.URL + urlPath, strings.NewReader(body))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if token != "" {
+    req.Header.Set("Authorization", "Bearer " + token)
+  }
+  if body != "" {
+    req.Header.Set("Content-Type", "application/json")
+  }
+
+  rs, err := ts.Client().Do(req)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  defer rs.Body.Close()
+  respBody, err := io.ReadAll(rs.Body)
+  if err != nil {
+    t.Fatal(err)
+  }
+  respBody = bytes.TrimSpace(respBody)
+
+  return rs.StatusCode, rs.Header, string(respBody)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+  "context"
+  "fmt"
+  "io"
+  "log/slog"
+  "net/http"
+  "strings"
+
+  "go.opentelemetry.io/otel"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+  "go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+  "go.opentelemetry.io/otel/propagation"
+  "go.opentelemetry.io/otel/sdk/resource"
+  sdktrace "go.opentelemetry.io/otel/sdk/trace"
+  semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+  "go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans created by the web application.
+const tracerName = "github.com/kjloveless/snippetbox/cmd/web"
+
+// The tracer() function returns the tracer for our spans. We get it from the
+// global provider every time, rather than keeping one in a variable, so that
+// it's always the provider which is currently configured.
+func tracer() trace.Tracer {
+  return otel.Tracer(tracerName)
+}
+
+// The setupTracing() function configures OpenTelemetry. The exporter decides
+// where spans go: "otlp" sends them to a collector over OTLP/HTTP (at
+// endpoint if it's set, otherwise wherever the standard OTEL_EXPORTER_OTLP_*
+// environment variables say), "stdout" writes them to w as JSON, and "none"
+// doesn't record them at all. sampleRatio is the fraction of new traces which
+// are recorded; requests which are part of a trace that's already been
+// sampled (or not) follow that decision.
+//
+// Whichever exporter is used, we read and pass on W3C trace context headers,
+// so that our trace IDs match the caller's. The returned function flushes any
+// spans which haven't been exported yet, and should be called before exiting.
+func setupTracing(ctx context.Context, exporter, endpoint string, sampleRatio float64, w io.Writer) (func(context.Context) error, error) {
+  otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+    propagation.TraceContext{}, propagation.Baggage{}))
+
+  var exp sdktrace.SpanExporter
+  var err error
+
+  switch exporter {
+  case "none":
+    return func(context.Context) error { return nil }, nil
+  case "stdout":
+    exp, err = stdouttrace.New(stdouttrace.WithWriter(w))
+  case "otlp":
+    var opts []otlptracehttp.Option
+    if endpoint != "" {
+      opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+    }
+    exp, err = otlptracehttp.New(ctx, opts...)
+  default:
+    return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+  }
+  if err != nil {
+    return nil, err
+  }
+
+  // The service name can be overridden with the OTEL_SERVICE_NAME environment
+  // variable, which is applied after our default.
+  res, err := resource.New(ctx,
+    resource.WithAttributes(semconv.ServiceName("snippetbox")),
+    resource.WithFromEnv(),
+    resource.WithTelemetrySDK(),
+  )
+  if err != nil {
+    return nil, err
+  }
+
+  provider := sdktrace.NewTracerProvider(
+    sdktrace.WithBatcher(exp),
+    sdktrace.WithResource(res),
+    sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+  )
+  otel.SetTracerProvider(provider)
+
+  return provider.Shutdown, nil
+}
+
+// The trace middleware starts a span for each request. If the request has a
+// traceparent header the span joins the caller's trace. Once the request has
+// been routed we rename the span after the route's pattern (like
+// "GET /snippet/view/{slug}"), so that requests for different snippets are
+// grouped together.
+//
+// This goes at the very start of the middleware chain, so that the span
+// covers everything else. It has to replace the request to add the span to
+// its context, so it reads the pattern from the replacement, which is the
+// one the servemux sees.
+func (app *application) trace(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+    ctx, span := tracer().Start(ctx, r.Method,
+      trace.WithSpanKind(trace.SpanKindServer),
+      trace.WithAttributes(
+        semconv.HTTPRequestMethodKey.String(r.Method),
+        semconv.URLPath(r.URL.Path),
+        semconv.ClientAddress(r.RemoteAddr),
+      ),
+    )
+    defer span.End()
+
+    r = r.WithContext(ctx)
+    rec := &statusRecorder{ResponseWriter: w}
+
+    next.ServeHTTP(rec, r)
+
+    if r.Pattern != "" {
+      // The span name is the method and the route, but the http.route
+      // attribute is just the route, so we take the method off patterns
+      // which have one.
+      span.SetName(r.Pattern)
+      route := r.Pattern
+      if _, path, ok := strings.Cut(route, " "); ok {
+        route = path
+      }
+      span.SetAttributes(semconv.HTTPRoute(route))
+    }
+
+    status := rec.status
+    if status == 0 {
+      status = http.StatusOK
+    }
+    span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+
+    // Only server errors mark the span as failed. A 4xx response is the
+    // client's problem, not ours.
+    if status >= 500 {
+      span.SetStatus(codes.Error, http.StatusText(status))
+    }
+  })
+}
+
+// The traceHandler type wraps a slog.Handler, and adds the trace and span IDs
+// from the context to each record, so that log entries can be matched up with
+// traces. Only the logging methods which take a context (like InfoContext())
+// pass one to the handler, so those are the ones to use while handling a
+// request.
+type traceHandler struct {
+  slog.Handler
+}
+
+func newTraceHandler(h slog.Handler) *traceHandler {
+  return &traceHandler{Handler: h}
+}
+
+func (h *traceHandler) Handle(ctx context.Context, record slog.Record) error {
+  if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+    record.AddAttrs(
+      slog.String("trace_id", sc.TraceID().String()),
+      slog.String("span_id", sc.SpanID().String()),
+    )
+  }
+  return h.Handler.Handle(ctx, record)
+}
+
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+  return newTraceHandler(h.Handler.WithAttrs(attrs))
+}
+
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+  return newTraceHandler(h.Handler.WithGroup(name))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+  "bytes"
+  "context"
+  "log/slog"
+  "net/http"
+  "strings"
+  "testing"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+
+  "go.opentelemetry.io/otel"
+  "go.opentelemetry.io/otel/propagation"
+  sdktrace "go.opentelemetry.io/otel/sdk/trace"
+  "go.opentelemetry.io/otel/sdk/trace/tracetest"
+  "go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans() makes the global tracer provider record spans in memory, and
+// puts the previous provider and propagator back when the test finishes.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+  recorder := tracetest.NewSpanRecorder()
+
+  previousProvider := otel.GetTracerProvider()
+  previousPropagator := otel.GetTextMapPropagator()
+  t.Cleanup(func() {
+    otel.SetTracerProvider(previousProvider)
+    otel.SetTextMapPropagator(previousPropagator)
+  })
+
+  otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+  otel.SetTextMapPropagator(propagation.TraceContext{})
+
+  return recorder
+}
+
+// findSpan() returns the first ended span with the given name.
+func findSpan(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+  for _, span := range recorder.Ended() {
+    if span.Name() == name {
+      return span
+    }
+  }
+
+  t.Fatalf("no span named %q", name)
+  return nil
+}
+
+func TestTrace(t *testing.T) {
+  recorder := recordSpans(t)
+
+  app := newTestApplication(t)
+  ts := newTestServer(t, app.routes())
+  defer ts.Close()
+
+  // Send a request as part of an existing trace.
+  const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+  req, err := http.NewRequest(http.MethodGet, ts.URL + "/snippet/view/s1lentPd", nil)
+  assert.NilError(t, err)
+  req.Header.Set("traceparent", "00-" + traceID + "-00f067aa0ba902b7-01")
+
+  rs, err := ts.Client().Do(req)
+  assert.NilError(t, err)
+  rs.Body.Close()
+
+  // The request's span is named after its route, and joins the caller's
+  // trace...
+  span := findSpan(t, recorder, "GET /snippet/view/{slug}")
+  assert.Equal(t, span.SpanContext().TraceID().String(), traceID)
+  assert.Equal(t, span.SpanKind(), trace.SpanKindServer)
+
+  attrs := map[string]string{}
+  for _, kv := range span.Attributes() {
+    attrs[string(kv.Key)] = kv.Value.Emit()
+  }
+  assert.Equal(t, attrs["http.route"], "/snippet/view/{slug}")
+  assert.Equal(t, attrs["http.response.status_code"], "200")
+
+  // ...and rendering the page is a child of it.
+  render := findSpan(t, recorder, "render view.tmpl")
+  assert.Equal(t, render.Parent().SpanID(), span.SpanContext().SpanID())
+}
+
+func TestTraceHandler(t *testing.T) {
+  recordSpans(t)
+
+  var buf bytes.Buffer
+  logger := slog.New(newTraceHandler(slog.NewTextHandler(&buf, nil)))
+
+  ctx, span := tracer().Start(context.Background(), "test")
+  defer span.End()
+
+  // Entries logged with a span's context get its IDs...
+  logger.InfoContext(ctx, "with span")
+  assert.StringContains(t, buf.String(), "trace_id=" + span.SpanContext().TraceID().String())
+  assert.StringContains(t, buf.String(), "span_id=" + span.SpanContext().SpanID().String())
+
+  // ...and ones without a span don't.
+  buf.Reset()
+  logger.With("key", "value").InfoContext(context.Background(), "without span")
+  if strings.Contains(buf.String(), "trace_id") {
+    t.Errorf("got a trace ID without a span: %s", buf.String())
+  }
+}
+
+func TestSetupTracingStdout(t *testing.T) {
+  // setupTracing() changes the global provider and propagator, so
+  // recordSpans() is used to put them back afterwards.
+  recordSpans(t)
+
+  var buf bytes.Buffer
+  shutdown, err := setupTracing(context.Background(), "stdout", "", 1, &buf)
+  assert.NilError(t, err)
+
+  _, span := tracer().Start(context.Background(), "exported span")
+  span.End()
+
+  // The spans are exported in batches, so they're only written once we shut
+  // down.
+  err = shutdown(context.Background())
+  assert.NilError(t, err)
+  assert.StringContains(t, buf.String(), `"Name":"exported span"`)
+  assert.StringContains(t, buf.String(), `"Value":"snippetbox"`)
+}
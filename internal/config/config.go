@@ -0,0 +1,328 @@
+// Package config loads the settings for snippetbox from flags, SNIPPETBOX_*
+// environment variables and a TOML config file. The web application and the
+// snippetadmin command both use it, so that they read the same settings in
+// the same way, and connect to the same database.
+package config
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "net/url"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/models"
+
+  "github.com/BurntSushi/toml"
+  "github.com/go-sql-driver/mysql"
+  "golang.org/x/crypto/bcrypt"
+)
+
+// The Config struct holds every setting for the web application. Each setting
+// has a single name, which is used for its command-line flag (-reap-batch),
+// its key in the config file (reap-batch = 500), and, in upper case with a
+// SNIPPETBOX_ prefix, its environment variable (SNIPPETBOX_REAP_BATCH=500).
+type Config struct {
+  Addr              string         `toml:"addr"`
+  BaseURL           string         `toml:"base-url"`
+  MetricsAddr       string         `toml:"metrics-addr"`
+  TLSCert           string         `toml:"tls-cert"`
+  TLSKey            string         `toml:"tls-key"`
+  Pass              string         `toml:"pass"`
+  DSN               string         `toml:"dsn"`
+  DB                string         `toml:"db"`
+  Migrate           bool           `toml:"migrate"`
+  QueryTimeout      time.Duration  `toml:"query-timeout"`
+  SessionLifetime   time.Duration  `toml:"session-lifetime"`
+  IdleTimeout       time.Duration  `toml:"idle-timeout"`
+  ReadTimeout       time.Duration  `toml:"read-timeout"`
+  WriteTimeout      time.Duration  `toml:"write-timeout"`
+  DrainTimeout      time.Duration  `toml:"drain-timeout"`
+  ReapInterval      time.Duration  `toml:"reap-interval"`
+  ReapBatch         int            `toml:"reap-batch"`
+  BurnedRetention   time.Duration  `toml:"burned-retention"`
+  BcryptCost        int            `toml:"bcrypt-cost"`
+  TraceExporter     string         `toml:"trace-exporter"`
+  OTLPEndpoint      string         `toml:"otlp-endpoint"`
+  TraceSampleRatio  float64        `toml:"trace-sample-ratio"`
+
+  // These two only make sense on the command line (or, for the config file,
+  // in the environment), so they're left out of the file.
+  ConfigFile        string         `toml:"-"`
+  PrintConfig       bool           `toml:"-"`
+}
+
+// envPrefix is added to the start of each setting's environment variable.
+const envPrefix = "SNIPPETBOX_"
+
+// redacted replaces secrets when the config is printed.
+const redacted = "xxxxx"
+
+// The Default() function returns the settings which are used when nothing
+// else is configured.
+func Default() Config {
+  return Config{
+    Addr:             ":4000",
+    MetricsAddr:      "localhost:4001",
+    TLSCert:          "./tls/cert.pem",
+    TLSKey:           "./tls/key.pem",
+    Pass:             "toor",
+    QueryTimeout:     3 * time.Second,
+    SessionLifetime:  12 * time.Hour,
+    IdleTimeout:      time.Minute,
+    ReadTimeout:      5 * time.Second,
+    WriteTimeout:     10 * time.Second,
+    DrainTimeout:     30 * time.Second,
+    ReapInterval:     10 * time.Minute,
+    ReapBatch:        500,
+    BurnedRetention:  30 * 24 * time.Hour,
+    BcryptCost:       models.DefaultBcryptCost,
+    TraceExporter:    "none",
+    TraceSampleRatio: 1,
+  }
+}
+
+// The Load() function works out the settings to use. It starts with the
+// defaults, then applies the config file (given by -config, or by the
+// SNIPPETBOX_CONFIG environment variable), then any SNIPPETBOX_* environment
+// variables, and then the flags in args, so that a flag always wins. The flags
+// are defined on fs, which decides what happens if one can't be parsed.
+//
+// If no DSN is configured, one is built from the password. We do this last,
+// so that -pass works wherever the password comes from.
+func Load(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+  cfg := Default()
+
+  fs.StringVar(&cfg.ConfigFile, "config", "", "Path to a TOML config file")
+  fs.BoolVar(&cfg.PrintConfig, "print-config", false, "Print the effective configuration, with secrets redacted, and exit")
+  fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "HTTP network address")
+  fs.StringVar(&cfg.BaseURL, "base-url", cfg.BaseURL, "Public URL of the site (like https://snippets.example.com), used in links sent outside the browser")
+  fs.StringVar(&cfg.MetricsAddr, "metrics-addr", cfg.MetricsAddr, "Network address for the Prometheus metrics (empty to disable)")
+  fs.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "Path to the TLS certificate")
+  fs.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "Path to the TLS private key")
+  fs.StringVar(&cfg.Pass, "pass", cfg.Pass, "Password to use in the default DSN")
+  fs.StringVar(&cfg.DSN, "dsn", cfg.DSN, "MySQL DSN, or PostgreSQL URL (postgres://...) (default web:<pass>@/snippetbox?parseTime=true)")
+  fs.StringVar(&cfg.DB, "db", cfg.DB, "Database URL (sqlite:///path/to.db or postgres://...), used instead of -dsn")
+  fs.BoolVar(&cfg.Migrate, "migrate", cfg.Migrate, "Apply pending database migrations at startup")
+  fs.DurationVar(&cfg.QueryTimeout, "query-timeout", cfg.QueryTimeout, "Deadline for each database query (0 for none)")
+  fs.DurationVar(&cfg.SessionLifetime, "session-lifetime", cfg.SessionLifetime, "How long a session lasts after it's created")
+  fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", cfg.IdleTimeout, "How long to keep idle keep-alive connections open (0 for the read timeout)")
+  fs.DurationVar(&cfg.ReadTimeout, "read-timeout", cfg.ReadTimeout, "Deadline for reading a request (0 for none)")
+  fs.DurationVar(&cfg.WriteTimeout, "write-timeout", cfg.WriteTimeout, "Deadline for writing a response (0 for none)")
+  fs.DurationVar(&cfg.DrainTimeout, "drain-timeout", cfg.DrainTimeout, "How long to wait for in-flight requests when shutting down")
+  fs.DurationVar(&cfg.ReapInterval, "reap-interval", cfg.ReapInterval, "How often to delete expired snippets (0 to disable)")
+  fs.IntVar(&cfg.ReapBatch, "reap-batch", cfg.ReapBatch, "Maximum number of expired snippets to delete in one query")
+  fs.DurationVar(&cfg.BurnedRetention, "burned-retention", cfg.BurnedRetention, "How long to remember that a burn-after-reading snippet has been read")
+  fs.IntVar(&cfg.BcryptCost, "bcrypt-cost", cfg.BcryptCost, "bcrypt cost for new password hashes")
+  fs.StringVar(&cfg.TraceExporter, "trace-exporter", cfg.TraceExporter, "Where to send traces: none, stdout or otlp")
+  fs.StringVar(&cfg.OTLPEndpoint, "otlp-endpoint", cfg.OTLPEndpoint, "OTLP/HTTP endpoint URL for traces (default from the OTEL_EXPORTER_OTLP_* environment variables)")
+  fs.Float64Var(&cfg.TraceSampleRatio, "trace-sample-ratio", cfg.TraceSampleRatio, "Fraction of new traces to record, from 0 to 1")
+
+  err := fs.Parse(args)
+  if err != nil {
+    return Config{}, err
+  }
+
+  // The config file and environment variables are applied after the flags
+  // have been parsed (we need -config to know which file to read), so we make
+  // a note of the flags which were set, and set them again at the end.
+  flags := map[string]string{}
+  fs.Visit(func(f *flag.Flag) {
+    flags[f.Name] = f.Value.String()
+  })
+
+  if cfg.ConfigFile == "" {
+    cfg.ConfigFile, _ = lookupEnv(envName("config"))
+  }
+
+  if cfg.ConfigFile != "" {
+    // Decoding a file only changes the settings which are in it. Any keys
+    // we don't recognise are probably typos, so they're an error rather than
+    // being silently ignored.
+    md, err := toml.DecodeFile(cfg.ConfigFile, &cfg)
+    if err != nil {
+      return Config{}, fmt.Errorf("config file: %w", err)
+    }
+
+    if undecoded := md.Undecoded(); len(undecoded) > 0 {
+      return Config{}, fmt.Errorf("config file %s: unknown setting %q", cfg.ConfigFile, undecoded[0].String())
+    }
+  }
+
+  var envErr error
+  fs.VisitAll(func(f *flag.Flag) {
+    if f.Name == "config" || envErr != nil {
+      return
+    }
+
+    value, ok := lookupEnv(envName(f.Name))
+    if !ok {
+      return
+    }
+
+    err := f.Value.Set(value)
+    if err != nil {
+      envErr = fmt.Errorf("invalid value %q for environment variable %s: %w", value, envName(f.Name), err)
+    }
+  })
+  if envErr != nil {
+    return Config{}, envErr
+  }
+
+  for name, value := range flags {
+    // These values have already been parsed once, so they can't fail.
+    fs.Set(name, value)
+  }
+
+  if cfg.DSN == "" {
+    cfg.DSN = "web:" + cfg.Pass + "@/snippetbox?parseTime=true"
+  }
+
+  return cfg, nil
+}
+
+// The envName() function returns the environment variable for a setting, so
+// "reap-batch" becomes SNIPPETBOX_REAP_BATCH.
+func envName(name string) string {
+  return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// The Validate() method checks that the settings make sense, so that a
+// mistake is reported when the application starts rather than when the
+// setting is first used. Every problem is reported, not just the first.
+func (cfg Config) Validate() error {
+  var errs []error
+  check := func(ok bool, format string, args ...any) {
+    if !ok {
+      errs = append(errs, fmt.Errorf(format, args...))
+    }
+  }
+
+  check(cfg.Addr != "", "addr must not be empty")
+
+  if cfg.BaseURL != "" {
+    u, err := url.Parse(cfg.BaseURL)
+    check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+      "base-url must be an http:// or https:// URL, got %q", cfg.BaseURL)
+  }
+
+  check(cfg.TLSCert != "", "tls-cert must not be empty")
+  check(cfg.TLSKey != "", "tls-key must not be empty")
+
+  if cfg.DB != "" {
+    check(strings.HasPrefix(cfg.DB, "sqlite://") ||
+      strings.HasPrefix(cfg.DB, "postgres://") ||
+      strings.HasPrefix(cfg.DB, "postgresql://"),
+      "db must be a sqlite:// or postgres:// URL, got %q", cfg.DB)
+  }
+
+  check(cfg.QueryTimeout >= 0, "query-timeout must not be negative")
+  check(cfg.SessionLifetime > 0, "session-lifetime must be greater than zero")
+  check(cfg.IdleTimeout >= 0, "idle-timeout must not be negative")
+  check(cfg.ReadTimeout >= 0, "read-timeout must not be negative")
+  check(cfg.WriteTimeout >= 0, "write-timeout must not be negative")
+  check(cfg.DrainTimeout >= 0, "drain-timeout must not be negative")
+  check(cfg.ReapInterval >= 0, "reap-interval must not be negative")
+  check(cfg.ReapBatch > 0, "reap-batch must be greater than zero")
+  check(cfg.BurnedRetention >= 0, "burned-retention must not be negative")
+  check(cfg.BcryptCost >= bcrypt.MinCost && cfg.BcryptCost <= bcrypt.MaxCost,
+    "bcrypt-cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cfg.BcryptCost)
+  check(cfg.TraceExporter == "none" || cfg.TraceExporter == "stdout" || cfg.TraceExporter == "otlp",
+    "trace-exporter must be none, stdout or otlp, got %q", cfg.TraceExporter)
+  check(cfg.TraceSampleRatio >= 0 && cfg.TraceSampleRatio <= 1,
+    "trace-sample-ratio must be between 0 and 1, got %g", cfg.TraceSampleRatio)
+
+  return errors.Join(errs...)
+}
+
+// The Write() method writes the config to w in the same TOML format as the
+// config file, so that its output can be used as one. The password, and any
+// password in the DSN or database URL, are replaced with xxxxx.
+func (cfg Config) Write(w io.Writer) error {
+  if cfg.Pass != "" {
+    cfg.Pass = redacted
+  }
+  cfg.DSN = redactDSN(cfg.DSN)
+  cfg.DB = redactDSN(cfg.DB)
+
+  return toml.NewEncoder(w).Encode(cfg)
+}
+
+// The Source() method returns the DSN or URL of the database to connect to.
+// The -db URL is used if it's set, and otherwise the MySQL DSN.
+func (cfg Config) Source() string {
+  if cfg.DB != "" {
+    return cfg.DB
+  }
+  return cfg.DSN
+}
+
+// The redactDSN() function hides the password in a MySQL DSN or a database
+// URL. If the DSN can't be parsed we can't tell which part is the password,
+// so the whole thing is hidden.
+func redactDSN(dsn string) string {
+  if dsn == "" || strings.HasPrefix(dsn, "sqlite://") {
+    return dsn
+  }
+
+  if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+    u, err := url.Parse(dsn)
+    if err != nil {
+      return redacted
+    }
+    return u.Redacted()
+  }
+
+  // We only use the parsed DSN to find the password, rather than formatting
+  // it again, so that the rest of the DSN is printed just as it was written.
+  mysqlCfg, err := mysql.ParseDSN(dsn)
+  if err != nil {
+    return redacted
+  }
+  if mysqlCfg.Passwd == "" {
+    return dsn
+  }
+  return strings.Replace(dsn, ":"+mysqlCfg.Passwd+"@", ":"+redacted+"@", 1)
+}
+
+// The MustLoad() function loads and validates the config for a main()
+// function, and handles -print-config. The flags are defined on fs, which
+// should be set to exit if a flag can't be parsed. Any other problem is
+// written to stderr and the program exits with status 2.
+func MustLoad(fs *flag.FlagSet, args []string) Config {
+  cfg, err := Load(fs, args, os.LookupEnv)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(2)
+  }
+
+  // When printing the config we still validate it, but only after it's been
+  // printed, since a config with problems is usually why it's being looked at.
+  if cfg.PrintConfig {
+    err = cfg.Write(os.Stdout)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+  }
+
+  err = cfg.Validate()
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", indent(err.Error()))
+    os.Exit(2)
+  }
+
+  if cfg.PrintConfig {
+    os.Exit(0)
+  }
+
+  return cfg
+}
+
+// indent() puts two spaces at the start of each line of s.
+func indent(s string) string {
+  return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
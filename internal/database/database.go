@@ -0,0 +1,62 @@
+// Package database opens the connection pool for whichever database a DSN
+// points at, so that the web application and the snippetadmin command accept
+// the same DSNs and set them up in the same way. Importing it registers the
+// MySQL, PostgreSQL and SQLite drivers.
+package database
+
+import (
+  "database/sql"
+  "strings"
+
+  "github.com/kjloveless/snippetbox/internal/migrations"
+
+  _ "github.com/go-sql-driver/mysql"
+  _ "github.com/jackc/pgx/v5/stdlib"
+  _ "modernc.org/sqlite"
+)
+
+// The Open() function wraps sql.Open() and returns a sql.DB connection pool
+// for a given DSN, along with which database it's connected to (one of the
+// names from the migrations package). DSNs which start with postgres:// or
+// postgresql:// are for PostgreSQL, sqlite:// URLs give the path of a SQLite
+// database file, and anything else is treated as a MySQL DSN. The database is
+// pinged before Open() returns, so a bad DSN is reported straight away.
+func Open(dsn string) (*sql.DB, string, error) {
+  driver, kind := "mysql", migrations.MySQL
+  if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+    driver, kind = "pgx", migrations.Postgres
+  } else if path, ok := strings.CutPrefix(dsn, "sqlite://"); ok {
+    driver, kind, dsn = "sqlite", migrations.SQLite, SQLiteDSN(path)
+  }
+
+  db, err := sql.Open(driver, dsn)
+  if err != nil {
+    return nil, "", err
+  }
+
+  err = db.Ping()
+  if err != nil {
+    db.Close()
+    return nil, "", err
+  }
+
+  return db, kind, nil
+}
+
+// SQLiteDSN() returns the data source name for the SQLite database file at
+// path, with the settings the models rely on:
+//
+//   - foreign_keys turns on foreign key constraints, which SQLite otherwise
+//     ignores, so that deleting a snippet deletes its tags and revisions.
+//   - busy_timeout makes a connection wait for up to five seconds for another
+//     one to finish writing, instead of failing straight away.
+//   - journal_mode(WAL) lets people read while someone else is writing.
+//   - _txlock=immediate makes every transaction take the write lock when it
+//     begins. SQLite has no SELECT ... FOR UPDATE, so this is what stops two
+//     people from both reading a burn-after-reading snippet.
+//   - _time_format=sqlite stores times as text which sorts in time order, so
+//     they can be compared with the current time in SQL.
+func SQLiteDSN(path string) string {
+  return path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)" +
+    "&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=sqlite"
+}
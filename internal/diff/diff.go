@@ -0,0 +1,252 @@
+// Package diff implements a line-based diff between two pieces of text, using
+// the Myers algorithm, and groups the result into unified diff hunks.
+package diff
+
+import (
+  "fmt"
+  "strings"
+)
+
+// Op describes what happened to a line when going from the old text to the
+// new text.
+type Op int
+
+const (
+  Equal Op = iota
+  Insert
+  Delete
+)
+
+// Line holds a single line of a diff. OldLine and NewLine are the 1-based line
+// numbers of the line in the old and new text respectively, and are 0 when the
+// line doesn't appear in that text (i.e. OldLine is 0 for inserted lines and
+// NewLine is 0 for deleted lines).
+type Line struct {
+  Op      Op
+  Text    string
+  OldLine int
+  NewLine int
+}
+
+// Prefix() returns the character which is used to mark the line in unified
+// diff output.
+func (l Line) Prefix() string {
+  switch l.Op {
+  case Insert:
+    return "+"
+  case Delete:
+    return "-"
+  default:
+    return " "
+  }
+}
+
+// Kind() returns a lower-case name for the line's operation. This is handy for
+// using as a CSS class name in templates.
+func (l Line) Kind() string {
+  switch l.Op {
+  case Insert:
+    return "insert"
+  case Delete:
+    return "delete"
+  default:
+    return "equal"
+  }
+}
+
+// Hunk is a contiguous group of changed lines, along with some surrounding
+// unchanged lines for context.
+type Hunk struct {
+  OldStart  int
+  OldLines  int
+  NewStart  int
+  NewLines  int
+  Lines     []Line
+}
+
+// Header() returns the hunk's range information in the standard unified diff
+// format, like "@@ -1,3 +1,4 @@".
+func (h Hunk) Header() string {
+  return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines,
+    h.NewStart, h.NewLines)
+}
+
+// splitLines() breaks text into lines. Windows-style line endings (which is
+// what browsers send for textarea contents) are normalized first, and a final
+// trailing newline doesn't produce an extra empty line.
+func splitLines(text string) []string {
+  if text == "" {
+    return nil
+  }
+
+  text = strings.ReplaceAll(text, "\r\n", "\n")
+  text = strings.TrimSuffix(text, "\n")
+
+  return strings.Split(text, "\n")
+}
+
+// Lines() returns the full line-by-line diff between the old and new text,
+// including all unchanged lines.
+func Lines(oldText, newText string) []Line {
+  a := splitLines(oldText)
+  b := splitLines(newText)
+
+  n, m := len(a), len(b)
+  max := n + m
+  offset := max + 1
+
+  // v holds the furthest reaching x position for each diagonal k (stored at
+  // index k+offset). We keep a snapshot of v at the start of every step so
+  // that we can backtrack through it afterwards to recover the edit path.
+  v := make([]int, 2*max+3)
+  var trace [][]int
+
+search:
+  for d := 0; d <= max; d++ {
+    trace = append(trace, append([]int(nil), v...))
+
+    for k := -d; k <= d; k += 2 {
+      var x int
+      if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+        x = v[offset+k+1]
+      } else {
+        x = v[offset+k-1] + 1
+      }
+      y := x - k
+
+      for x < n && y < m && a[x] == b[y] {
+        x++
+        y++
+      }
+
+      v[offset+k] = x
+
+      if x >= n && y >= m {
+        break search
+      }
+    }
+  }
+
+  // Walk backwards through the trace from the end of both texts, collecting
+  // the edits in reverse order.
+  var lines []Line
+  x, y := n, m
+
+  for d := len(trace) - 1; d >= 0; d-- {
+    v := trace[d]
+    k := x - y
+
+    var prevK int
+    if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+      prevK = k + 1
+    } else {
+      prevK = k - 1
+    }
+
+    prevX := v[offset+prevK]
+    prevY := prevX - prevK
+
+    for x > prevX && y > prevY {
+      lines = append(lines, Line{Op: Equal, Text: a[x-1], OldLine: x, NewLine: y})
+      x--
+      y--
+    }
+
+    if d > 0 {
+      if x == prevX {
+        lines = append(lines, Line{Op: Insert, Text: b[y-1], NewLine: y})
+      } else {
+        lines = append(lines, Line{Op: Delete, Text: a[x-1], OldLine: x})
+      }
+    }
+
+    x, y = prevX, prevY
+  }
+
+  // Reverse the lines so that they're in the right order.
+  for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+    lines[i], lines[j] = lines[j], lines[i]
+  }
+
+  return lines
+}
+
+// Unified() returns the diff between the old and new text grouped into hunks,
+// with the given number of unchanged context lines around each change.
+// Changes which are close enough together that their context would overlap
+// are merged into a single hunk. If the texts are identical, no hunks are
+// returned.
+func Unified(oldText, newText string, context int) []Hunk {
+  lines := Lines(oldText, newText)
+
+  var hunks []Hunk
+  // oldSeen and newSeen count how many lines of the old and new text come
+  // before position i in the diff.
+  oldSeen, newSeen := 0, 0
+  i := 0
+
+  for i < len(lines) {
+    // Skip forward to the next change.
+    next := i
+    for next < len(lines) && lines[next].Op == Equal {
+      next++
+    }
+    if next == len(lines) {
+      break
+    }
+
+    start := next - context
+    if start < i {
+      start = i
+    }
+
+    // Find the end of the last change in this hunk. We keep going until we
+    // hit a run of unchanged lines which is too long to be shared context.
+    end := next
+    for j := next; j < len(lines); j++ {
+      if lines[j].Op != Equal {
+        end = j + 1
+      } else if j-end+1 > 2*context {
+        break
+      }
+    }
+
+    stop := end + context
+    if stop > len(lines) {
+      stop = len(lines)
+    }
+
+    // Catch the line counters up to the start of the hunk. Skipped lines are
+    // always unchanged, so they count towards both texts.
+    oldSeen += start - i
+    newSeen += start - i
+
+    h := Hunk{Lines: lines[start:stop]}
+    for _, l := range h.Lines {
+      if l.Op != Insert {
+        h.OldLines++
+      }
+      if l.Op != Delete {
+        h.NewLines++
+      }
+    }
+
+    // By convention, a hunk which contains no lines from one of the texts
+    // gives the line *before* the hunk as its start line for that text.
+    h.OldStart, h.NewStart = oldSeen, newSeen
+    if h.OldLines > 0 {
+      h.OldStart++
+    }
+    if h.NewLines > 0 {
+      h.NewStart++
+    }
+
+    hunks = append(hunks, h)
+
+    oldSeen += h.OldLines
+    newSeen += h.NewLines
+    i = stop
+  }
+
+  return hunks
+}
@@ -0,0 +1,141 @@
+package diff
+
+import (
+  "strings"
+  "testing"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+)
+
+// render() turns a slice of lines back into unified diff style text, which is
+// easier to compare against in tests.
+func render(lines []Line) string {
+  var b strings.Builder
+  for _, l := range lines {
+    b.WriteString(l.Prefix() + l.Text + "\n")
+  }
+  return b.String()
+}
+
+func TestLines(t *testing.T) {
+  tests := []struct {
+    name    string
+    oldText string
+    newText string
+    want    string
+  }{
+    {
+      name:    "Identical",
+      oldText: "a\nb\nc",
+      newText: "a\nb\nc",
+      want:    " a\n b\n c\n",
+    },
+    {
+      name:    "Both empty",
+      oldText: "",
+      newText: "",
+      want:    "",
+    },
+    {
+      name:    "All inserted",
+      oldText: "",
+      newText: "a\nb",
+      want:    "+a\n+b\n",
+    },
+    {
+      name:    "All deleted",
+      oldText: "a\nb",
+      newText: "",
+      want:    "-a\n-b\n",
+    },
+    {
+      name:    "Changed line",
+      oldText: "a\nb\nc",
+      newText: "a\nx\nc",
+      want:    " a\n-b\n+x\n c\n",
+    },
+    {
+      name:    "CRLF line endings",
+      oldText: "a\r\nb\r\n",
+      newText: "a\nb\nc\n",
+      want:    " a\n b\n+c\n",
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      assert.Equal(t, render(Lines(tt.oldText, tt.newText)), tt.want)
+    })
+  }
+}
+
+func TestLinesNumbers(t *testing.T) {
+  lines := Lines("a\nb\nc", "a\nc\nd")
+
+  want := []Line{
+    {Op: Equal, Text: "a", OldLine: 1, NewLine: 1},
+    {Op: Delete, Text: "b", OldLine: 2},
+    {Op: Equal, Text: "c", OldLine: 3, NewLine: 2},
+    {Op: Insert, Text: "d", NewLine: 3},
+  }
+
+  assert.Equal(t, len(lines), len(want))
+  for i := range want {
+    assert.Equal(t, lines[i], want[i])
+  }
+}
+
+func TestUnified(t *testing.T) {
+  oldText := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15"
+
+  tests := []struct {
+    name        string
+    newText     string
+    wantHeaders []string
+  }{
+    {
+      name:        "No changes",
+      newText:     oldText,
+      wantHeaders: nil,
+    },
+    {
+      name:        "Single change",
+      newText:     strings.Replace(oldText, "8\n", "eight\n", 1),
+      wantHeaders: []string{"@@ -5,7 +5,7 @@"},
+    },
+    {
+      name:        "Separate hunks",
+      newText:     strings.Replace(strings.Replace(oldText, "2\n", "two\n", 1), "14\n", "fourteen\n", 1),
+      wantHeaders: []string{"@@ -1,5 +1,5 @@", "@@ -11,5 +11,5 @@"},
+    },
+    {
+      name:        "Merged hunks",
+      newText:     strings.Replace(strings.Replace(oldText, "5\n", "five\n", 1), "10\n", "ten\n", 1),
+      wantHeaders: []string{"@@ -2,12 +2,12 @@"},
+    },
+    {
+      name:        "Insert at start",
+      newText:     "0\n" + oldText,
+      wantHeaders: []string{"@@ -1,3 +1,4 @@"},
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      hunks := Unified(oldText, tt.newText, 3)
+
+      assert.Equal(t, len(hunks), len(tt.wantHeaders))
+      for i := range hunks {
+        if i < len(tt.wantHeaders) {
+          assert.Equal(t, hunks[i].Header(), tt.wantHeaders[i])
+        }
+      }
+    })
+  }
+
+  // A hunk which only adds lines to an empty text starts at line 0 of the
+  // old text.
+  hunks := Unified("", "a\nb", 3)
+  assert.Equal(t, len(hunks), 1)
+  assert.Equal(t, hunks[0].Header(), "@@ -0,0 +1,2 @@")
+}
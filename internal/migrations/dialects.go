@@ -0,0 +1,123 @@
+package migrations
+
+import (
+  "context"
+  "database/sql"
+  "time"
+)
+
+// Define a dialect type to hold the SQL which differs between databases.
+type dialect struct {
+  // transactional is true if schema changes can be made in a transaction.
+  transactional bool
+  createTable   string
+  tableExists   string
+  insertVersion string
+  deleteVersion string
+  lock          func(ctx context.Context, conn *sql.Conn, timeout time.Duration) error
+  unlock        func(ctx context.Context, conn *sql.Conn)
+}
+
+var dialects = map[string]dialect{
+  MySQL: {
+    createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version integer not null primary key,
+    name varchar(255) not null,
+    applied datetime not null
+    )`,
+    tableExists: `SELECT EXISTS(SELECT true FROM information_schema.tables
+    WHERE table_schema = DATABASE() AND table_name = 'schema_migrations')`,
+    insertVersion: "INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)",
+    deleteVersion: "DELETE FROM schema_migrations WHERE version = ?",
+    lock:          mysqlLock,
+    unlock: func(ctx context.Context, conn *sql.Conn) {
+      conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+    },
+  },
+  Postgres: {
+    transactional: true,
+    createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version integer not null primary key,
+    name varchar(255) not null,
+    applied timestamp not null
+    )`,
+    tableExists: `SELECT EXISTS(SELECT true FROM information_schema.tables
+    WHERE table_schema = current_schema() AND table_name = 'schema_migrations')`,
+    insertVersion: "INSERT INTO schema_migrations (version, name, applied) VALUES ($1, $2, $3)",
+    deleteVersion: "DELETE FROM schema_migrations WHERE version = $1",
+    lock:          postgresLock,
+    unlock: func(ctx context.Context, conn *sql.Conn) {
+      conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", lockName)
+    },
+  },
+  // SQLite has no advisory locks, and a SQLite database normally belongs to
+  // a single process anyway, so there's nothing to lock. If two processes did
+  // migrate the same file at once, the second one's attempt to apply a
+  // migration would fail and its transaction would be rolled back.
+  SQLite: {
+    transactional: true,
+    createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version integer not null primary key,
+    name varchar(255) not null,
+    applied datetime not null
+    )`,
+    tableExists: `SELECT EXISTS(SELECT true FROM sqlite_master
+    WHERE type = 'table' AND name = 'schema_migrations')`,
+    insertVersion: "INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)",
+    deleteVersion: "DELETE FROM schema_migrations WHERE version = ?",
+    lock: func(ctx context.Context, conn *sql.Conn, timeout time.Duration) error {
+      return nil
+    },
+    unlock: func(ctx context.Context, conn *sql.Conn) {},
+  },
+}
+
+// mysqlLock() takes the lock with GET_LOCK(), which waits for up to timeout
+// for another process to release it. It returns 1 if we got the lock, 0 if we
+// timed out waiting for it, and NULL if something went wrong.
+func mysqlLock(ctx context.Context, conn *sql.Conn, timeout time.Duration) error {
+  var got sql.NullInt64
+  err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(timeout.Seconds())).Scan(&got)
+  if err != nil {
+    return err
+  }
+  if !got.Valid || got.Int64 != 1 {
+    return ErrLocked
+  }
+  return nil
+}
+
+// postgresLock() takes the lock with pg_try_advisory_lock(). Postgres
+// advisory locks are identified by a number rather than a name, so we hash
+// the name. There's no way to give pg_advisory_lock() a timeout, so instead
+// we keep trying until the timeout is up.
+func postgresLock(ctx context.Context, conn *sql.Conn, timeout time.Duration) error {
+  ctx, cancel := context.WithTimeout(ctx, timeout)
+  defer cancel()
+
+  ticker := time.NewTicker(250 * time.Millisecond)
+  defer ticker.Stop()
+
+  for {
+    var got bool
+    err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", lockName).Scan(&got)
+    if err != nil {
+      if ctx.Err() == context.DeadlineExceeded {
+        return ErrLocked
+      }
+      return err
+    }
+    if got {
+      return nil
+    }
+
+    select {
+    case <-ticker.C:
+    case <-ctx.Done():
+      if ctx.Err() == context.DeadlineExceeded {
+        return ErrLocked
+      }
+      return ctx.Err()
+    }
+  }
+}
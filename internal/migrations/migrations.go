@@ -0,0 +1,447 @@
+// Package migrations holds the versioned changes to the database schema, and
+// the code to apply and roll them back.
+//
+// Each migration is a pair of SQL files, named like
+// 0003_upgrade_snippets.up.sql and 0003_upgrade_snippets.down.sql, in the
+// directory for the database they're written for (mysql, postgres or sqlite).
+// The number at the start is the migration's version, and migrations are
+// applied in version order. The versions which have been applied are recorded
+// in the schema_migrations table. Every database should have the same
+// migrations, with the same versions, so that they all end up with the same
+// schema.
+//
+// Once a migration has been released it must never be edited, because
+// databases which have already applied it won't pick up the change. Add a new
+// migration instead.
+//
+// The first migration, 0001_create_baseline, creates the schema which
+// snippetbox had before it had migrations, when the tables were made by hand.
+// A database which was set up that way already has those tables, so applying
+// 0001 to it would fail. Instead, Baseline() records 0001 as applied without
+// running it, and Up() then brings the database up to date from there like
+// any other.
+package migrations
+
+import (
+  "context"
+  "database/sql"
+  "embed"
+  "errors"
+  "fmt"
+  "io/fs"
+  "slices"
+  "strconv"
+  "strings"
+  "time"
+)
+
+//go:embed "mysql" "postgres" "sqlite"
+var files embed.FS
+
+// The databases which migrations are written for. These are also the names of
+// the directories holding their migrations.
+const (
+  MySQL    = "mysql"
+  Postgres = "postgres"
+  SQLite   = "sqlite"
+)
+
+// lockName is the name of the advisory lock which is held while migrations
+// are applied or rolled back. It's shared by every process using the same
+// database server, so it includes the application's name.
+const lockName = "snippetbox.migrations"
+
+// ErrNoMigrations is returned by Down() when there are no applied migrations
+// to roll back.
+var ErrNoMigrations = errors.New("migrations: no applied migrations to roll back")
+
+// ErrAlreadyVersioned is returned by Baseline() when the database already has
+// applied migrations, so there's nothing to adopt.
+var ErrAlreadyVersioned = errors.New("migrations: database already has applied migrations")
+
+// ErrLocked is returned if another process held the migration lock for longer
+// than the Migrator's LockTimeout.
+var ErrLocked = errors.New("migrations: timed out waiting for another process to finish migrating")
+
+// Define a Migration type to hold a single migration. Up and Down contain the
+// SQL statements which apply it and roll it back.
+type Migration struct {
+  Version int
+  Name    string
+  Up      string
+  Down    string
+}
+
+// Define a Status type to describe whether a migration has been applied.
+// Applied is the zero time if it hasn't.
+type Status struct {
+  Migration
+  Applied time.Time
+}
+
+// Pending reports whether the migration still needs to be applied.
+func (s Status) Pending() bool {
+  return s.Applied.IsZero()
+}
+
+// Define a Migrator type which applies migrations to a database.
+type Migrator struct {
+  DB          *sql.DB
+  Migrations  []Migration
+  // LockTimeout is how long to wait for another process which is already
+  // migrating the database to finish.
+  LockTimeout time.Duration
+  dialect     dialect
+}
+
+// New() returns a Migrator for the migrations which are embedded in the
+// program. The database should be MySQL, Postgres or SQLite.
+func New(db *sql.DB, database string) (*Migrator, error) {
+  d, ok := dialects[database]
+  if !ok {
+    return nil, fmt.Errorf("migrations: unsupported database %q", database)
+  }
+
+  fsys, err := fs.Sub(files, database)
+  if err != nil {
+    return nil, err
+  }
+
+  migrations, err := Load(fsys)
+  if err != nil {
+    return nil, err
+  }
+
+  return &Migrator{DB: db, Migrations: migrations, LockTimeout: time.Minute, dialect: d}, nil
+}
+
+// Load() reads the migrations from the .sql files in the root of fsys, and
+// returns them in version order. Every migration must have both an up and a
+// down file, and no two migrations can have the same version.
+func Load(fsys fs.FS) ([]Migration, error) {
+  names, err := fs.Glob(fsys, "*.sql")
+  if err != nil {
+    return nil, err
+  }
+
+  byVersion := map[int]*Migration{}
+
+  for _, name := range names {
+    // Split a name like "0003_upgrade_snippets.up.sql" into its parts.
+    base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+    version, label, ok2 := strings.Cut(base, "_")
+    n, err := strconv.Atoi(version)
+    if !ok || !ok2 || err != nil || n < 1 || (direction != "up" && direction != "down") {
+      return nil, fmt.Errorf("migrations: invalid file name %q", name)
+    }
+
+    script, err := fs.ReadFile(fsys, name)
+    if err != nil {
+      return nil, err
+    }
+
+    m, exists := byVersion[n]
+    if !exists {
+      m = &Migration{Version: n, Name: label}
+      byVersion[n] = m
+    }
+    if m.Name != label {
+      return nil, fmt.Errorf("migrations: version %d is used by both %q and %q", n, m.Name, label)
+    }
+
+    if direction == "up" {
+      m.Up = string(script)
+    } else {
+      m.Down = string(script)
+    }
+  }
+
+  var migrations []Migration
+
+  for _, m := range byVersion {
+    if m.Up == "" || m.Down == "" {
+      return nil, fmt.Errorf("migrations: migration %s needs both an up and a down file", m)
+    }
+    migrations = append(migrations, *m)
+  }
+
+  slices.SortFunc(migrations, func(a, b Migration) int {
+    return a.Version - b.Version
+  })
+
+  return migrations, nil
+}
+
+// String() returns the migration's version and name, like
+// "0003_upgrade_snippets", which is what we show to people.
+func (m Migration) String() string {
+  return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Status() returns every migration along with when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+  conn, err := m.DB.Conn(ctx)
+  if err != nil {
+    return nil, err
+  }
+  defer conn.Close()
+
+  applied, err := m.appliedVersions(ctx, conn)
+  if err != nil {
+    return nil, err
+  }
+
+  statuses := make([]Status, len(m.Migrations))
+  for i, migration := range m.Migrations {
+    statuses[i] = Status{Migration: migration, Applied: applied[migration.Version]}
+  }
+
+  return statuses, nil
+}
+
+// Up() applies every pending migration, in order, and returns the ones it
+// applied. It stops at the first migration which fails.
+//
+// Postgres and SQLite run each migration in a transaction, so a migration
+// which fails leaves no trace. MySQL commits schema changes immediately,
+// though, so they can't be rolled back by a transaction. If a migration fails
+// part way through on MySQL, the changes it made before the failure have to
+// be undone by hand before trying again.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+  var done []Migration
+
+  err := m.withLock(ctx, func(conn *sql.Conn) error {
+    applied, err := m.appliedVersions(ctx, conn)
+    if err != nil {
+      return err
+    }
+
+    for _, migration := range m.Migrations {
+      if _, ok := applied[migration.Version]; ok {
+        continue
+      }
+
+      err = m.run(ctx, conn, migration.Up, m.dialect.insertVersion, migration.Version,
+        migration.Name, time.Now().UTC())
+      if err != nil {
+        return fmt.Errorf("migrations: applying %s: %w", migration, err)
+      }
+
+      done = append(done, migration)
+    }
+
+    return nil
+  })
+
+  return done, err
+}
+
+// Down() rolls back the most recently applied migration and returns it. If no
+// migrations have been applied it returns ErrNoMigrations.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+  var migration Migration
+
+  err := m.withLock(ctx, func(conn *sql.Conn) error {
+    applied, err := m.appliedVersions(ctx, conn)
+    if err != nil {
+      return err
+    }
+
+    // Find the latest applied migration which we know about.
+    found := false
+    for _, mg := range slices.Backward(m.Migrations) {
+      if _, ok := applied[mg.Version]; ok {
+        migration, found = mg, true
+        break
+      }
+    }
+    if !found {
+      return ErrNoMigrations
+    }
+
+    err = m.run(ctx, conn, migration.Down, m.dialect.deleteVersion, migration.Version)
+    if err != nil {
+      return fmt.Errorf("migrations: rolling back %s: %w", migration, err)
+    }
+
+    return nil
+  })
+
+  return migration, err
+}
+
+// Baseline() records the first migration as applied without running it, for a
+// database whose tables were created by hand before snippetbox had migrations.
+// The schema must match the first migration: Baseline() doesn't check. If any
+// migrations have already been applied it returns ErrAlreadyVersioned.
+func (m *Migrator) Baseline(ctx context.Context) (Migration, error) {
+  if len(m.Migrations) == 0 {
+    return Migration{}, ErrNoMigrations
+  }
+  migration := m.Migrations[0]
+
+  err := m.withLock(ctx, func(conn *sql.Conn) error {
+    applied, err := m.appliedVersions(ctx, conn)
+    if err != nil {
+      return err
+    }
+    if len(applied) > 0 {
+      return ErrAlreadyVersioned
+    }
+
+    // An empty script just records the migration.
+    err = m.run(ctx, conn, "", m.dialect.insertVersion, migration.Version,
+      migration.Name, time.Now().UTC())
+    if err != nil {
+      return fmt.Errorf("migrations: recording %s: %w", migration, err)
+    }
+
+    return nil
+  })
+
+  return migration, err
+}
+
+// execer is satisfied by both *sql.Conn and *sql.Tx.
+type execer interface {
+  ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// run() runs each of the statements in a migration script, and then the
+// statement which records that it has been applied or rolled back. If the
+// database supports transactional schema changes, it's all done in one
+// transaction.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+  var (
+    db execer = conn
+    tx *sql.Tx
+    err error
+  )
+
+  if m.dialect.transactional {
+    tx, err = conn.BeginTx(ctx, nil)
+    if err != nil {
+      return err
+    }
+    defer tx.Rollback()
+    db = tx
+  }
+
+  // The driver only runs one statement per call (unless multiStatements is
+  // set in a MySQL DSN, which we don't want to require), so we split the
+  // script up ourselves.
+  for _, stmt := range splitStatements(script) {
+    _, err = db.ExecContext(ctx, stmt)
+    if err != nil {
+      return err
+    }
+  }
+
+  _, err = db.ExecContext(ctx, record, args...)
+  if err != nil {
+    return err
+  }
+
+  if tx != nil {
+    return tx.Commit()
+  }
+  return nil
+}
+
+// withLock() runs fn while holding the advisory lock, so that only one
+// process changes the schema at a time. Advisory locks belong to a
+// connection, so fn is given the connection which holds the lock and must do
+// all of its work on it.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+  conn, err := m.DB.Conn(ctx)
+  if err != nil {
+    return err
+  }
+  defer conn.Close()
+
+  err = m.dialect.lock(ctx, conn, m.LockTimeout)
+  if err != nil {
+    return err
+  }
+
+  // Release the lock with a fresh context, so that it's released even if ctx
+  // has been cancelled. If that fails the lock is released anyway when the
+  // connection is closed.
+  defer m.dialect.unlock(context.Background(), conn)
+
+  _, err = conn.ExecContext(ctx, m.dialect.createTable)
+  if err != nil {
+    return err
+  }
+
+  return fn(conn)
+}
+
+// appliedVersions() returns the versions of the applied migrations, mapped to
+// when they were applied. If the schema_migrations table doesn't exist yet,
+// nothing has been applied.
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+  var exists bool
+  err := conn.QueryRowContext(ctx, m.dialect.tableExists).Scan(&exists)
+  if err != nil {
+    return nil, err
+  }
+
+  applied := map[int]time.Time{}
+  if !exists {
+    return applied, nil
+  }
+
+  rows, err := conn.QueryContext(ctx, "SELECT version, applied FROM schema_migrations")
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  for rows.Next() {
+    var (
+      version int
+      at      time.Time
+    )
+    err = rows.Scan(&version, &at)
+    if err != nil {
+      return nil, err
+    }
+    applied[version] = at
+  }
+
+  return applied, rows.Err()
+}
+
+// splitStatements() splits a script into statements. A statement ends with a
+// semicolon at the end of a line, so semicolons inside a line (in a string,
+// say) are left alone. Lines which only contain comments are dropped.
+func splitStatements(script string) []string {
+  var (
+    stmts   []string
+    current strings.Builder
+  )
+
+  for line := range strings.Lines(script) {
+    trimmed := strings.TrimSpace(line)
+    if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+      continue
+    }
+
+    if stmt, ok := strings.CutSuffix(trimmed, ";"); ok {
+      current.WriteString(stmt)
+      stmts = append(stmts, current.String())
+      current.Reset()
+      continue
+    }
+
+    current.WriteString(line)
+  }
+
+  // Allow the last statement to leave out its semicolon.
+  if s := strings.TrimSpace(current.String()); s != "" {
+    stmts = append(stmts, s)
+  }
+
+  return stmts
+}
@@ -0,0 +1,127 @@
+package migrations
+
+import (
+  "strings"
+  "testing"
+  "testing/fstest"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+)
+
+func TestLoad(t *testing.T) {
+  tests := []struct {
+    name    string
+    files   fstest.MapFS
+    want    string
+    wantErr string
+  }{
+    {
+      name: "Sorted by version",
+      files: fstest.MapFS{
+        "0010_add_tokens.up.sql":     {Data: []byte("create table tokens (id integer);")},
+        "0010_add_tokens.down.sql":   {Data: []byte("drop table tokens;")},
+        "0002_add_users.up.sql":      {Data: []byte("create table users (id integer);")},
+        "0002_add_users.down.sql":    {Data: []byte("drop table users;")},
+        "README.md":                  {Data: []byte("not a migration")},
+      },
+      want: "0002_add_users,0010_add_tokens",
+    },
+    {
+      name: "Missing down file",
+      files: fstest.MapFS{
+        "0001_add_users.up.sql": {Data: []byte("create table users (id integer);")},
+      },
+      wantErr: "migrations: migration 0001_add_users needs both an up and a down file",
+    },
+    {
+      name: "Duplicate version",
+      files: fstest.MapFS{
+        "0001_add_users.up.sql":    {Data: []byte("create table users (id integer);")},
+        "0001_add_users.down.sql":  {Data: []byte("drop table users;")},
+        "0001_add_tokens.up.sql":   {Data: []byte("create table tokens (id integer);")},
+        "0001_add_tokens.down.sql": {Data: []byte("drop table tokens;")},
+      },
+      wantErr: `migrations: version 1 is used by both "add_tokens" and "add_users"`,
+    },
+    {
+      name: "Invalid name",
+      files: fstest.MapFS{
+        "add_users.sql": {Data: []byte("create table users (id integer);")},
+      },
+      wantErr: `migrations: invalid file name "add_users.sql"`,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      migrations, err := Load(tt.files)
+
+      if tt.wantErr != "" {
+        if err == nil {
+          t.Fatalf("got no error; want %q", tt.wantErr)
+        }
+        assert.Equal(t, err.Error(), tt.wantErr)
+        return
+      }
+
+      assert.NilError(t, err)
+
+      var names []string
+      for _, m := range migrations {
+        names = append(names, m.String())
+      }
+      assert.Equal(t, strings.Join(names, ","), tt.want)
+    })
+  }
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+  var names []string
+
+  for _, database := range []string{MySQL, Postgres, SQLite} {
+    t.Run(database, func(t *testing.T) {
+      m, err := New(nil, database)
+      assert.NilError(t, err)
+
+      // Versions should start at 1 and have no gaps, so that it's obvious if
+      // a migration goes missing.
+      var got []string
+      for i, migration := range m.Migrations {
+        assert.Equal(t, migration.Version, i+1)
+        if len(splitStatements(migration.Up)) == 0 || len(splitStatements(migration.Down)) == 0 {
+          t.Errorf("migration %s is empty", migration)
+        }
+        got = append(got, migration.String())
+      }
+
+      // Every database should have the same migrations.
+      if names == nil {
+        names = got
+      }
+      assert.Equal(t, strings.Join(got, ","), strings.Join(names, ","))
+    })
+  }
+
+  _, err := New(nil, "oracle")
+  if err == nil {
+    t.Error("got no error for an unsupported database")
+  }
+}
+
+func TestSplitStatements(t *testing.T) {
+  script := `-- A comment; with a semicolon.
+create table users (
+  id integer not null,
+  name varchar(255) not null default 'a;b'
+);
+
+create index idx_users_name on users(name);
+drop table old_users`
+
+  stmts := splitStatements(script)
+
+  assert.Equal(t, len(stmts), 3)
+  assert.Equal(t, stmts[0], "create table users (\n  id integer not null,\n  name varchar(255) not null default 'a;b'\n)")
+  assert.Equal(t, stmts[1], "create index idx_users_name on users(name)")
+  assert.Equal(t, stmts[2], "drop table old_users")
+}
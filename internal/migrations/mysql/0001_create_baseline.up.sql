@@ -0,0 +1,33 @@
+-- This is the schema which snippetbox had before it had migrations. A database
+-- which was set up by hand from it can adopt migrations with
+-- "snippetadmin migrate baseline", which records this migration as applied
+-- without running it. The migrations after this one bring the schema up to
+-- date.
+create table snippets (
+  id integer not null primary key auto_increment,
+  title varchar(100) not null,
+  content text not null,
+  created datetime not null,
+  expires datetime not null
+);
+
+create index idx_snippets_created on snippets(created);
+
+create table users (
+  id integer not null primary key auto_increment,
+  name varchar(255) not null,
+  email varchar(255) not null,
+  hashed_password char(60) not null,
+  created datetime not null
+);
+
+alter table users add constraint users_uc_email unique (email);
+
+-- The sessions table is used by the mysqlstore package to store session data.
+create table sessions (
+  token char(43) primary key,
+  data blob not null,
+  expiry timestamp(6) not null
+);
+
+create index sessions_expiry_idx on sessions (expiry);
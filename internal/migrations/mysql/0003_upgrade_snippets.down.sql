@@ -0,0 +1,22 @@
+drop table burned_snippets;
+
+alter table snippets drop foreign key fk_snippets_user_id;
+
+alter table snippets
+  drop index idx_snippets_fulltext,
+  drop index idx_snippets_expires,
+  drop index snippets_uc_slug;
+
+-- Snippets had to expire before, so the ones which don't are given an expiry
+-- date so far in the future that it makes no difference.
+update snippets set expires = '9999-12-31 23:59:59' where expires is null;
+
+alter table snippets
+  drop column slug,
+  drop column language,
+  drop column visibility,
+  drop column burn_after_reading,
+  drop column user_id,
+  modify column expires datetime not null;
+
+delete from users where email = 'anonymous@snippetbox.invalid';
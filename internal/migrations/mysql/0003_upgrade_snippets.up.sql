@@ -0,0 +1,72 @@
+-- Snippets get an author, a random slug which is used in their URLs in place
+-- of their ID, a language, a visibility and a burn-after-reading option. They
+-- can also be searched, and they no longer have to expire. The new columns
+-- which can't be empty are added as nullable first, so that existing snippets
+-- can be filled in before they're made not null.
+alter table snippets
+  add column slug char(8) character set ascii collate ascii_bin after id,
+  add column language varchar(32) not null default '' after content,
+  add column visibility varchar(8) not null default 'public' after language,
+  add column burn_after_reading boolean not null default false after visibility,
+  add column user_id integer,
+  modify column expires datetime;
+
+-- Existing snippets get random slugs, like new ones, so that they can't be
+-- guessed from their IDs. The first character is always a letter, so that
+-- the slug is never all digits. The old numeric URLs still work, because they
+-- redirect to the slug. Two snippets are very unlikely to get the same slug,
+-- but if they do, the second statement gives those snippets new ones before
+-- the unique constraint is added.
+update snippets set slug = concat(
+    substring('ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 52, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1));
+
+update snippets s
+  inner join (select slug from snippets group by slug having count(*) > 1) d
+    on d.slug = s.slug
+  set s.slug = concat(
+    substring('ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 52, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1),
+    substring('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + ord(random_bytes(1)) % 62, 1));
+
+-- Existing snippets didn't record who created them, so they're given to a
+-- placeholder user. The placeholder is disabled, so nobody can log in as
+-- them, and is only added if there are snippets for it to own.
+insert into users (name, email, hashed_password, created, disabled)
+  select 'Anonymous', 'anonymous@snippetbox.invalid', repeat('!', 60), utc_timestamp(), true
+  from dual where exists (select 1 from snippets);
+
+update snippets set user_id = (select id from users
+  where email = 'anonymous@snippetbox.invalid');
+
+alter table snippets
+  modify column slug char(8) character set ascii collate ascii_bin not null,
+  modify column user_id integer not null;
+
+alter table snippets add constraint snippets_uc_slug unique (slug);
+
+create index idx_snippets_expires on snippets(expires);
+
+create fulltext index idx_snippets_fulltext on snippets(title, content);
+
+alter table snippets add constraint fk_snippets_user_id foreign key (user_id)
+  references users(id);
+
+-- Remember the slugs of burn-after-reading snippets which have been read, so
+-- that we can tell people the snippet is gone rather than that it never
+-- existed.
+create table burned_snippets (
+  slug char(8) character set ascii collate ascii_bin not null primary key,
+  burned datetime not null
+);
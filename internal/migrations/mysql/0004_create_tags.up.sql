@@ -0,0 +1,18 @@
+create table tags (
+  id integer not null primary key auto_increment,
+  name varchar(32) not null
+);
+
+alter table tags add constraint tags_uc_name unique (name);
+
+create table snippet_tags (
+  snippet_id integer not null,
+  tag_id integer not null,
+  primary key (snippet_id, tag_id)
+);
+
+alter table snippet_tags add constraint fk_snippet_tags_snippet_id
+  foreign key (snippet_id) references snippets(id) on delete cascade;
+
+alter table snippet_tags add constraint fk_snippet_tags_tag_id
+  foreign key (tag_id) references tags(id) on delete cascade;
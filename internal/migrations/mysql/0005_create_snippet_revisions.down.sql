@@ -0,0 +1 @@
+drop table snippet_revisions;
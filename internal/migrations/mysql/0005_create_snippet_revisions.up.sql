@@ -0,0 +1,18 @@
+create table snippet_revisions (
+  id integer not null primary key auto_increment,
+  snippet_id integer not null,
+  version integer not null,
+  title varchar(100) not null,
+  content text not null,
+  user_id integer not null,
+  created datetime not null
+);
+
+alter table snippet_revisions add constraint snippet_revisions_uc_version
+  unique (snippet_id, version);
+
+alter table snippet_revisions add constraint fk_snippet_revisions_snippet_id
+  foreign key (snippet_id) references snippets(id) on delete cascade;
+
+alter table snippet_revisions add constraint fk_snippet_revisions_user_id
+  foreign key (user_id) references users(id);
@@ -0,0 +1,15 @@
+create table tokens (
+  id integer not null primary key auto_increment,
+  user_id integer not null,
+  name varchar(100) not null,
+  scope varchar(8) not null default 'read',
+  hash binary(32) not null,
+  created datetime not null,
+  expires datetime,
+  last_used datetime
+);
+
+alter table tokens add constraint tokens_uc_hash unique (hash);
+
+alter table tokens add constraint fk_tokens_user_id foreign key (user_id)
+  references users(id) on delete cascade;
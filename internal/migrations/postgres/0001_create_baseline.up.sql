@@ -0,0 +1,31 @@
+-- This is the schema which snippetbox had before it had migrations, written
+-- for Postgres. The migrations after this one bring it up to date, in the
+-- same steps as the MySQL migrations.
+create table snippets (
+  id integer not null primary key generated by default as identity,
+  title varchar(100) not null,
+  content text not null,
+  created timestamp not null,
+  expires timestamp not null
+);
+
+create index idx_snippets_created on snippets(created);
+
+create table users (
+  id integer not null primary key generated by default as identity,
+  name varchar(255) not null,
+  email varchar(255) not null,
+  hashed_password char(60) not null,
+  created timestamp not null
+);
+
+alter table users add constraint users_uc_email unique (email);
+
+-- The sessions table is used by the postgresstore package to store session data.
+create table sessions (
+  token text primary key,
+  data bytea not null,
+  expiry timestamptz not null
+);
+
+create index sessions_expiry_idx on sessions (expiry);
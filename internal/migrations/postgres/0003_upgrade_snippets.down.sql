@@ -0,0 +1,17 @@
+drop table burned_snippets;
+
+-- Snippets had to expire before, so the ones which don't are given an expiry
+-- date so far in the future that it makes no difference. Dropping the columns
+-- drops their indexes and constraints too.
+update snippets set expires = '9999-12-31 23:59:59' where expires is null;
+
+alter table snippets
+  drop column slug,
+  drop column language,
+  drop column visibility,
+  drop column burn_after_reading,
+  drop column user_id,
+  drop column search,
+  alter column expires set not null;
+
+delete from users where email = 'anonymous@snippetbox.invalid';
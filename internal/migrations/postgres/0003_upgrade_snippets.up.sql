@@ -0,0 +1,77 @@
+-- Snippets get an author, a random slug which is used in their URLs in place
+-- of their ID, a language, a visibility and a burn-after-reading option. They
+-- no longer have to expire. The search column holds the words in the title
+-- and content, for full-text search, and Postgres keeps it up to date
+-- whenever a snippet changes. The new columns which can't be empty are added
+-- as nullable first, so that existing snippets can be filled in before
+-- they're made not null.
+alter table snippets
+  add column slug char(8) collate "C",
+  add column language varchar(32) not null default '',
+  add column visibility varchar(8) not null default 'public',
+  add column burn_after_reading boolean not null default false,
+  add column user_id integer,
+  add column search tsvector generated always as
+    (to_tsvector('english', title || ' ' || content)) stored,
+  alter column expires drop not null;
+
+-- Existing snippets get random slugs, like new ones, so that they can't be
+-- guessed from their IDs. Each character comes from a byte of a random UUID,
+-- which Postgres makes with a secure random number generator. The first
+-- character is always a letter, so that the slug is never all digits. The
+-- old numeric URLs still work, because they redirect to the slug. Two
+-- snippets are very unlikely to get the same slug, but if they do, the second
+-- statement gives those snippets new ones before the unique constraint is
+-- added.
+update snippets set slug =
+    substr('ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 52, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1);
+
+update snippets set slug =
+    substr('ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 52, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + get_byte(uuid_send(gen_random_uuid()), 0) % 62, 1)
+  where slug in (select slug from snippets group by slug having count(*) > 1);
+
+-- Existing snippets didn't record who created them, so they're given to a
+-- placeholder user. The placeholder is disabled, so nobody can log in as
+-- them, and is only added if there are snippets for it to own.
+insert into users (name, email, hashed_password, created, disabled)
+  select 'Anonymous', 'anonymous@snippetbox.invalid', repeat('!', 60),
+    now() at time zone 'utc', true
+  where exists (select 1 from snippets);
+
+update snippets set user_id = (select id from users
+  where email = 'anonymous@snippetbox.invalid');
+
+alter table snippets
+  alter column slug set not null,
+  alter column user_id set not null;
+
+alter table snippets add constraint snippets_uc_slug unique (slug);
+
+create index idx_snippets_expires on snippets(expires);
+
+create index idx_snippets_search on snippets using gin (search);
+
+alter table snippets add constraint fk_snippets_user_id foreign key (user_id)
+  references users(id);
+
+-- Remember the slugs of burn-after-reading snippets which have been read, so
+-- that we can tell people the snippet is gone rather than that it never
+-- existed.
+create table burned_snippets (
+  slug char(8) collate "C" not null primary key,
+  burned timestamp not null
+);
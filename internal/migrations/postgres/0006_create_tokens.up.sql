@@ -0,0 +1,15 @@
+create table tokens (
+  id integer not null primary key generated by default as identity,
+  user_id integer not null,
+  name varchar(100) not null,
+  scope varchar(8) not null default 'read',
+  hash bytea not null,
+  created timestamp not null,
+  expires timestamp,
+  last_used timestamp
+);
+
+alter table tokens add constraint tokens_uc_hash unique (hash);
+
+alter table tokens add constraint fk_tokens_user_id foreign key (user_id)
+  references users(id) on delete cascade;
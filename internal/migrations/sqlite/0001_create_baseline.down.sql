@@ -0,0 +1,5 @@
+drop table sessions;
+
+drop table users;
+
+drop table snippets;
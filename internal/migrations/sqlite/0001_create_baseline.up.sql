@@ -0,0 +1,34 @@
+-- This is the schema which snippetbox had before it had migrations, written
+-- for SQLite. The migrations after this one bring it up to date, in the same
+-- steps as the MySQL migrations.
+--
+-- SQLite doesn't support adding constraints with alter table, so they're
+-- declared along with the columns. Declaring the timestamp columns as datetime
+-- tells the driver to read them back as times.
+create table snippets (
+  id integer not null primary key,
+  title varchar(100) not null,
+  content text not null,
+  created datetime not null,
+  expires datetime not null
+);
+
+create index idx_snippets_created on snippets(created);
+
+create table users (
+  id integer not null primary key,
+  name varchar(255) not null,
+  email varchar(255) not null constraint users_uc_email unique,
+  hashed_password char(60) not null,
+  created datetime not null
+);
+
+-- The sessions table is used by the sqlite3store package to store session
+-- data. It stores expiry times as Julian day numbers.
+create table sessions (
+  token text primary key,
+  data blob not null,
+  expiry real not null
+);
+
+create index sessions_expiry_idx on sessions (expiry);
@@ -0,0 +1,28 @@
+drop table burned_snippets;
+
+-- Dropping the snippets table drops the triggers which keep the search index
+-- up to date, so the index goes first.
+drop table snippets_search;
+
+-- Put the old snippets table back, and copy the snippets into it. Snippets had
+-- to expire before, so the ones which don't are given an expiry date so far
+-- in the future that it makes no difference.
+create table snippets_old (
+  id integer not null primary key,
+  title varchar(100) not null,
+  content text not null,
+  created datetime not null,
+  expires datetime not null
+);
+
+insert into snippets_old (id, title, content, created, expires)
+  select id, title, content, created, coalesce(expires, '9999-12-31 23:59:59+00:00')
+  from snippets;
+
+drop table snippets;
+
+alter table snippets_old rename to snippets;
+
+create index idx_snippets_created on snippets(created);
+
+delete from users where email = 'anonymous@snippetbox.invalid';
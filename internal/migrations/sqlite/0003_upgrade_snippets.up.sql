@@ -0,0 +1,100 @@
+-- Snippets get an author, a random slug which is used in their URLs in place
+-- of their ID, a language, a visibility and a burn-after-reading option. They
+-- can also be searched, and they no longer have to expire. SQLite can't
+-- change the constraints on a table, so we make a new snippets table and copy
+-- the existing snippets into it.
+
+-- Existing snippets didn't record who created them, so they're given to a
+-- placeholder user. The placeholder is disabled, so nobody can log in as
+-- them, and is only added if there are snippets for it to own.
+insert into users (name, email, hashed_password, created, disabled)
+  select 'Anonymous', 'anonymous@snippetbox.invalid', printf('%.60c', '!'),
+    strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'), true
+  where exists (select 1 from snippets);
+
+create table snippets_new (
+  id integer not null primary key,
+  slug char(8) not null constraint snippets_uc_slug unique,
+  title varchar(100) not null,
+  content text not null,
+  language varchar(32) not null default '',
+  visibility varchar(8) not null default 'public',
+  burn_after_reading boolean not null default false,
+  created datetime not null,
+  expires datetime,
+  user_id integer not null constraint fk_snippets_user_id references users(id)
+);
+
+-- Existing snippets are copied across with their IDs as placeholder slugs,
+-- which can't clash with each other, and then given random slugs, like new
+-- ones, so that they can't be guessed from their IDs. The first character is
+-- always a letter, so that the slug is never all digits. The old numeric URLs
+-- still work, because they redirect to the slug.
+insert into snippets_new (id, slug, title, content, created, expires, user_id)
+  select id, cast(id as text), title, content, created, expires,
+    (select id from users where email = 'anonymous@snippetbox.invalid')
+  from snippets;
+
+-- Two snippets are very unlikely to get the same slug, but if they do, "or
+-- ignore" skips the second one, which keeps its placeholder. The second
+-- statement tries again for any snippets which still have one.
+update or ignore snippets_new set slug =
+    substr('ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 52, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1);
+
+update or ignore snippets_new set slug =
+    substr('ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 52, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', 1 + (random() & 255) % 62, 1)
+  where slug not glob '*[A-Za-z]*';
+
+drop table snippets;
+
+alter table snippets_new rename to snippets;
+
+create index idx_snippets_created on snippets(created);
+
+create index idx_snippets_expires on snippets(expires);
+
+-- The snippets_search table is an FTS5 full-text index of the title and
+-- content of each snippet, using the snippet's ID as its rowid. It doesn't
+-- hold a copy of the text, so the triggers below keep it up to date whenever
+-- a snippet changes. Their bodies are written on one line because migration
+-- scripts are split into statements at semicolons which end a line.
+create virtual table snippets_search using fts5(
+  title, content, content='snippets', content_rowid='id', tokenize='porter unicode61'
+);
+
+create trigger snippets_search_insert after insert on snippets begin
+  insert into snippets_search (rowid, title, content) values (new.id, new.title, new.content); end;
+
+create trigger snippets_search_delete after delete on snippets begin
+  insert into snippets_search (snippets_search, rowid, title, content) values ('delete', old.id, old.title, old.content); end;
+
+create trigger snippets_search_update_old before update of title, content on snippets begin
+  insert into snippets_search (snippets_search, rowid, title, content) values ('delete', old.id, old.title, old.content); end;
+
+create trigger snippets_search_update_new after update of title, content on snippets begin
+  insert into snippets_search (rowid, title, content) values (new.id, new.title, new.content); end;
+
+-- Index the snippets which were copied across.
+insert into snippets_search (snippets_search) values ('rebuild');
+
+-- Remember the slugs of burn-after-reading snippets which have been read, so
+-- that we can tell people the snippet is gone rather than that it never
+-- existed.
+create table burned_snippets (
+  slug char(8) not null primary key,
+  burned datetime not null
+);
@@ -0,0 +1,3 @@
+drop table snippet_tags;
+
+drop table tags;
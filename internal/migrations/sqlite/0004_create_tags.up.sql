@@ -0,0 +1,12 @@
+create table tags (
+  id integer not null primary key,
+  name varchar(32) not null constraint tags_uc_name unique
+);
+
+create table snippet_tags (
+  snippet_id integer not null constraint fk_snippet_tags_snippet_id
+    references snippets(id) on delete cascade,
+  tag_id integer not null constraint fk_snippet_tags_tag_id
+    references tags(id) on delete cascade,
+  primary key (snippet_id, tag_id)
+);
@@ -0,0 +1,12 @@
+create table snippet_revisions (
+  id integer not null primary key,
+  snippet_id integer not null constraint fk_snippet_revisions_snippet_id
+    references snippets(id) on delete cascade,
+  version integer not null,
+  title varchar(100) not null,
+  content text not null,
+  user_id integer not null constraint fk_snippet_revisions_user_id
+    references users(id),
+  created datetime not null,
+  constraint snippet_revisions_uc_version unique (snippet_id, version)
+);
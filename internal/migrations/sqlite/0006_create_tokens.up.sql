@@ -0,0 +1,11 @@
+create table tokens (
+  id integer not null primary key,
+  user_id integer not null constraint fk_tokens_user_id
+    references users(id) on delete cascade,
+  name varchar(100) not null,
+  scope varchar(8) not null default 'read',
+  hash blob not null constraint tokens_uc_hash unique,
+  created datetime not null,
+  expires datetime,
+  last_used datetime
+);
@@ -0,0 +1,128 @@
+package models
+
+import (
+  "context"
+  "database/sql"
+  "fmt"
+)
+
+// The AdminModelInterface and AdminUserModelInterface interfaces describe what
+// the snippetadmin command needs from the database, so that it can work with
+// any of them. UserModel has the extra methods for managing users.
+type AdminModelInterface interface {
+  Snippets(ctx context.Context, userID, limit int) ([]Snippet, error)
+  DeleteSnippet(ctx context.Context, slug string) error
+  PurgeUserSnippets(ctx context.Context, userID int) (int, error)
+  Stats(ctx context.Context) ([]TableStats, error)
+}
+
+type AdminUserModelInterface interface {
+  UserModelInterface
+  All(ctx context.Context) ([]User, error)
+  SetDisabled(ctx context.Context, id int, disabled bool) error
+  SetPassword(ctx context.Context, id int, password string) error
+  Delete(ctx context.Context, id int) error
+}
+
+// Define an AdminModel type for the queries which only the snippetadmin
+// command needs. Unlike the SnippetModel methods, these ignore visibility,
+// expiry and burn-after-reading, because administrators need to see and clean
+// up everything.
+type AdminModel struct {
+  DB      *sql.DB
+  Dialect string
+}
+
+// Define a TableStats type to hold the size of a database table. Rows is an
+// exact count, but DataBytes and IndexBytes are the database's estimates.
+type TableStats struct {
+  Name       string
+  Rows       int
+  DataBytes  int64
+  IndexBytes int64
+}
+
+// The Snippets method returns the latest snippets, newest first, whatever
+// their visibility and even if they've expired. If userID isn't zero only
+// that user's snippets are returned.
+func (m *AdminModel) Snippets(ctx context.Context, userID, limit int) ([]Snippet, error) {
+  stmt := snippetSelect + ` WHERE (? = 0 OR s.user_id = ?)
+  ORDER BY s.id DESC LIMIT ?`
+
+  snippets := &SnippetModel{DB: m.DB, Dialect: m.Dialect}
+  return snippets.querySnippets(ctx, stmt, userID, userID, limit)
+}
+
+// The DeleteSnippet method deletes the snippet with the given slug. Its tags
+// and revisions are deleted by the database. It returns ErrNoRecord if there
+// is no matching snippet.
+func (m *AdminModel) DeleteSnippet(ctx context.Context, slug string) error {
+  result, err := m.DB.ExecContext(ctx, dialectFor(m.Dialect).rebind("DELETE FROM snippets WHERE slug = ?"), slug)
+  if err != nil {
+    return err
+  }
+
+  n, err := result.RowsAffected()
+  if err != nil {
+    return err
+  }
+  if n == 0 {
+    return ErrNoRecord
+  }
+
+  return nil
+}
+
+// The PurgeUserSnippets method deletes every snippet belonging to a user (for
+// example, a spammer), and returns how many were deleted.
+func (m *AdminModel) PurgeUserSnippets(ctx context.Context, userID int) (int, error) {
+  result, err := m.DB.ExecContext(ctx, dialectFor(m.Dialect).rebind("DELETE FROM snippets WHERE user_id = ?"),
+    userID)
+  if err != nil {
+    return 0, err
+  }
+
+  n, err := result.RowsAffected()
+  return int(n), err
+}
+
+// The Stats method returns the size of every table in the database, in
+// alphabetical order.
+func (m *AdminModel) Stats(ctx context.Context) ([]TableStats, error) {
+  d := dialectFor(m.Dialect)
+
+  rows, err := m.DB.QueryContext(ctx, d.tableStats)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  var stats []TableStats
+
+  for rows.Next() {
+    var t TableStats
+    err = rows.Scan(&t.Name, &t.DataBytes, &t.IndexBytes)
+    if err != nil {
+      return nil, err
+    }
+    stats = append(stats, t)
+  }
+
+  if err = rows.Err(); err != nil {
+    return nil, err
+  }
+
+  // The databases only keep estimates of the number of rows, so count the
+  // rows properly. The table names come straight from the database, so it's
+  // safe to put them in the query.
+  for i, t := range stats {
+    stmt := fmt.Sprintf("SELECT COUNT(*) FROM %s", d.quote(t.Name))
+
+    err = m.DB.QueryRowContext(ctx, stmt).Scan(&stats[i].Rows)
+    if err != nil {
+      return nil, err
+    }
+  }
+
+  return stats, nil
+}
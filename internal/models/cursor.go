@@ -0,0 +1,56 @@
+package models
+
+import (
+  "encoding/base64"
+  "fmt"
+  "strconv"
+  "strings"
+)
+
+// Define a Cursor type to mark a position in a paginated list of snippets.
+// Listings use keyset pagination: rather than skipping a number of rows with
+// OFFSET, the next page starts from the ID of the last snippet on the current
+// page. Backward is set when the cursor points back towards the previous
+// page, rather than on to the next one.
+type Cursor struct {
+  ID       int
+  Backward bool
+}
+
+// Encode() returns the cursor as an opaque, URL-safe string, suitable for
+// using in query strings.
+func (c Cursor) Encode() string {
+  direction := "n"
+  if c.Backward {
+    direction = "p"
+  }
+
+  s := fmt.Sprintf("%s:%d", direction, c.ID)
+  return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// DecodeCursor() parses a string produced by Cursor.Encode(). An empty string
+// decodes to the zero Cursor, which means "start from the first page". If the
+// string isn't a valid cursor we return the ErrInvalidCursor error.
+func DecodeCursor(s string) (Cursor, error) {
+  if s == "" {
+    return Cursor{}, nil
+  }
+
+  b, err := base64.RawURLEncoding.DecodeString(s)
+  if err != nil {
+    return Cursor{}, ErrInvalidCursor
+  }
+
+  direction, idString, ok := strings.Cut(string(b), ":")
+  if !ok || (direction != "n" && direction != "p") {
+    return Cursor{}, ErrInvalidCursor
+  }
+
+  id, err := strconv.Atoi(idString)
+  if err != nil || id < 1 {
+    return Cursor{}, ErrInvalidCursor
+  }
+
+  return Cursor{ID: id, Backward: direction == "p"}, nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+  "testing"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+)
+
+func TestCursor(t *testing.T) {
+  tests := []struct {
+    name   string
+    cursor Cursor
+  }{
+    {
+      name:   "Forward",
+      cursor: Cursor{ID: 42},
+    },
+    {
+      name:   "Backward",
+      cursor: Cursor{ID: 7, Backward: true},
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      c, err := DecodeCursor(tt.cursor.Encode())
+
+      assert.NilError(t, err)
+      assert.Equal(t, c, tt.cursor)
+    })
+  }
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+  // These are "!!!" (not base64), "x:1", "n:-1", "n:abc" and "n1".
+  for _, s := range []string{"!!!", "eDox", "bjotMQ", "bjphYmM", "bjE"} {
+    _, err := DecodeCursor(s)
+    assert.Equal(t, err, ErrInvalidCursor)
+  }
+
+  c, err := DecodeCursor("")
+  assert.NilError(t, err)
+  assert.Equal(t, c, Cursor{})
+}
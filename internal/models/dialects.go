@@ -0,0 +1,230 @@
+package models
+
+import (
+  "context"
+  "database/sql"
+  "errors"
+  "strconv"
+  "strings"
+
+  "github.com/kjloveless/snippetbox/internal/migrations"
+
+  "github.com/go-sql-driver/mysql"
+  "github.com/jackc/pgx/v5/pgconn"
+  "modernc.org/sqlite"
+  sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Define a dialect type to hold the SQL which differs between databases. The
+// queries in this package are written for MySQL, and rebind() adapts them to
+// the other databases. The fields below cover the few statements which need
+// more than that.
+type dialect struct {
+  // numbered is true if placeholders are numbered, like $1, rather than all
+  // being written as ?.
+  numbered bool
+  // now is the current time in UTC, which takes the place of
+  // UTC_TIMESTAMP(). Our timestamp columns don't store a time zone, so we
+  // always store UTC in them.
+  now string
+  // returning is true if an INSERT returns the ID of the new row with a
+  // RETURNING clause, rather than through LastInsertId().
+  returning bool
+  // skipConflicts is added to an INSERT to skip a row which would break a
+  // unique constraint, rather than failing. A failed statement aborts the
+  // whole transaction in Postgres, so we can't let it fail and try again.
+  // MySQL only rolls back the failed statement, so there we don't need to.
+  skipConflicts string
+  // upsertTag inserts a tag, or does nothing if it already exists, and
+  // either way gives insertID() the ID of the tag.
+  upsertTag string
+  // forUpdate locks the rows read by a SELECT until the end of the
+  // transaction.
+  forUpdate string
+  // purgeExpired deletes up to a given number of expired snippets, oldest
+  // first.
+  purgeExpired string
+  // textMatch and textScore are the full-text search condition and relevance
+  // score for Search(). Each has a placeholder for the search text, which is
+  // the query as it was given, unless searchText() is set to make it from
+  // the words in the query.
+  textMatch  string
+  textScore  string
+  searchText func(terms []string) string
+  // tableStats lists the tables in the database with their data and index
+  // sizes, and quote quotes a table name.
+  tableStats string
+  quote      func(name string) string
+}
+
+var dialects = map[string]dialect{
+  // If a tag already exists, MySQL's ON DUPLICATE KEY UPDATE clause sets the
+  // value returned by LastInsertId() to the ID of the existing row.
+  migrations.MySQL: {
+    now: "UTC_TIMESTAMP()",
+    upsertTag: `INSERT INTO tags (name) VALUES (?)
+    ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`,
+    forUpdate:    "FOR UPDATE",
+    purgeExpired: "DELETE FROM snippets WHERE expires < ? ORDER BY expires LIMIT ?",
+    textMatch:    "MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE)",
+    textScore:    "MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE)",
+    tableStats: `SELECT table_name, data_length, index_length
+    FROM information_schema.tables
+    WHERE table_schema = DATABASE()
+    ORDER BY table_name`,
+    quote: func(name string) string { return "`" + name + "`" },
+  },
+  // ON CONFLICT DO NOTHING wouldn't return the ID of an existing tag, so
+  // Postgres and SQLite do a no-op update instead, which does. Neither
+  // supports ORDER BY and LIMIT on a DELETE, so we pick out the expired
+  // snippets with a subquery.
+  //
+  // Postgres' full-text search uses the search column, which holds the words
+  // in each snippet's title and content, and plainto_tsquery() turns the
+  // query into words to look for. pg_table_size() and pg_indexes_size() give
+  // the space each table and its indexes take up on disk.
+  migrations.Postgres: {
+    numbered:      true,
+    now:           "(now() AT TIME ZONE 'utc')",
+    returning:     true,
+    skipConflicts: "ON CONFLICT DO NOTHING",
+    upsertTag: `INSERT INTO tags (name) VALUES (?)
+    ON CONFLICT (name) DO UPDATE SET name = excluded.name`,
+    forUpdate: "FOR UPDATE OF s",
+    purgeExpired: `DELETE FROM snippets WHERE id IN (
+      SELECT id FROM snippets WHERE expires < ? ORDER BY expires LIMIT ?
+    )`,
+    textMatch: "s.search @@ plainto_tsquery('english', ?)",
+    textScore: "ts_rank(s.search, plainto_tsquery('english', ?))",
+    tableStats: `SELECT c.relname, pg_table_size(c.oid), pg_indexes_size(c.oid)
+    FROM pg_class c
+    INNER JOIN pg_namespace n ON n.oid = c.relnamespace
+    WHERE n.nspname = current_schema() AND c.relkind = 'r'
+    ORDER BY c.relname`,
+    quote: doubleQuote,
+  },
+  // SQLite has no SELECT ... FOR UPDATE, but every transaction takes the
+  // write lock when it begins (see database.SQLiteDSN()), which does the same
+  // job. Its full-text search uses an FTS5 index, snippets_search, in which
+  // bm25() scores the better matches higher (as a negative number, so we flip
+  // its sign). The table sizes are read from the dbstat table, which lists
+  // the pages each table and index takes up in the database file.
+  migrations.SQLite: {
+    now:           "strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')",
+    returning:     true,
+    skipConflicts: "ON CONFLICT DO NOTHING",
+    upsertTag: `INSERT INTO tags (name) VALUES (?)
+    ON CONFLICT (name) DO UPDATE SET name = excluded.name`,
+    purgeExpired: `DELETE FROM snippets WHERE id IN (
+      SELECT id FROM snippets WHERE expires < ? ORDER BY expires LIMIT ?
+    )`,
+    textMatch: `s.id IN (SELECT rowid FROM snippets_search
+    WHERE snippets_search MATCH ?)`,
+    textScore: `COALESCE((SELECT -bm25(snippets_search) FROM snippets_search
+    WHERE snippets_search MATCH ? AND rowid = s.id), 0)`,
+    searchText: fts5Query,
+    tableStats: `SELECT t.name,
+      (SELECT COALESCE(SUM(d.pgsize), 0) FROM dbstat d WHERE d.name = t.name),
+      (SELECT COALESCE(SUM(d.pgsize), 0) FROM dbstat d
+        INNER JOIN sqlite_schema i ON i.name = d.name
+        WHERE i.type = 'index' AND i.tbl_name = t.name)
+    FROM sqlite_schema t
+    WHERE t.type = 'table' AND t.name NOT LIKE 'sqlite_%'
+    ORDER BY t.name`,
+    quote: doubleQuote,
+  },
+}
+
+// dialectFor() returns the dialect for the named database. The models were
+// written for MySQL before the other databases were supported, so an empty
+// name means MySQL.
+func dialectFor(name string) dialect {
+  d, ok := dialects[name]
+  if !ok {
+    return dialects[migrations.MySQL]
+  }
+  return d
+}
+
+// rebind() adapts a query written for MySQL to the database, by swapping
+// UTC_TIMESTAMP() for the database's equivalent and numbering the
+// placeholders if the database needs that. None of our queries contain a ?
+// which isn't a placeholder.
+func (d dialect) rebind(query string) string {
+  query = strings.ReplaceAll(query, "UTC_TIMESTAMP()", d.now)
+  if !d.numbered {
+    return query
+  }
+
+  var b strings.Builder
+  n := 0
+  for _, r := range query {
+    if r == '?' {
+      n++
+      b.WriteString("$" + strconv.Itoa(n))
+      continue
+    }
+    b.WriteRune(r)
+  }
+
+  return b.String()
+}
+
+// insertID() runs an INSERT statement in a transaction, and returns the ID of
+// the new row. If the statement skipped the row because of skipConflicts,
+// it returns sql.ErrNoRows.
+func (d dialect) insertID(ctx context.Context, tx *sql.Tx, stmt string, args ...any) (int, error) {
+  if d.returning {
+    var id int
+    err := tx.QueryRowContext(ctx, d.rebind(stmt+" RETURNING id"), args...).Scan(&id)
+    return id, err
+  }
+
+  result, err := tx.ExecContext(ctx, d.rebind(stmt), args...)
+  if err != nil {
+    return 0, err
+  }
+
+  id, err := result.LastInsertId()
+  return int(id), err
+}
+
+// doubleQuote() quotes a name in the standard SQL way, which Postgres and
+// SQLite both use.
+func doubleQuote(name string) string {
+  return `"` + name + `"`
+}
+
+// fts5Query() turns the words in a search query into an FTS5 query which
+// matches any of them, like MySQL's natural language mode. FTS5 has its own
+// query syntax, in which words like OR and NOT and characters like * and :
+// mean something, so we quote each word to make FTS5 search for it as it is.
+func fts5Query(terms []string) string {
+  quoted := make([]string, len(terms))
+  for i, term := range terms {
+    quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+  }
+  return strings.Join(quoted, " OR ")
+}
+
+// isUniqueViolation() reports whether err means that a row broke the named
+// unique constraint. SQLite doesn't report the name of the constraint, only
+// the columns in it, so for SQLite we look for column instead, given as
+// "table.column".
+func isUniqueViolation(err error, constraint, column string) bool {
+  var mySQLError *mysql.MySQLError
+  var pgErr *pgconn.PgError
+  var sqliteErr *sqlite.Error
+
+  switch {
+  case errors.As(err, &mySQLError):
+    return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, constraint)
+  case errors.As(err, &pgErr):
+    return pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+  case errors.As(err, &sqliteErr):
+    return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE &&
+      strings.Contains(sqliteErr.Error(), column)
+  }
+
+  return false
+}
@@ -14,4 +14,17 @@ var (
   // Add a new ErrDuplicateEmail error. We'll use this later if a user
   // tries to signup with an email address that's already in use.
   ErrDuplicateEmail = errors.New("models: duplicate email")
+
+  // Add a new ErrInvalidCursor error. We'll return this if a pagination cursor
+  // can't be decoded (e.g. because somebody has edited the URL by hand).
+  ErrInvalidCursor = errors.New("models: invalid cursor")
+
+  // Add a new ErrBurned error. We'll return this when somebody tries to view
+  // a burn-after-reading snippet which has already been read.
+  ErrBurned = errors.New("models: snippet has been burned")
+
+  // Add a new ErrTimeout error. We'll return this when a query is cancelled
+  // because it took longer than its deadline, so that callers can tell a
+  // slow database apart from a broken one.
+  ErrTimeout = errors.New("models: query timed out")
 )
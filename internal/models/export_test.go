@@ -0,0 +1,4 @@
+package models
+
+// NewTestDB lets the external tests in this directory use newTestDB().
+var NewTestDB = newTestDB
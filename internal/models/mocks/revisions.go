@@ -0,0 +1,52 @@
+package mocks
+
+import (
+  "context"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/models"
+)
+
+var mockRevisions = []models.Revision{
+  {
+    ID:         2,
+    SnippetID:  1,
+    Version:    2,
+    Title:      "an old silent pond",
+    Content:    "an old silent pond...\na frog jumps into the pond,\nsplash! silence again.",
+    UserID:     1,
+    Author:     "Alice Jones",
+    Created:    time.Now(),
+  },
+  {
+    ID:         1,
+    SnippetID:  1,
+    Version:    1,
+    Title:      "an old silent pond",
+    Content:    "an old silent pond...\na frog jumps in,\nsplash! silence again.",
+    UserID:     1,
+    Author:     "Alice Jones",
+    Created:    time.Now(),
+  },
+}
+
+type RevisionModel struct{}
+
+func (m *RevisionModel) All(ctx context.Context, snippetID int) ([]models.Revision, error) {
+  switch snippetID {
+  case 1:
+    return mockRevisions, nil
+  default:
+    return nil, nil
+  }
+}
+
+func (m *RevisionModel) Get(ctx context.Context, snippetID int, version int) (models.Revision, error) {
+  for _, r := range mockRevisions {
+    if r.SnippetID == snippetID && r.Version == version {
+      return r, nil
+    }
+  }
+
+  return models.Revision{}, models.ErrNoRecord
+}
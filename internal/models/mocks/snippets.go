@@ -1,34 +1,280 @@
 package mocks
 
 import (
+  "context"
+  "slices"
+  "strings"
+  "sync"
   "time"
 
   "github.com/kjloveless/snippetbox/internal/models"
 )
 
 var mockSnippet = models.Snippet{
-  ID:       1,
-  Title:    "an old silent pond",
-  Content:  "an old silent pond...",
-  Created:  time.Now(),
-  Expires:  time.Now(),
+  ID:         1,
+  Slug:       "s1lentPd",
+  Title:      "an old silent pond",
+  Content:    "an old silent pond...",
+  Visibility: models.VisibilityPublic,
+  Created:    time.Now(),
+  Expires:    time.Now().Add(3 * time.Hour),
+  UserID:     1,
+  Author:     "Alice Jones",
+  Tags:       []string{"haiku", "nature"},
 }
 
-type SnippetModel struct{}
+// mockOtherSnippet is owned by a different user to mockSnippet, so that we
+// can test what happens when somebody tries to modify a snippet they don't
+// own.
+var mockOtherSnippet = models.Snippet{
+  ID:         3,
+  Slug:       "w1ntryFr",
+  Title:      "over the wintry forest",
+  Content:    "over the wintry forest...",
+  Language:   "plaintext",
+  Visibility: models.VisibilityPublic,
+  Created:    time.Now(),
+  Expires:    time.Now().Add(3 * time.Hour),
+  UserID:     2,
+  Author:     "Bob Smith",
+  Tags:       []string{"haiku", "winter"},
+}
+
+// mockUnlistedSnippet can be viewed by anybody with the link, but shouldn't
+// appear in any listings except its author's.
+var mockUnlistedSnippet = models.Snippet{
+  ID:         4,
+  Slug:       "c0ldShwr",
+  Title:      "the first cold shower",
+  Content:    "the first cold shower...",
+  Visibility: models.VisibilityUnlisted,
+  Created:    time.Now(),
+  Expires:    time.Now().Add(3 * time.Hour),
+  UserID:     2,
+  Author:     "Bob Smith",
+}
+
+// mockPrivateSnippet belongs to the user that our tests log in as, and can't
+// be seen by anyone else. It never expires.
+var mockPrivateSnippet = models.Snippet{
+  ID:         5,
+  Slug:       "l1ghtnFl",
+  Title:      "a lightning flash",
+  Content:    "a lightning flash...",
+  Visibility: models.VisibilityPrivate,
+  Created:    time.Now(),
+  UserID:     1,
+  Author:     "Alice Jones",
+}
+
+// mockBurnSnippet is a burn-after-reading snippet belonging to somebody other
+// than the user our tests log in as.
+var mockBurnSnippet = models.Snippet{
+  ID:               6,
+  Slug:             "bUrn1tNw",
+  Title:            "the door code",
+  Content:          "1234",
+  Visibility:       models.VisibilityUnlisted,
+  BurnAfterReading: true,
+  Created:          time.Now(),
+  Expires:          time.Now().Add(3 * time.Hour),
+  UserID:           2,
+  Author:           "Bob Smith",
+}
+
+// mockBurnedSlug is the slug of a burn-after-reading snippet which has
+// already been read.
+const mockBurnedSlug = "bUrnEd0n"
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-  return 2, nil
+// mockSlowSlug is the slug of a snippet which takes so long to fetch that the
+// query times out.
+const mockSlowSlug = "sl0wQury"
+
+var mockSnippets = []models.Snippet{
+  mockSnippet,
+  mockOtherSnippet,
+  mockUnlistedSnippet,
+  mockPrivateSnippet,
+  mockBurnSnippet,
+}
+
+// SnippetModel keeps hold of the last snippet passed to Insert(), so that
+// tests can check what a handler tried to save. The handlers run in the test
+// server's goroutines, so it's protected by a mutex.
+type SnippetModel struct {
+  mu       sync.Mutex
+  inserted models.Snippet
+}
+
+// Insert() pretends that the new snippet is mockSnippet, so that handlers
+// which fetch the snippet back after creating it get something to work with.
+func (m *SnippetModel) Insert(ctx context.Context, snippet models.Snippet) (string, error) {
+  m.mu.Lock()
+  m.inserted = snippet
+  m.mu.Unlock()
+
+  return mockSnippet.Slug, nil
 }
 
-func (m *SnippetModel) Get(id int) (models.Snippet, error) {
+// Inserted() returns the snippet most recently passed to Insert().
+func (m *SnippetModel) Inserted() models.Snippet {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+
+  return m.inserted
+}
+
+func (m *SnippetModel) Get(ctx context.Context, slug string, viewerID int) (models.Snippet, error) {
+  if slug == mockSlowSlug {
+    return models.Snippet{}, models.ErrTimeout
+  }
+
+  for _, s := range mockSnippets {
+    if s.Slug == slug && s.VisibleTo(viewerID) && !s.BurnsFor(viewerID) {
+      return s, nil
+    }
+  }
+  return models.Snippet{}, models.ErrNoRecord
+}
+
+func (m *SnippetModel) View(ctx context.Context, slug string, viewerID int) (models.Snippet, error) {
+  switch slug {
+  case mockBurnedSlug:
+    return models.Snippet{}, models.ErrBurned
+  case mockSlowSlug:
+    return models.Snippet{}, models.ErrTimeout
+  }
+
+  for _, s := range mockSnippets {
+    if s.Slug == slug && s.VisibleTo(viewerID) {
+      return s, nil
+    }
+  }
+  return models.Snippet{}, models.ErrNoRecord
+}
+
+func (m *SnippetModel) LegacySlug(ctx context.Context, id int, viewerID int) (string, error) {
+  for _, s := range mockSnippets {
+    if s.ID == id && s.ListedFor(viewerID) {
+      return s.Slug, nil
+    }
+  }
+  return "", models.ErrNoRecord
+}
+
+func (m *SnippetModel) Latest(ctx context.Context, viewerID int) ([]models.Snippet, error) {
+  return []models.Snippet{mockSnippet}, nil
+}
+
+func (m *SnippetModel) Search(ctx context.Context, query string, page int, viewerID int) ([]models.Snippet, error) {
+  if page > 1 {
+    return nil, nil
+  }
+
+  var snippets []models.Snippet
+  for _, s := range mockSnippets {
+    if !s.ListedFor(viewerID) {
+      continue
+    }
+    text := strings.ToLower(s.Title + " " + s.Content + " " + strings.Join(s.Tags, " "))
+    for _, term := range strings.Fields(strings.ToLower(query)) {
+      if strings.Contains(text, term) {
+        snippets = append(snippets, s)
+        break
+      }
+    }
+  }
+
+  return snippets, nil
+}
+
+func (m *SnippetModel) List(ctx context.Context, filter models.SnippetFilter) (models.SnippetPage, error) {
+  cursor, err := models.DecodeCursor(filter.Cursor)
+  if err != nil {
+    return models.SnippetPage{}, err
+  }
+
+  limit := filter.Limit
+  if limit < 1 {
+    limit = models.ListPageSize
+  }
+
+  ascending := filter.Sort == models.SortOldest
+  if cursor.Backward {
+    ascending = !ascending
+  }
+
+  // Apply the same filters as the real model, in memory.
+  var snippets []models.Snippet
+  for _, s := range mockSnippets {
+    if !s.ListedFor(filter.ViewerID) {
+      continue
+    }
+    if filter.AuthorID != 0 && s.UserID != filter.AuthorID {
+      continue
+    }
+    if !filter.CreatedAfter.IsZero() && s.Created.Before(filter.CreatedAfter) {
+      continue
+    }
+    if !filter.CreatedBefore.IsZero() && !s.Created.Before(filter.CreatedBefore) {
+      continue
+    }
+    if filter.Tag != "" && !slices.Contains(s.Tags, filter.Tag) {
+      continue
+    }
+    if cursor.ID != 0 && ascending && s.ID <= cursor.ID {
+      continue
+    }
+    if cursor.ID != 0 && !ascending && s.ID >= cursor.ID {
+      continue
+    }
+    snippets = append(snippets, s)
+  }
+
+  slices.SortFunc(snippets, func(a, b models.Snippet) int {
+    if ascending {
+      return a.ID - b.ID
+    }
+    return b.ID - a.ID
+  })
+
+  if len(snippets) > limit+1 {
+    snippets = snippets[:limit+1]
+  }
+
+  return models.NewSnippetPage(snippets, cursor, limit), nil
+}
+
+func (m *SnippetModel) Tags(ctx context.Context, limit int) ([]models.Tag, error) {
+  return []models.Tag{
+    {Name: "haiku", Count: 2},
+    {Name: "nature", Count: 1},
+    {Name: "winter", Count: 1},
+  }, nil
+}
+
+func (m *SnippetModel) Update(ctx context.Context, snippet models.Snippet, editorID int) error {
+  switch snippet.ID {
+  case 1, 3:
+    return nil
+  default:
+    return models.ErrNoRecord
+  }
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
   switch id {
-  case 1:
-    return mockSnippet, nil
+  case 1, 3:
+    return nil
   default:
-    return models.Snippet{}, models.ErrNoRecord
+    return models.ErrNoRecord
   }
 }
 
-func (m *SnippetModel) Latest() ([]models.Snippet, error) {
-  return []models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) PurgeExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+  return 0, nil
+}
+
+func (m *SnippetModel) PurgeBurned(ctx context.Context, before time.Time) (int, error) {
+  return 0, nil
 }
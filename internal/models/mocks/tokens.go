@@ -0,0 +1,69 @@
+package mocks
+
+import (
+  "context"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/models"
+)
+
+// mockToken is a write token belonging to the user from our mocked UserModel.
+var mockToken = models.Token{
+  ID:       1,
+  UserID:   1,
+  Name:     "laptop",
+  Scope:    models.ScopeWrite,
+  Created:  time.Now(),
+  LastUsed: time.Now(),
+}
+
+// mockReadToken is a read-only token belonging to the same user, which
+// expires and has never been used.
+var mockReadToken = models.Token{
+  ID:      2,
+  UserID:  1,
+  Name:    "editor plugin",
+  Scope:   models.ScopeRead,
+  Created: time.Now(),
+  Expires: time.Now().Add(30 * 24 * time.Hour),
+}
+
+// mockTokens maps the plaintext tokens which Authenticate() accepts to the
+// tokens they belong to.
+var mockTokens = map[string]models.Token{
+  "alice-token":      mockToken,
+  "alice-read-token": mockReadToken,
+}
+
+type TokenModel struct{}
+
+func (m *TokenModel) Insert(ctx context.Context, userID int, name, scope string, expires time.Time) (string, error) {
+  return "n3w-t0ken", nil
+}
+
+func (m *TokenModel) Authenticate(ctx context.Context, token string) (models.Token, error) {
+  t, ok := mockTokens[token]
+  if !ok {
+    return models.Token{}, models.ErrInvalidCredentials
+  }
+
+  return t, nil
+}
+
+func (m *TokenModel) ForUser(ctx context.Context, userID int) ([]models.Token, error) {
+  if userID == 1 {
+    return []models.Token{mockReadToken, mockToken}, nil
+  }
+
+  return nil, nil
+}
+
+func (m *TokenModel) Revoke(ctx context.Context, id, userID int) error {
+  for _, t := range mockTokens {
+    if t.ID == id && t.UserID == userID {
+      return nil
+    }
+  }
+
+  return models.ErrNoRecord
+}
@@ -0,0 +1,27 @@
+package models_test
+
+import (
+  "testing"
+
+  "github.com/kjloveless/snippetbox/internal/models"
+  "github.com/kjloveless/snippetbox/internal/models/modeltest"
+)
+
+// TestModels runs the tests shared by every database against MySQL.
+func TestModels(t *testing.T) {
+  if testing.Short() {
+    t.Skip("models: skipping integration test")
+  }
+
+  modeltest.Run(t, func(t *testing.T) modeltest.Models {
+    db := models.NewTestDB(t)
+
+    return modeltest.Models{
+      Snippets:  &models.SnippetModel{DB: db},
+      Users:     &models.UserModel{DB: db},
+      Revisions: &models.RevisionModel{DB: db},
+      Tokens:    &models.TokenModel{DB: db},
+      Admin:     &models.AdminModel{DB: db},
+    }
+  })
+}
@@ -0,0 +1,683 @@
+// Package modeltest holds the tests which the models must pass with every
+// database, so that MySQL, PostgreSQL and SQLite are held to the same
+// standard. The tests for each database run them by calling Run().
+package modeltest
+
+import (
+  "errors"
+  "sync"
+  "testing"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+  "github.com/kjloveless/snippetbox/internal/models"
+)
+
+// Define a Models type to hold the models being tested. Users includes the
+// methods which the snippetadmin command uses, and Admin is the model which
+// only snippetadmin uses.
+type Models struct {
+  Snippets  models.SnippetModelInterface
+  Users     models.AdminUserModelInterface
+  Revisions models.RevisionModelInterface
+  Tokens    models.TokenModelInterface
+  Admin     models.AdminModelInterface
+}
+
+// Run() runs every test in the suite as a subtest. newModels is called at the
+// start of each one, and should return models using a fresh test database
+// which contains a single user: Alice Jones, with the ID 1, the email
+// address alice@example.com and the password "pa$$word".
+//
+// Implementations which need a database server to test against should skip
+// these when the "-short" flag is provided, like their other integration
+// tests.
+func Run(t *testing.T, newModels func(t *testing.T) Models) {
+  tests := []struct {
+    name string
+    test func(t *testing.T, m Models)
+  }{
+    {"SnippetInsert", testSnippetInsert},
+    {"SnippetUpdate", testSnippetUpdate},
+    {"SnippetVisibility", testSnippetVisibility},
+    {"SnippetViewBurn", testSnippetViewBurn},
+    {"SnippetViewConcurrent", testSnippetViewConcurrent},
+    {"SnippetList", testSnippetList},
+    {"SnippetSearch", testSnippetSearch},
+    {"SnippetTags", testSnippetTags},
+    {"SnippetPurgeExpired", testSnippetPurgeExpired},
+    {"SnippetPurgeBurned", testSnippetPurgeBurned},
+    {"UserInsert", testUserInsert},
+    {"UserExists", testUserExists},
+    {"UserAdmin", testUserAdmin},
+    {"UserDeleteRevisions", testUserDeleteRevisions},
+    {"Admin", testAdmin},
+    {"Token", testToken},
+    {"TokenExpired", testTokenExpired},
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      tt.test(t, newModels(t))
+    })
+  }
+}
+
+// insertSnippet() inserts a snippet owned by Alice, filling in the title,
+// content and visibility if they aren't set, and returns its slug.
+func insertSnippet(t *testing.T, m Models, s models.Snippet) string {
+  t.Helper()
+
+  if s.Title == "" {
+    s.Title = "an old silent pond"
+  }
+  if s.Content == "" {
+    s.Content = "an old silent pond..."
+  }
+  if s.Visibility == "" {
+    s.Visibility = models.VisibilityPublic
+  }
+  s.UserID = 1
+
+  slug, err := m.Snippets.Insert(t.Context(), s)
+  assert.NilError(t, err)
+
+  return slug
+}
+
+// insertBurnSnippet() inserts a burn-after-reading snippet owned by Alice, and
+// returns its slug.
+func insertBurnSnippet(t *testing.T, m Models) string {
+  t.Helper()
+
+  return insertSnippet(t, m, models.Snippet{
+    Title:            "the door code",
+    Content:          "1234",
+    Visibility:       models.VisibilityUnlisted,
+    BurnAfterReading: true,
+    Expires:          time.Now().Add(time.Hour),
+  })
+}
+
+func testSnippetInsert(t *testing.T, m Models) {
+  expires := time.Now().Add(time.Hour).Truncate(time.Second)
+
+  slug := insertSnippet(t, m, models.Snippet{
+    Language: "go",
+    Expires:  expires,
+    Tags:     []string{"nature", "haiku"},
+  })
+  assert.Equal(t, models.ValidSlug(slug), true)
+
+  s, err := m.Snippets.Get(t.Context(), slug, 0)
+  assert.NilError(t, err)
+  assert.Equal(t, s.Slug, slug)
+  assert.Equal(t, s.Title, "an old silent pond")
+  assert.Equal(t, s.Content, "an old silent pond...")
+  assert.Equal(t, s.Language, "go")
+  assert.Equal(t, s.Author, "Alice Jones")
+  assert.Equal(t, s.Expires.Equal(expires), true)
+  assert.Equal(t, len(s.Tags), 2)
+  assert.Equal(t, s.Tags[0], "haiku")
+  assert.Equal(t, s.Tags[1], "nature")
+
+  // The snippet can be found by its old numeric ID too.
+  legacy, err := m.Snippets.LegacySlug(t.Context(), s.ID, 0)
+  assert.NilError(t, err)
+  assert.Equal(t, legacy, slug)
+
+  // Snippets which never expire don't have an expiry time.
+  slug = insertSnippet(t, m, models.Snippet{})
+
+  s, err = m.Snippets.Get(t.Context(), slug, 0)
+  assert.NilError(t, err)
+  assert.Equal(t, s.NeverExpires(), true)
+
+  _, err = m.Snippets.Get(t.Context(), "m1ss1ngX", 0)
+  assert.Equal(t, err, models.ErrNoRecord)
+}
+
+func testSnippetUpdate(t *testing.T, m Models) {
+  slug := insertSnippet(t, m, models.Snippet{Tags: []string{"haiku"}})
+
+  s, err := m.Snippets.Get(t.Context(), slug, 1)
+  assert.NilError(t, err)
+
+  s.Title = "a frog jumps"
+  s.Content = "a frog jumps into the pond..."
+  s.Tags = []string{"frogs"}
+  err = m.Snippets.Update(t.Context(), s, 1)
+  assert.NilError(t, err)
+
+  s, err = m.Snippets.Get(t.Context(), slug, 1)
+  assert.NilError(t, err)
+  assert.Equal(t, s.Title, "a frog jumps")
+  assert.Equal(t, len(s.Tags), 1)
+  assert.Equal(t, s.Tags[0], "frogs")
+
+  // Both versions are kept as revisions, newest first.
+  revisions, err := m.Revisions.All(t.Context(), s.ID)
+  assert.NilError(t, err)
+  assert.Equal(t, len(revisions), 2)
+  assert.Equal(t, revisions[0].Version, 2)
+  assert.Equal(t, revisions[0].Title, "a frog jumps")
+  assert.Equal(t, revisions[1].Version, 1)
+  assert.Equal(t, revisions[1].Title, "an old silent pond")
+
+  r, err := m.Revisions.Get(t.Context(), s.ID, 1)
+  assert.NilError(t, err)
+  assert.Equal(t, r.Content, "an old silent pond...")
+  assert.Equal(t, r.Author, "Alice Jones")
+
+  _, err = m.Revisions.Get(t.Context(), s.ID, 3)
+  assert.Equal(t, err, models.ErrNoRecord)
+
+  // Saving a snippet without changing it still records a revision.
+  err = m.Snippets.Update(t.Context(), s, 1)
+  assert.NilError(t, err)
+
+  revisions, err = m.Revisions.All(t.Context(), s.ID)
+  assert.NilError(t, err)
+  assert.Equal(t, len(revisions), 3)
+
+  // Deleting the snippet works once, and then there's nothing to delete.
+  err = m.Snippets.Delete(t.Context(), s.ID)
+  assert.NilError(t, err)
+
+  err = m.Snippets.Delete(t.Context(), s.ID)
+  assert.Equal(t, err, models.ErrNoRecord)
+
+  // Nor is there anything to update.
+  err = m.Snippets.Update(t.Context(), s, 1)
+  assert.Equal(t, err, models.ErrNoRecord)
+}
+
+func testSnippetVisibility(t *testing.T, m Models) {
+  private := insertSnippet(t, m, models.Snippet{Visibility: models.VisibilityPrivate})
+  unlisted := insertSnippet(t, m, models.Snippet{Visibility: models.VisibilityUnlisted})
+
+  // Private snippets can only be seen by their author.
+  _, err := m.Snippets.Get(t.Context(), private, 1)
+  assert.NilError(t, err)
+
+  _, err = m.Snippets.Get(t.Context(), private, 2)
+  assert.Equal(t, err, models.ErrNoRecord)
+
+  // Unlisted snippets can be seen by anyone with the link...
+  _, err = m.Snippets.Get(t.Context(), unlisted, 0)
+  assert.NilError(t, err)
+
+  // ...but only their author sees them in listings.
+  latest, err := m.Snippets.Latest(t.Context(), 0)
+  assert.NilError(t, err)
+  assert.Equal(t, len(latest), 0)
+
+  latest, err = m.Snippets.Latest(t.Context(), 1)
+  assert.NilError(t, err)
+  assert.Equal(t, len(latest), 2)
+}
+
+func testSnippetViewBurn(t *testing.T, m Models) {
+  slug := insertBurnSnippet(t, m)
+
+  // The author can view their own snippet as often as they like.
+  for range 2 {
+    s, err := m.Snippets.View(t.Context(), slug, 1)
+    assert.NilError(t, err)
+    assert.Equal(t, s.Content, "1234")
+  }
+
+  // Nobody else can get at the snippet except through View().
+  _, err := m.Snippets.Get(t.Context(), slug, 2)
+  assert.Equal(t, err, models.ErrNoRecord)
+
+  // The first person to view it sees it...
+  s, err := m.Snippets.View(t.Context(), slug, 2)
+  assert.NilError(t, err)
+  assert.Equal(t, s.Content, "1234")
+
+  // ...and after that it's gone, even for the author.
+  _, err = m.Snippets.View(t.Context(), slug, 2)
+  assert.Equal(t, err, models.ErrBurned)
+
+  _, err = m.Snippets.View(t.Context(), slug, 1)
+  assert.Equal(t, err, models.ErrBurned)
+
+  _, err = m.Snippets.Get(t.Context(), slug, 1)
+  assert.Equal(t, err, models.ErrNoRecord)
+}
+
+func testSnippetViewConcurrent(t *testing.T, m Models) {
+  slug := insertBurnSnippet(t, m)
+
+  // Have lots of readers race to view the snippet at once. Exactly one of
+  // them should see it, and the rest should be told that it has been burned.
+  const readers = 10
+
+  var (
+    wg     sync.WaitGroup
+    mu     sync.Mutex
+    seen   int
+    burned int
+    errs   []error
+  )
+
+  start := make(chan struct{})
+
+  for i := range readers {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      <-start
+
+      _, err := m.Snippets.View(t.Context(), slug, i+2)
+
+      mu.Lock()
+      defer mu.Unlock()
+
+      switch {
+      case err == nil:
+        seen++
+      case errors.Is(err, models.ErrBurned):
+        burned++
+      default:
+        errs = append(errs, err)
+      }
+    }()
+  }
+
+  close(start)
+  wg.Wait()
+
+  for _, err := range errs {
+    t.Error(err)
+  }
+  assert.Equal(t, seen, 1)
+  assert.Equal(t, burned, readers-1)
+}
+
+func testSnippetList(t *testing.T, m Models) {
+  for range 5 {
+    insertSnippet(t, m, models.Snippet{})
+  }
+  insertSnippet(t, m, models.Snippet{Tags: []string{"haiku"}})
+
+  // Page forwards through the snippets, two at a time...
+  page, err := m.Snippets.List(t.Context(), models.SnippetFilter{Limit: 2})
+  assert.NilError(t, err)
+  assert.Equal(t, len(page.Snippets), 2)
+  assert.Equal(t, page.PrevCursor, "")
+
+  seen := len(page.Snippets)
+  for page.NextCursor != "" {
+    page, err = m.Snippets.List(t.Context(), models.SnippetFilter{Limit: 2, Cursor: page.NextCursor})
+    assert.NilError(t, err)
+    seen += len(page.Snippets)
+  }
+  assert.Equal(t, seen, 6)
+
+  // ...and back again.
+  page, err = m.Snippets.List(t.Context(), models.SnippetFilter{Limit: 2, Cursor: page.PrevCursor})
+  assert.NilError(t, err)
+  assert.Equal(t, len(page.Snippets), 2)
+
+  // The filters narrow down the results.
+  page, err = m.Snippets.List(t.Context(), models.SnippetFilter{Tag: "haiku"})
+  assert.NilError(t, err)
+  assert.Equal(t, len(page.Snippets), 1)
+
+  page, err = m.Snippets.List(t.Context(), models.SnippetFilter{AuthorID: 2})
+  assert.NilError(t, err)
+  assert.Equal(t, len(page.Snippets), 0)
+
+  page, err = m.Snippets.List(t.Context(), models.SnippetFilter{CreatedBefore: time.Now().Add(-time.Hour)})
+  assert.NilError(t, err)
+  assert.Equal(t, len(page.Snippets), 0)
+
+  page, err = m.Snippets.List(t.Context(), models.SnippetFilter{Sort: models.SortOldest, Limit: 1})
+  assert.NilError(t, err)
+  assert.Equal(t, len(page.Snippets[0].Tags), 0)
+
+  _, err = m.Snippets.List(t.Context(), models.SnippetFilter{Cursor: "not-a-cursor"})
+  assert.Equal(t, err, models.ErrInvalidCursor)
+}
+
+func testSnippetSearch(t *testing.T, m Models) {
+  insertSnippet(t, m, models.Snippet{Title: "frogs", Content: "a frog jumps into the water"})
+  insertSnippet(t, m, models.Snippet{Title: "crows", Content: "on a withered branch a crow has settled"})
+  insertSnippet(t, m, models.Snippet{Title: "autumn", Content: "autumn evening", Tags: []string{"frog"}})
+
+  // Matches come from the title and content, and from tags.
+  results, err := m.Snippets.Search(t.Context(), "frog", 1, 0)
+  assert.NilError(t, err)
+  assert.Equal(t, len(results), 2)
+
+  results, err = m.Snippets.Search(t.Context(), "crow", 1, 0)
+  assert.NilError(t, err)
+  assert.Equal(t, len(results), 1)
+  assert.Equal(t, results[0].Title, "crows")
+
+  results, err = m.Snippets.Search(t.Context(), "crow", 2, 0)
+  assert.NilError(t, err)
+  assert.Equal(t, len(results), 0)
+
+  results, err = m.Snippets.Search(t.Context(), "", 1, 0)
+  assert.NilError(t, err)
+  assert.Equal(t, len(results), 0)
+}
+
+func testSnippetTags(t *testing.T, m Models) {
+  insertSnippet(t, m, models.Snippet{Tags: []string{"haiku", "nature"}})
+  insertSnippet(t, m, models.Snippet{Tags: []string{"haiku"}})
+  // Private snippets aren't counted.
+  insertSnippet(t, m, models.Snippet{Tags: []string{"secret"}, Visibility: models.VisibilityPrivate})
+
+  tags, err := m.Snippets.Tags(t.Context(), 10)
+  assert.NilError(t, err)
+  assert.Equal(t, len(tags), 2)
+  assert.Equal(t, tags[0], models.Tag{Name: "haiku", Count: 2})
+  assert.Equal(t, tags[1], models.Tag{Name: "nature", Count: 1})
+
+  // The limit keeps the most used tags.
+  tags, err = m.Snippets.Tags(t.Context(), 1)
+  assert.NilError(t, err)
+  assert.Equal(t, len(tags), 1)
+  assert.Equal(t, tags[0].Name, "haiku")
+}
+
+func testSnippetPurgeExpired(t *testing.T, m Models) {
+  // Insert three expired snippets, one which hasn't expired yet, and one
+  // which never expires.
+  expiries := []time.Time{
+    time.Now().Add(-3 * time.Hour),
+    time.Now().Add(-2 * time.Hour),
+    time.Now().Add(-time.Hour),
+    time.Now().Add(time.Hour),
+    {},
+  }
+  for _, expires := range expiries {
+    insertSnippet(t, m, models.Snippet{Expires: expires})
+  }
+
+  // The limit is respected...
+  n, err := m.Snippets.PurgeExpired(t.Context(), time.Now(), 2)
+  assert.NilError(t, err)
+  assert.Equal(t, n, 2)
+
+  // ...and only expired snippets are deleted.
+  n, err = m.Snippets.PurgeExpired(t.Context(), time.Now(), 2)
+  assert.NilError(t, err)
+  assert.Equal(t, n, 1)
+
+  n, err = m.Snippets.PurgeExpired(t.Context(), time.Now(), 2)
+  assert.NilError(t, err)
+  assert.Equal(t, n, 0)
+
+  latest, err := m.Snippets.Latest(t.Context(), 1)
+  assert.NilError(t, err)
+  assert.Equal(t, len(latest), 2)
+}
+
+func testSnippetPurgeBurned(t *testing.T, m Models) {
+  slug := insertBurnSnippet(t, m)
+
+  _, err := m.Snippets.View(t.Context(), slug, 2)
+  assert.NilError(t, err)
+
+  // The slug is only forgotten once it was burned before the cut-off.
+  n, err := m.Snippets.PurgeBurned(t.Context(), time.Now().Add(-time.Hour))
+  assert.NilError(t, err)
+  assert.Equal(t, n, 0)
+
+  _, err = m.Snippets.View(t.Context(), slug, 2)
+  assert.Equal(t, err, models.ErrBurned)
+
+  n, err = m.Snippets.PurgeBurned(t.Context(), time.Now().Add(time.Minute))
+  assert.NilError(t, err)
+  assert.Equal(t, n, 1)
+
+  // After that, the snippet might as well never have existed.
+  _, err = m.Snippets.View(t.Context(), slug, 2)
+  assert.Equal(t, err, models.ErrNoRecord)
+}
+
+func testUserInsert(t *testing.T, m Models) {
+  err := m.Users.Insert(t.Context(), "Bob", "bob@example.com", "n3w-passw0rd")
+  assert.NilError(t, err)
+
+  id, err := m.Users.Authenticate(t.Context(), "bob@example.com", "n3w-passw0rd")
+  assert.NilError(t, err)
+  assert.Equal(t, id, 2)
+
+  _, err = m.Users.Authenticate(t.Context(), "bob@example.com", "wrong-password")
+  assert.Equal(t, err, models.ErrInvalidCredentials)
+
+  _, err = m.Users.Authenticate(t.Context(), "nobody@example.com", "n3w-passw0rd")
+  assert.Equal(t, err, models.ErrInvalidCredentials)
+
+  err = m.Users.Insert(t.Context(), "Alice", "alice@example.com", "n3w-passw0rd")
+  assert.Equal(t, err, models.ErrDuplicateEmail)
+}
+
+func testUserExists(t *testing.T, m Models) {
+  tests := []struct {
+    name   string
+    userID int
+    want   bool
+  }{
+    {
+      name:   "valid ID",
+      userID: 1,
+      want:   true,
+    },
+    {
+      name:   "zero id",
+      userID: 0,
+      want:   false,
+    },
+    {
+      name:   "non-existent id",
+      userID: 2,
+      want:   false,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      exists, err := m.Users.Exists(t.Context(), tt.userID)
+
+      assert.Equal(t, exists, tt.want)
+      assert.NilError(t, err)
+    })
+  }
+}
+
+func testUserAdmin(t *testing.T, m Models) {
+  users, err := m.Users.All(t.Context())
+  assert.NilError(t, err)
+  assert.Equal(t, len(users), 1)
+  assert.Equal(t, users[0].Email, "alice@example.com")
+  assert.Equal(t, users[0].Disabled, false)
+
+  // A disabled user can't log in, and doesn't count as existing, so their
+  // sessions and API tokens stop working too.
+  err = m.Users.SetDisabled(t.Context(), 1, true)
+  assert.NilError(t, err)
+
+  _, err = m.Users.Authenticate(t.Context(), "alice@example.com", "pa$$word")
+  assert.Equal(t, err, models.ErrInvalidCredentials)
+
+  exists, err := m.Users.Exists(t.Context(), 1)
+  assert.NilError(t, err)
+  assert.Equal(t, exists, false)
+
+  // Disabling a user twice isn't an error.
+  err = m.Users.SetDisabled(t.Context(), 1, true)
+  assert.NilError(t, err)
+
+  err = m.Users.SetDisabled(t.Context(), 1, false)
+  assert.NilError(t, err)
+
+  // Only the new password works after it's changed.
+  err = m.Users.SetPassword(t.Context(), 1, "n3w-passw0rd")
+  assert.NilError(t, err)
+
+  _, err = m.Users.Authenticate(t.Context(), "alice@example.com", "pa$$word")
+  assert.Equal(t, err, models.ErrInvalidCredentials)
+
+  id, err := m.Users.Authenticate(t.Context(), "alice@example.com", "n3w-passw0rd")
+  assert.NilError(t, err)
+  assert.Equal(t, id, 1)
+
+  // Deleting a user deletes their snippets too.
+  insertSnippet(t, m, models.Snippet{})
+
+  err = m.Users.Delete(t.Context(), 1)
+  assert.NilError(t, err)
+
+  snippets, err := m.Admin.Snippets(t.Context(), 1, 10)
+  assert.NilError(t, err)
+  assert.Equal(t, len(snippets), 0)
+
+  // Changing a user who doesn't exist is an error.
+  assert.Equal(t, m.Users.SetDisabled(t.Context(), 1, true), models.ErrNoRecord)
+  assert.Equal(t, m.Users.SetPassword(t.Context(), 1, "n3w-passw0rd"), models.ErrNoRecord)
+  assert.Equal(t, m.Users.Delete(t.Context(), 1), models.ErrNoRecord)
+}
+
+func testUserDeleteRevisions(t *testing.T, m Models) {
+  slug := insertSnippet(t, m, models.Snippet{})
+
+  s, err := m.Snippets.Get(t.Context(), slug, 1)
+  assert.NilError(t, err)
+
+  // Bob edits Alice's snippet, and then his account is deleted.
+  err = m.Users.Insert(t.Context(), "Bob", "bob@example.com", "n3w-passw0rd")
+  assert.NilError(t, err)
+
+  s.Title = "a frog jumps"
+  err = m.Snippets.Update(t.Context(), s, 2)
+  assert.NilError(t, err)
+
+  err = m.Users.Delete(t.Context(), 2)
+  assert.NilError(t, err)
+
+  // Bob's revision is still part of the snippet's history, but it now
+  // belongs to the Anonymous user, who can't log in.
+  revisions, err := m.Revisions.All(t.Context(), s.ID)
+  assert.NilError(t, err)
+  assert.Equal(t, len(revisions), 2)
+  assert.Equal(t, revisions[0].Title, "a frog jumps")
+  assert.Equal(t, revisions[0].Author, "Anonymous")
+  assert.Equal(t, revisions[1].Author, "Alice Jones")
+
+  users, err := m.Users.All(t.Context())
+  assert.NilError(t, err)
+  assert.Equal(t, len(users), 2)
+  assert.Equal(t, users[1].Email, models.AnonymousEmail)
+  assert.Equal(t, users[1].Disabled, true)
+
+  // Deleting another user who made revisions hands them to the same
+  // Anonymous user, rather than making a new one.
+  err = m.Users.Insert(t.Context(), "Carol", "carol@example.com", "n3w-passw0rd")
+  assert.NilError(t, err)
+
+  err = m.Snippets.Update(t.Context(), s, 4)
+  assert.NilError(t, err)
+
+  err = m.Users.Delete(t.Context(), 4)
+  assert.NilError(t, err)
+
+  revisions, err = m.Revisions.All(t.Context(), s.ID)
+  assert.NilError(t, err)
+  assert.Equal(t, len(revisions), 3)
+  assert.Equal(t, revisions[0].UserID, users[1].ID)
+  assert.Equal(t, revisions[1].UserID, users[1].ID)
+
+  users, err = m.Users.All(t.Context())
+  assert.NilError(t, err)
+  assert.Equal(t, len(users), 2)
+}
+
+func testAdmin(t *testing.T, m Models) {
+  // Administrators can see private and burn-after-reading snippets.
+  slug := insertBurnSnippet(t, m)
+
+  snippets, err := m.Admin.Snippets(t.Context(), 1, 10)
+  assert.NilError(t, err)
+  assert.Equal(t, len(snippets), 1)
+  assert.Equal(t, snippets[0].Slug, slug)
+
+  snippets, err = m.Admin.Snippets(t.Context(), 2, 10)
+  assert.NilError(t, err)
+  assert.Equal(t, len(snippets), 0)
+
+  stats, err := m.Admin.Stats(t.Context())
+  assert.NilError(t, err)
+  found := false
+  for _, table := range stats {
+    if table.Name == "snippets" {
+      assert.Equal(t, table.Rows, 1)
+      found = true
+    }
+  }
+  assert.Equal(t, found, true)
+
+  err = m.Admin.DeleteSnippet(t.Context(), slug)
+  assert.NilError(t, err)
+  assert.Equal(t, m.Admin.DeleteSnippet(t.Context(), slug), models.ErrNoRecord)
+
+  insertBurnSnippet(t, m)
+  insertBurnSnippet(t, m)
+
+  n, err := m.Admin.PurgeUserSnippets(t.Context(), 1)
+  assert.NilError(t, err)
+  assert.Equal(t, n, 2)
+}
+
+func testToken(t *testing.T, m Models) {
+  token, err := m.Tokens.Insert(t.Context(), 1, "laptop", models.ScopeWrite, time.Time{})
+  assert.NilError(t, err)
+
+  // A valid token authenticates, and its use is recorded.
+  tok, err := m.Tokens.Authenticate(t.Context(), token)
+  assert.NilError(t, err)
+  assert.Equal(t, tok.UserID, 1)
+  assert.Equal(t, tok.Scope, models.ScopeWrite)
+  assert.Equal(t, tok.NeverExpires(), true)
+
+  tokens, err := m.Tokens.ForUser(t.Context(), 1)
+  assert.NilError(t, err)
+  assert.Equal(t, len(tokens), 1)
+  assert.Equal(t, tokens[0].Name, "laptop")
+  assert.Equal(t, tokens[0].LastUsed.IsZero(), false)
+
+  // Unknown tokens don't.
+  _, err = m.Tokens.Authenticate(t.Context(), "not-a-real-token")
+  assert.Equal(t, err, models.ErrInvalidCredentials)
+
+  // Tokens can only be revoked by their owner...
+  err = m.Tokens.Revoke(t.Context(), tok.ID, 2)
+  assert.Equal(t, err, models.ErrNoRecord)
+
+  // ...and once revoked they stop working.
+  err = m.Tokens.Revoke(t.Context(), tok.ID, 1)
+  assert.NilError(t, err)
+
+  _, err = m.Tokens.Authenticate(t.Context(), token)
+  assert.Equal(t, err, models.ErrInvalidCredentials)
+}
+
+func testTokenExpired(t *testing.T, m Models) {
+  token, err := m.Tokens.Insert(t.Context(), 1, "old laptop", models.ScopeRead, time.Now().Add(-time.Hour))
+  assert.NilError(t, err)
+
+  _, err = m.Tokens.Authenticate(t.Context(), token)
+  assert.Equal(t, err, models.ErrInvalidCredentials)
+
+  // Expired tokens are still listed, so that the user can see what happened
+  // to them.
+  tokens, err := m.Tokens.ForUser(t.Context(), 1)
+  assert.NilError(t, err)
+  assert.Equal(t, len(tokens), 1)
+}
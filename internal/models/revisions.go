@@ -0,0 +1,106 @@
+package models
+
+import (
+  "context"
+  "database/sql"
+  "errors"
+  "time"
+)
+
+type RevisionModelInterface interface {
+  All(ctx context.Context, snippetID int) ([]Revision, error)
+  Get(ctx context.Context, snippetID int, version int) (Revision, error)
+}
+
+// Define a Revision type to hold a saved version of a snippet. A new revision
+// is recorded every time a snippet is created or edited, with Version
+// counting up from 1 for each snippet. UserID and Author identify the user who
+// made that particular version.
+type Revision struct {
+  ID        int
+  SnippetID int
+  Version   int
+  Title     string
+  Content   string
+  UserID    int
+  Author    string
+  Created   time.Time
+}
+
+// Define a RevisionModel type which wraps a sql.DB connection pool.
+type RevisionModel struct {
+  DB      *sql.DB
+  Dialect string
+}
+
+// insertRevision() records the current state of a snippet as its next
+// revision. It takes a *sql.Tx so that the revision is written in the same
+// transaction as the change to the snippet itself. We get the user ID from
+// the users table, rather than selecting the parameter itself, because
+// Postgres can't tell the type of a parameter in a SELECT list.
+func insertRevision(ctx context.Context, d dialect, tx *sql.Tx, snippetID int, userID int) error {
+  stmt := `INSERT INTO snippet_revisions
+  (snippet_id, version, title, content, user_id, created)
+  SELECT s.id, (SELECT COALESCE(MAX(r.version), 0) + 1 FROM snippet_revisions r
+  WHERE r.snippet_id = s.id), s.title, s.content, u.id, UTC_TIMESTAMP()
+  FROM snippets s INNER JOIN users u ON u.id = ?
+  WHERE s.id = ?`
+
+  _, err := tx.ExecContext(ctx, d.rebind(stmt), userID, snippetID)
+  return err
+}
+
+// This will return all the revisions of a specific snippet, newest first.
+func (m *RevisionModel) All(ctx context.Context, snippetID int) ([]Revision, error) {
+  stmt := `SELECT r.id, r.snippet_id, r.version, r.title, r.content,
+  r.user_id, u.name, r.created FROM snippet_revisions r
+  INNER JOIN users u ON u.id = r.user_id
+  WHERE r.snippet_id = ? ORDER BY r.version DESC`
+
+  rows, err := m.DB.QueryContext(ctx, dialectFor(m.Dialect).rebind(stmt), snippetID)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  var revisions []Revision
+
+  for rows.Next() {
+    var r Revision
+    err = rows.Scan(&r.ID, &r.SnippetID, &r.Version, &r.Title, &r.Content,
+      &r.UserID, &r.Author, &r.Created)
+    if err != nil {
+      return nil, err
+    }
+    revisions = append(revisions, r)
+  }
+
+  if err = rows.Err(); err != nil {
+    return nil, err
+  }
+
+  return revisions, nil
+}
+
+// This will return a specific revision of a snippet. If there's no such
+// revision we return the ErrNoRecord error.
+func (m *RevisionModel) Get(ctx context.Context, snippetID int, version int) (Revision, error) {
+  stmt := `SELECT r.id, r.snippet_id, r.version, r.title, r.content,
+  r.user_id, u.name, r.created FROM snippet_revisions r
+  INNER JOIN users u ON u.id = r.user_id
+  WHERE r.snippet_id = ? AND r.version = ?`
+
+  var r Revision
+
+  err := m.DB.QueryRowContext(ctx, dialectFor(m.Dialect).rebind(stmt), snippetID, version).Scan(&r.ID, &r.SnippetID,
+    &r.Version, &r.Title, &r.Content, &r.UserID, &r.Author, &r.Created)
+  if err != nil {
+    if errors.Is(err, sql.ErrNoRows) {
+      return Revision{}, ErrNoRecord
+    } else {
+      return Revision{}, err
+    }
+  }
+
+  return r, nil
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+  "crypto/rand"
+  "strings"
+)
+
+// SlugLength is the number of characters in a snippet slug. With 62 possible
+// characters in each position there are over 200 trillion possible slugs, so
+// they're impractical to guess.
+const SlugLength = 8
+
+// slugAlphabet holds the characters which can appear in a slug. They are all
+// safe to use in a URL without escaping.
+const slugAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// MaxSlugAttempts is the number of times Insert() will generate a new slug
+// after a collision before giving up. Collisions should be vanishingly rare,
+// so needing more than a couple of attempts means something else is wrong.
+const MaxSlugAttempts = 5
+
+// NewSlug() returns a random slug of SlugLength characters. Slugs made up
+// entirely of digits are never generated, so that they can't be confused with
+// the numeric IDs used in old URLs.
+func NewSlug() (string, error) {
+  // Bytes of 248 or more are rejected (rather than taken modulo 62) so that
+  // every character in the alphabet is equally likely.
+  const limit = 256 - 256%len(slugAlphabet)
+
+  buf := make([]byte, 1)
+
+  for {
+    var slug strings.Builder
+
+    for slug.Len() < SlugLength {
+      _, err := rand.Read(buf)
+      if err != nil {
+        return "", err
+      }
+      if int(buf[0]) >= limit {
+        continue
+      }
+      slug.WriteByte(slugAlphabet[int(buf[0])%len(slugAlphabet)])
+    }
+
+    if ValidSlug(slug.String()) {
+      return slug.String(), nil
+    }
+  }
+}
+
+// ValidSlug() reports whether s looks like a slug generated by NewSlug(): the
+// right length, only characters from the alphabet, and not all digits. It's
+// useful for rejecting bad URLs without a trip to the database.
+func ValidSlug(s string) bool {
+  if len(s) != SlugLength {
+    return false
+  }
+
+  letters := 0
+  for _, c := range []byte(s) {
+    switch {
+    case c >= '0' && c <= '9':
+    case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+      letters++
+    default:
+      return false
+    }
+  }
+
+  return letters > 0
+}
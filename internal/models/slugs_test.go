@@ -0,0 +1,64 @@
+package models
+
+import (
+  "testing"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+)
+
+func TestNewSlug(t *testing.T) {
+  seen := map[string]bool{}
+
+  for range 1000 {
+    slug, err := NewSlug()
+    assert.NilError(t, err)
+    assert.Equal(t, ValidSlug(slug), true)
+    assert.Equal(t, seen[slug], false)
+    seen[slug] = true
+  }
+}
+
+func TestValidSlug(t *testing.T) {
+  tests := []struct {
+    name string
+    slug string
+    want bool
+  }{
+    {
+      name: "Valid",
+      slug: "aZ3kP9qX",
+      want: true,
+    },
+    {
+      name: "Too short",
+      slug: "aZ3kP9q",
+      want: false,
+    },
+    {
+      name: "Too long",
+      slug: "aZ3kP9qXy",
+      want: false,
+    },
+    {
+      name: "All digits",
+      slug: "12345678",
+      want: false,
+    },
+    {
+      name: "Invalid character",
+      slug: "aZ3k-9qX",
+      want: false,
+    },
+    {
+      name: "Empty",
+      slug: "",
+      want: false,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      assert.Equal(t, ValidSlug(tt.slug), tt.want)
+    })
+  }
+}
@@ -1,85 +1,327 @@
 package models
 
 import (
+  "context"
   "database/sql"
   "errors"
+  "slices"
+  "strings"
   "time"
 )
 
 type SnippetModelInterface interface {
-  Insert(title string, content string, expires int) (int, error)
-  Get(id int) (Snippet, error)
-  Latest() ([]Snippet, error)
+  Insert(ctx context.Context, snippet Snippet) (string, error)
+  Get(ctx context.Context, slug string, viewerID int) (Snippet, error)
+  View(ctx context.Context, slug string, viewerID int) (Snippet, error)
+  LegacySlug(ctx context.Context, id int, viewerID int) (string, error)
+  Latest(ctx context.Context, viewerID int) ([]Snippet, error)
+  Search(ctx context.Context, query string, page int, viewerID int) ([]Snippet, error)
+  List(ctx context.Context, filter SnippetFilter) (SnippetPage, error)
+  Tags(ctx context.Context, limit int) ([]Tag, error)
+  Update(ctx context.Context, snippet Snippet, editorID int) error
+  Delete(ctx context.Context, id int) error
+  PurgeExpired(ctx context.Context, before time.Time, limit int) (int, error)
+  PurgeBurned(ctx context.Context, before time.Time) (int, error)
+}
+
+// SearchPageSize is the maximum number of snippets returned by each page of
+// Search() results.
+const SearchPageSize = 10
+
+// ListPageSize is the default number of snippets returned by each page of
+// List() results.
+const ListPageSize = 20
+
+// The sort orders supported by List().
+const (
+  SortNewest = "newest"
+  SortOldest = "oldest"
+)
+
+// The visibility levels a snippet can have. Public snippets can be seen by
+// anyone and are listed on the home page, in the archive and in search
+// results. Unlisted snippets can be seen by anyone who has the link, but
+// aren't listed. Private snippets can only be seen by their author.
+const (
+  VisibilityPublic   = "public"
+  VisibilityUnlisted = "unlisted"
+  VisibilityPrivate  = "private"
+)
+
+// Visibilities holds all of the permitted visibility levels.
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
+// Define a SnippetFilter type to hold the options for List(). Zero values mean
+// "don't filter on this". CreatedAfter is inclusive and CreatedBefore is
+// exclusive. Tag limits the results to snippets with that tag. Sort should be
+// SortNewest (the default) or SortOldest, and Cursor should be empty for the
+// first page or one of the cursors returned in a SnippetPage for subsequent
+// pages. ViewerID is the ID of the user doing the listing (or zero if nobody
+// is logged in), and is always applied; see Snippet.ListedFor().
+type SnippetFilter struct {
+  ViewerID      int
+  AuthorID      int
+  CreatedAfter  time.Time
+  CreatedBefore time.Time
+  Tag           string
+  Sort          string
+  Cursor        string
+  Limit         int
+}
+
+// Define a SnippetPage type to hold one page of List() results. NextCursor and
+// PrevCursor are empty if there is no next or previous page.
+type SnippetPage struct {
+  Snippets    []Snippet
+  NextCursor  string
+  PrevCursor  string
 }
 
 // Define a Snippet type to hold the data for an individual snippet. Notice how
 // the fields of the struct correspond to the fields in our MySQL snippets
 // table?
+// The Slug field holds the random code which identifies the snippet in URLs;
+// unlike the ID, it can't be guessed or used to tell how many snippets exist.
+// The UserID field holds the ID of the user who created the snippet, and
+// Author holds their name (which we pull in from the users table with a join
+// when reading snippets back out). Tags are stored in separate tables, and are
+// sorted alphabetically. Language is the language the content is written in,
+// or the empty string if it should be detected automatically. Visibility is
+// one of the Visibility* constants. If BurnAfterReading is true, the snippet
+// is deleted the first time somebody other than its author views it. Snippets
+// which never expire have a zero Expires time (and NULL in the database).
 type Snippet struct {
-  ID      int
-  Title   string
-  Content string
-  Created time.Time
-  Expires time.Time
+  ID               int
+  Slug             string
+  Title            string
+  Content          string
+  Language         string
+  Visibility       string
+  BurnAfterReading bool
+  Created          time.Time
+  Expires          time.Time
+  UserID           int
+  Author           string
+  Tags             []string
+}
+
+// VisibleTo() reports whether the snippet can be viewed by the given user. A
+// viewerID of zero means nobody is logged in.
+func (s Snippet) VisibleTo(viewerID int) bool {
+  return s.Visibility != VisibilityPrivate || s.UserID == viewerID
+}
+
+// ListedFor() reports whether the snippet should appear in listings and
+// search results for the given user. Authors can always see their own
+// snippets, so that they can find their unlisted and private ones again.
+// Burn-after-reading snippets are never listed for anyone else, so that they
+// can't be destroyed by somebody browsing the archive.
+func (s Snippet) ListedFor(viewerID int) bool {
+  return s.UserID == viewerID ||
+    (s.Visibility == VisibilityPublic && !s.BurnAfterReading)
 }
 
-// Define a SnippetModel type which wraps a sql.DB connection pool.
+// NeverExpires() reports whether the snippet is kept until it's deleted.
+func (s Snippet) NeverExpires() bool {
+  return s.Expires.IsZero()
+}
+
+// BurnsFor() reports whether the given user viewing the snippet will destroy
+// it.
+func (s Snippet) BurnsFor(viewerID int) bool {
+  return s.BurnAfterReading && s.UserID != viewerID
+}
+
+// Define a SnippetModel type which wraps a sql.DB connection pool. Dialect is
+// the kind of database it's connected to, using the names from the
+// migrations package; it's empty for MySQL. The other models have a Dialect
+// field too.
 type SnippetModel struct {
-  DB *sql.DB
+  DB      *sql.DB
+  Dialect string
+}
+
+// snippetSelect is the start of the SELECT statement used by all the methods
+// which read snippets. We join on the users table so that we can return the
+// name of the snippet's author along with the snippet itself.
+const snippetSelect = `SELECT s.id, s.slug, s.title, s.content, s.language,
+  s.visibility, s.burn_after_reading, s.created, s.expires, s.user_id, u.name
+  FROM snippets s
+  INNER JOIN users u ON u.id = s.user_id`
+
+// The unexpired condition matches snippets which haven't expired yet,
+// including those which never expire.
+const unexpired = `(s.expires IS NULL OR s.expires > UTC_TIMESTAMP())`
+
+// The visibleTo, listedFor and notBurnedBy conditions implement
+// Snippet.VisibleTo(), Snippet.ListedFor() and the opposite of
+// Snippet.BurnsFor() in SQL. Each takes the ID of the viewing user as its
+// placeholder parameter. Because user IDs start at 1, passing zero for an
+// anonymous viewer never matches the author.
+const (
+  visibleTo   = `(s.visibility <> 'private' OR s.user_id = ?)`
+  listedFor   = `(s.user_id = ?
+  OR (s.visibility = 'public' AND NOT s.burn_after_reading))`
+  notBurnedBy = `(NOT s.burn_after_reading OR s.user_id = ?)`
+)
+
+// scanner is the interface shared by *sql.Row and *sql.Rows.
+type scanner interface {
+  Scan(dest ...any) error
+}
+
+// scanSnippet() copies the columns selected by snippetSelect into a new
+// Snippet struct.
+func scanSnippet(row scanner) (Snippet, error) {
+  var s Snippet
+  var expires sql.NullTime
+  err := row.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Language,
+    &s.Visibility, &s.BurnAfterReading, &s.Created, &expires, &s.UserID,
+    &s.Author)
+  // A NULL expiry leaves s.Expires as the zero time, meaning "never".
+  s.Expires = expires.Time
+  return s, err
+}
+
+// nullTime() converts a time to a sql.NullTime, treating the zero time as
+// NULL.
+func nullTime(t time.Time) sql.NullTime {
+  return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
 }
 
-// This will insert a new snippet into the database.
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// querySnippets() runs a query which starts with snippetSelect and returns
+// the resulting snippets, complete with their tags.
+func (m *SnippetModel) querySnippets(ctx context.Context, stmt string, args ...any) ([]Snippet, error) {
+  d := dialectFor(m.Dialect)
+
+  rows, err := m.DB.QueryContext(ctx, d.rebind(stmt), args...)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  var snippets []Snippet
+
+  for rows.Next() {
+    s, err := scanSnippet(rows)
+    if err != nil {
+      return nil, err
+    }
+    snippets = append(snippets, s)
+  }
+
+  if err = rows.Err(); err != nil {
+    return nil, err
+  }
+
+  err = attachTags(ctx, d, m.DB, snippets)
+  if err != nil {
+    return nil, err
+  }
+
+  return snippets, nil
+}
+
+// This will insert a new snippet into the database and return its slug. The
+// title, content, language, visibility, burn after reading flag, expiry time,
+// user ID and tags are all taken from the snippet.
+func (m *SnippetModel) Insert(ctx context.Context, snippet Snippet) (string, error) {
+  d := dialectFor(m.Dialect)
+
   // Write the SQL statement we want to execute. I've split it over two lines
   // for readability (which is why it's surrounded with backquotes instead
   // of normal double quotes.
-  stmt := `INSERT INTO snippets (title, content, created, expires)
-  VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+  stmt := `INSERT INTO snippets (slug, title, content, language, visibility,
+  burn_after_reading, created, expires, user_id)
+  VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?, ?) ` + d.skipConflicts
+
+  // Begin a transaction, so that the snippet, its tags and its first revision
+  // are either all saved or not saved at all. The transaction is rolled back
+  // if ctx is cancelled before it's committed. Deferring tx.Rollback() makes
+  // sure the transaction is always cleaned up; if tx.Commit() has already
+  // been called by then it is a no-op.
+  tx, err := m.DB.BeginTx(ctx, nil)
+  if err != nil {
+    return "", err
+  }
+  defer tx.Rollback()
 
-  // Use the Exec() method on the embedded connection pool to execute the
-  // statement. The first parameter is the SQL statement, followed by the
-  // values for the placeholder parameters: title, content, and expiry in that
-  // order. This method returns a sql.Result type, which contains some basic
-  // information about what happened when the statement was executed.
-  result, err := m.DB.Exec(stmt, title, content, expires)
+  // Use the dialect's insertID() helper to execute the statement. The first
+  // parameters are the context, the transaction and the SQL statement,
+  // followed by the values for the placeholder parameters: slug, title,
+  // content, language, visibility, burn after reading flag, expiry and the ID
+  // of the user who created the snippet in that order. It returns the ID of
+  // our newly inserted record in the snippets table.
+  //
+  // The slug is random, so there's a tiny chance that it's already in use. If
+  // so we try again with a new slug. In MySQL the snippets_uc_slug constraint
+  // rejects the row, but only the failed statement is rolled back, so it's
+  // fine to carry on using tx. The other databases skip the row instead (see
+  // skipConflicts), and so no ID is returned.
+  var id int
+
+  for attempt := 1; ; attempt++ {
+    snippet.Slug, err = NewSlug()
+    if err != nil {
+      return "", err
+    }
+
+    id, err = d.insertID(ctx, tx, stmt, snippet.Slug, snippet.Title, snippet.Content,
+      snippet.Language, snippet.Visibility, snippet.BurnAfterReading,
+      nullTime(snippet.Expires), snippet.UserID)
+    if err == nil {
+      break
+    }
+
+    collision := errors.Is(err, sql.ErrNoRows) ||
+      isUniqueViolation(err, "snippets_uc_slug", "snippets.slug")
+    if !collision || attempt == MaxSlugAttempts {
+      return "", err
+    }
+  }
+
+  err = setTags(ctx, d, tx, id, snippet.Tags)
   if err != nil {
-    return 0, nil
+    return "", err
   }
 
-  // Use the LastInsertId() method on the result to get the ID of our
-  // newly inserted record in the snippets table.
-  id, err := result.LastInsertId()
+  // Record the new snippet as revision 1.
+  err = insertRevision(ctx, d, tx, id, snippet.UserID)
   if err != nil {
-    return 0, err
+    return "", err
   }
 
-  // The ID returned has the type int64, so we convert it to an int type
-  // before returning.
-  return int(id), nil
+  err = tx.Commit()
+  if err != nil {
+    return "", err
+  }
+
+  return snippet.Slug, nil
 }
 
-// This will return a specific snippet based on its id.
-func (m *SnippetModel) Get(id int) (Snippet, error) {
+// This will return a specific snippet based on its slug, as long as it is
+// visible to the given viewer. Private snippets belonging to somebody else
+// result in ErrNoRecord, just as if they didn't exist. So do burn-after-reading
+// snippets belonging to somebody else; they can only be read with View().
+func (m *SnippetModel) Get(ctx context.Context, slug string, viewerID int) (Snippet, error) {
+  d := dialectFor(m.Dialect)
+
   // Write the SQL statement we want to execute. Again, I've split it over two
   // lines for readability.
-  stmt := `SELECT id, title, content, created, expires FROM snippets
-  WHERE expires > UTC_TIMESTAMP() and id = ?`
+  stmt := snippetSelect + `
+  WHERE ` + unexpired + ` and s.slug = ? AND ` + visibleTo + `
+  AND ` + notBurnedBy
 
-  // Use the QueryRow() method on the connection pool to execute our
-  // SQL statement, passing in the untrusted id variable as the value for the
-  // placeholder parameter. This returns a pointer to a sql.Row object which
+  // Use the QueryRowContext() method on the connection pool to execute our
+  // SQL statement, passing in the untrusted slug variable as the value for the
+  // placeholder parameter. If ctx is cancelled, or its deadline passes, the
+  // query is cancelled too. This returns a pointer to a sql.Row object which
   // holds the result from the database.
-  row := m.DB.QueryRow(stmt, id)
-
-  // Initialize a new zeroed Snippet struct.
-  var s Snippet
+  row := m.DB.QueryRowContext(ctx, d.rebind(stmt), slug, viewerID, viewerID)
 
-  // Use row.Scan() to copy the values from each field in sql.Row to the
-  // corresponding field in the Snippet struct. Notice that the arguments
-  // to row.Scan are *pointers* to the place you want to copy the data into,
-  // and the number of arguments must be exactly the same as the number of 
-  // columns returned by your statement.
-  err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+  // Use the scanSnippet() helper to copy the values from each field in
+  // sql.Row to the corresponding field in a new Snippet struct.
+  s, err := scanSnippet(row)
   if err != nil {
     // If the query returns no rows. then row.Scan() will return a 
     // sql.ErrNoRows error. We use the errors.Is() function check for that
@@ -92,29 +334,132 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
     }
   }
 
+  // Tags are stored in a separate table, so fetch them with a second query.
+  s.Tags, err = snippetTags(ctx, d, m.DB, s.ID)
+  if err != nil {
+    return Snippet{}, err
+  }
+
   // If everything went OK, then return the filled Snippet struct.
   return s, nil
 }
 
-// This will return the 10 most recently created Snippets.
-func (m *SnippetModel) Latest() ([]Snippet, error) {
+// This will return a snippet for the given viewer to read. It works like Get(),
+// except that burn-after-reading snippets are included, and if viewing the
+// snippet burns it then it is deleted in the same transaction. We lock the
+// row while we read it, so if two people view a burn-after-reading snippet at
+// the same time, only one of them will see it; the other gets ErrBurned, as
+// does anybody who comes along afterwards.
+//
+// The second viewer waits for the first to finish. When it does, the row has
+// gone, so nothing is returned, and the burned_snippets row inserted by the
+// first transaction is visible to our next statement.
+func (m *SnippetModel) View(ctx context.Context, slug string, viewerID int) (Snippet, error) {
+  d := dialectFor(m.Dialect)
+
+  stmt := snippetSelect + `
+  WHERE ` + unexpired + ` AND s.slug = ? AND ` + visibleTo + `
+  ` + d.forUpdate
+
+  tx, err := m.DB.BeginTx(ctx, nil)
+  if err != nil {
+    return Snippet{}, err
+  }
+  defer tx.Rollback()
+
+  s, err := scanSnippet(tx.QueryRowContext(ctx, d.rebind(stmt), slug, viewerID))
+  if err != nil {
+    if !errors.Is(err, sql.ErrNoRows) {
+      return Snippet{}, err
+    }
+
+    // Check whether the snippet existed but has been burned, so that we can
+    // tell the viewer what happened to it.
+    var burned bool
+    err = tx.QueryRowContext(ctx, d.rebind(`SELECT EXISTS(SELECT 1 FROM burned_snippets
+    WHERE slug = ?)`), slug).Scan(&burned)
+    if err != nil {
+      return Snippet{}, err
+    }
+    if burned {
+      return Snippet{}, ErrBurned
+    }
+    return Snippet{}, ErrNoRecord
+  }
+
+  s.Tags, err = snippetTags(ctx, d, tx, s.ID)
+  if err != nil {
+    return Snippet{}, err
+  }
+
+  if s.BurnsFor(viewerID) {
+    // Deleting the snippet also deletes its tags and revisions, thanks to the
+    // ON DELETE CASCADE foreign keys. We keep a record of the slug, so that we
+    // can say the snippet has been burned rather than that it never existed.
+    _, err = tx.ExecContext(ctx, d.rebind("DELETE FROM snippets WHERE id = ?"), s.ID)
+    if err != nil {
+      return Snippet{}, err
+    }
+
+    _, err = tx.ExecContext(ctx, d.rebind(`INSERT INTO burned_snippets (slug, burned)
+    VALUES(?, UTC_TIMESTAMP())`), s.Slug)
+    if err != nil {
+      return Snippet{}, err
+    }
+  }
+
+  err = tx.Commit()
+  if err != nil {
+    return Snippet{}, err
+  }
+
+  return s, nil
+}
+
+// This will return the slug of the snippet with the given numeric ID, so that
+// old URLs which used IDs can be redirected. Only snippets which are listed
+// for the viewer are found; otherwise it would be possible to discover the
+// slugs of unlisted snippets by trying every ID in turn.
+func (m *SnippetModel) LegacySlug(ctx context.Context, id int, viewerID int) (string, error) {
+  stmt := `SELECT s.slug FROM snippets s
+  WHERE ` + unexpired + ` AND s.id = ? AND ` + listedFor
+
+  var slug string
+
+  err := m.DB.QueryRowContext(ctx, dialectFor(m.Dialect).rebind(stmt), id, viewerID).Scan(&slug)
+  if err != nil {
+    if errors.Is(err, sql.ErrNoRows) {
+      return "", ErrNoRecord
+    }
+    return "", err
+  }
+
+  return slug, nil
+}
+
+// This will return the 10 most recently created Snippets which are listed for
+// the given viewer.
+func (m *SnippetModel) Latest(ctx context.Context, viewerID int) ([]Snippet, error) {
+  d := dialectFor(m.Dialect)
+
   // Write the SQL statement we want to execute.
-  stmt := `SELECT id, title, content, created, expires FROM snippets
-  WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+  stmt := snippetSelect + `
+  WHERE ` + unexpired + ` AND ` + listedFor + `
+  ORDER BY s.id DESC LIMIT 10`
 
-  // Use the Query() method on the connection pool to execute our
+  // Use the QueryContext() method on the connection pool to execute our
   // SQL statement. This returns a sql.Rows resultset containing the result
   // of our query.
-  rows, err := m.DB.Query(stmt)
+  rows, err := m.DB.QueryContext(ctx, d.rebind(stmt), viewerID)
   if err != nil {
     return nil, err
   }
 
   // We defer rows.Close() to ensure the sql.Rows resultset is always properly
   // closed before the Latest() method returns. This defer statement should
-  // come *after* uou check for an error from the Query() method. Otherwise,
-  // if Query() returns an error, you'll get a panic trying to close a nil 
-  // resultset.
+  // come *after* uou check for an error from the QueryContext() method.
+  // Otherwise, if QueryContext() returns an error, you'll get a panic trying
+  // to close a nil resultset.
   defer rows.Close()
 
   // Initialize an empty slice to hold the Snippets structs.
@@ -126,14 +471,9 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
   // automatically closes itself and frees-up the underlying database
   // connection.
   for rows.Next() {
-    // Create a new zeroed Snippet struct
-    var s Snippet
-    // Use rows.Scan() to copy the values from each field in the row to the new
-    // Snippet object thta we created. Again, the argumments to row.Scan() must
-    // be pointers to the place you want to copy the data into, and the number
-    // of arguments must be exactly the same as the number of columns returned
-    // by your statment.
-    err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+    // Use scanSnippet() to copy the values from each field in the row to a new
+    // Snippet object.
+    s, err := scanSnippet(rows)
     if err != nil {
       return nil, err
     }
@@ -149,6 +489,307 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
     return nil, err
   }
 
+  // Fetch the tags for all of the snippets in one go.
+  err = attachTags(ctx, d, m.DB, snippets)
+  if err != nil {
+    return nil, err
+  }
+
   // If everything went Ok then return the Snippets slice'
   return snippets, nil
 }
+
+// This will update the title, content, language, visibility, burn after
+// reading flag, expiry time and tags of an existing snippet, and record the
+// result as a new revision made by the given editor. If there's no matching
+// snippet we return the ErrNoRecord error.
+func (m *SnippetModel) Update(ctx context.Context, snippet Snippet, editorID int) error {
+  d := dialectFor(m.Dialect)
+
+  // Postgres and SQLite don't allow the columns being set to be qualified
+  // with the table alias, so we leave it off.
+  stmt := `UPDATE snippets AS s SET title = ?, content = ?, language = ?,
+  visibility = ?, burn_after_reading = ?, expires = ?
+  WHERE s.id = ? AND ` + unexpired
+
+  tx, err := m.DB.BeginTx(ctx, nil)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback()
+
+  result, err := tx.ExecContext(ctx, d.rebind(stmt), snippet.Title, snippet.Content, snippet.Language,
+    snippet.Visibility, snippet.BurnAfterReading, nullTime(snippet.Expires),
+    snippet.ID)
+  if err != nil {
+    return err
+  }
+
+  // If the snippet doesn't exist (or has expired) nothing was updated, and
+  // there's nothing to record a revision of. MySQL doesn't count rows which
+  // already had the new values, though, so when nothing changed we have to
+  // check whether the snippet is there.
+  rows, err := result.RowsAffected()
+  if err != nil {
+    return err
+  }
+  if rows == 0 {
+    var exists bool
+    err = tx.QueryRowContext(ctx, d.rebind("SELECT EXISTS(SELECT true FROM snippets s WHERE s.id = ? AND "+
+      unexpired+")"), snippet.ID).Scan(&exists)
+    if err != nil {
+      return err
+    }
+    if !exists {
+      return ErrNoRecord
+    }
+  }
+
+  err = setTags(ctx, d, tx, snippet.ID, snippet.Tags)
+  if err != nil {
+    return err
+  }
+
+  err = insertRevision(ctx, d, tx, snippet.ID, editorID)
+  if err != nil {
+    return err
+  }
+
+  return tx.Commit()
+}
+
+// This will delete a specific snippet based on its id. If no matching snippet
+// exists we return the ErrNoRecord error.
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+  stmt := "DELETE FROM snippets WHERE id = ?"
+
+  result, err := m.DB.ExecContext(ctx, dialectFor(m.Dialect).rebind(stmt), id)
+  if err != nil {
+    return err
+  }
+
+  // Use the RowsAffected() method on the result to check whether a snippet
+  // was actually deleted.
+  rows, err := result.RowsAffected()
+  if err != nil {
+    return err
+  }
+
+  if rows == 0 {
+    return ErrNoRecord
+  }
+
+  return nil
+}
+
+// This will permanently delete up to limit snippets which expired before the
+// given time, oldest first, and return how many were deleted. Their tags and
+// revisions are deleted along with them. Callers which want to delete every
+// expired snippet should call it repeatedly until it returns fewer than limit;
+// keeping each batch small stops one huge DELETE from locking the table for a
+// long time.
+func (m *SnippetModel) PurgeExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+  d := dialectFor(m.Dialect)
+
+  result, err := m.DB.ExecContext(ctx, d.rebind(d.purgeExpired), before.UTC(), limit)
+  if err != nil {
+    return 0, err
+  }
+
+  rows, err := result.RowsAffected()
+  if err != nil {
+    return 0, err
+  }
+
+  return int(rows), nil
+}
+
+// This will permanently forget the slugs of burn-after-reading snippets which
+// were burned before the given time, and return how many were forgotten.
+// After that, their URLs say that the snippet doesn't exist, rather than that
+// it has been burned. Only a few snippets are burned at a time, so unlike
+// PurgeExpired() there's no need to do this in batches.
+func (m *SnippetModel) PurgeBurned(ctx context.Context, before time.Time) (int, error) {
+  stmt := "DELETE FROM burned_snippets WHERE burned < ?"
+
+  result, err := m.DB.ExecContext(ctx, dialectFor(m.Dialect).rebind(stmt), before.UTC())
+  if err != nil {
+    return 0, err
+  }
+
+  rows, err := result.RowsAffected()
+  if err != nil {
+    return 0, err
+  }
+
+  return int(rows), nil
+}
+
+// This will return a page of unexpired snippets whose title or content match
+// the search query, using the database's full-text search, or which are
+// tagged with one of the words in the query. Only snippets which are listed
+// for the viewer are included. Results are ranked by relevance (most relevant
+// first), and page numbers start at 1.
+func (m *SnippetModel) Search(ctx context.Context, query string, page int, viewerID int) ([]Snippet, error) {
+  d := dialectFor(m.Dialect)
+
+  // If there are no words in the query, nothing can match it.
+  terms := strings.Fields(strings.ToLower(query))
+  if len(terms) == 0 {
+    return nil, nil
+  }
+
+  text := query
+  if d.searchText != nil {
+    text = d.searchText(terms)
+  }
+
+  // Tags can't be part of the full-text index, so we look for them
+  // separately and add one to the relevance score for each matching tag.
+  placeholders := strings.Repeat("?, ", len(terms)-1) + "?"
+
+  tagMatches := `SELECT COUNT(*) FROM snippet_tags st
+  INNER JOIN tags t ON t.id = st.tag_id
+  WHERE st.snippet_id = s.id AND t.name IN (` + placeholders + `)`
+
+  stmt := snippetSelect + `
+  WHERE ` + unexpired + ` AND ` + listedFor + `
+  AND (` + d.textMatch + ` OR (` + tagMatches + `) > 0)
+  ORDER BY ` + d.textScore + ` + (` + tagMatches + `) DESC, s.id DESC
+  LIMIT ? OFFSET ?`
+
+  if page < 1 {
+    page = 1
+  }
+  offset := (page - 1) * SearchPageSize
+
+  args := []any{viewerID}
+  for range 2 {
+    args = append(args, text)
+    for _, term := range terms {
+      args = append(args, term)
+    }
+  }
+  args = append(args, SearchPageSize, offset)
+
+  return m.querySnippets(ctx, stmt, args...)
+}
+
+// This will return a page of unexpired snippets matching the filter. Snippets
+// are ordered by ID, which follows the order in which they were created, and
+// we use the ID of the first or last snippet on the page as the cursor for
+// the previous or next page.
+func (m *SnippetModel) List(ctx context.Context, filter SnippetFilter) (SnippetPage, error) {
+  cursor, err := DecodeCursor(filter.Cursor)
+  if err != nil {
+    return SnippetPage{}, err
+  }
+
+  limit := filter.Limit
+  if limit < 1 {
+    limit = ListPageSize
+  }
+
+  // Build up the WHERE clause and its arguments based on the filter.
+  conditions := []string{unexpired, listedFor}
+  args := []any{filter.ViewerID}
+
+  if filter.AuthorID != 0 {
+    conditions = append(conditions, "s.user_id = ?")
+    args = append(args, filter.AuthorID)
+  }
+  if !filter.CreatedAfter.IsZero() {
+    conditions = append(conditions, "s.created >= ?")
+    args = append(args, filter.CreatedAfter.UTC())
+  }
+  if !filter.CreatedBefore.IsZero() {
+    conditions = append(conditions, "s.created < ?")
+    args = append(args, filter.CreatedBefore.UTC())
+  }
+  if filter.Tag != "" {
+    conditions = append(conditions, `EXISTS (SELECT 1 FROM snippet_tags st
+    INNER JOIN tags t ON t.id = st.tag_id
+    WHERE st.snippet_id = s.id AND t.name = ?)`)
+    args = append(args, filter.Tag)
+  }
+
+  // When paging backwards we query in the opposite order to the requested
+  // one (so that the rows nearest the cursor come first), and then reverse
+  // the results afterwards.
+  ascending := filter.Sort == SortOldest
+  if cursor.Backward {
+    ascending = !ascending
+  }
+
+  order := "DESC"
+  if ascending {
+    order = "ASC"
+  }
+
+  if cursor.ID != 0 {
+    if ascending {
+      conditions = append(conditions, "s.id > ?")
+    } else {
+      conditions = append(conditions, "s.id < ?")
+    }
+    args = append(args, cursor.ID)
+  }
+
+  // Fetch one more row than we need, so that we can tell if there are any
+  // more rows beyond this page.
+  stmt := snippetSelect + `
+  WHERE ` + strings.Join(conditions, " AND ") + `
+  ORDER BY s.id ` + order + ` LIMIT ?`
+  args = append(args, limit+1)
+
+  snippets, err := m.querySnippets(ctx, stmt, args...)
+  if err != nil {
+    return SnippetPage{}, err
+  }
+
+  return NewSnippetPage(snippets, cursor, limit), nil
+}
+
+// NewSnippetPage() builds a SnippetPage from up to limit+1 snippets fetched
+// from the given cursor position, working out the cursors for the next and
+// previous pages. The snippets should be in the order they were fetched in
+// (i.e. reversed, when paging backwards).
+func NewSnippetPage(snippets []Snippet, cursor Cursor, limit int) SnippetPage {
+  more := len(snippets) > limit
+  if more {
+    snippets = snippets[:limit]
+  }
+
+  if cursor.Backward {
+    slices.Reverse(snippets)
+  }
+
+  var page SnippetPage
+  page.Snippets = snippets
+
+  if len(snippets) == 0 {
+    return page
+  }
+
+  first := snippets[0].ID
+  last := snippets[len(snippets)-1].ID
+
+  // Going forwards, there's a next page if we found more rows, and there's a
+  // previous page if we got here from another page. Going backwards, it's
+  // the other way around.
+  if cursor.Backward {
+    page.NextCursor = Cursor{ID: last}.Encode()
+    if more {
+      page.PrevCursor = Cursor{ID: first, Backward: true}.Encode()
+    }
+  } else {
+    if more {
+      page.NextCursor = Cursor{ID: last}.Encode()
+    }
+    if cursor.ID != 0 {
+      page.PrevCursor = Cursor{ID: first, Backward: true}.Encode()
+    }
+  }
+
+  return page
+}
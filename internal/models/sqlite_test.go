@@ -0,0 +1,187 @@
+package models_test
+
+import (
+  "context"
+  "database/sql"
+  "path/filepath"
+  "testing"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+  "github.com/kjloveless/snippetbox/internal/database"
+  "github.com/kjloveless/snippetbox/internal/migrations"
+  "github.com/kjloveless/snippetbox/internal/models"
+  "github.com/kjloveless/snippetbox/internal/models/modeltest"
+
+  "github.com/alexedwards/scs/sqlite3store"
+)
+
+// newSQLiteTestDB() creates a new SQLite database in a temporary directory, which
+// is removed when the test finishes. The schema is created by the SQLite
+// migrations, so these tests check them too, and then we add Alice. Because
+// nothing needs to be running beforehand, these tests aren't skipped by the
+// "-short" flag.
+func newSQLiteTestDB(t *testing.T) *sql.DB {
+  db, err := sql.Open("sqlite", database.SQLiteDSN(filepath.Join(t.TempDir(), "test.db")))
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() { db.Close() })
+
+  migrator, err := migrations.New(db, migrations.SQLite)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  _, err = migrator.Up(context.Background())
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  _, err = db.Exec(`INSERT INTO users (name, email, hashed_password, created)
+  VALUES ('Alice Jones', 'alice@example.com',
+  '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
+  '2022-01-01 09:18:24')`)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  return db
+}
+
+// TestSQLiteModels runs the tests shared by every database against SQLite.
+func TestSQLiteModels(t *testing.T) {
+  modeltest.Run(t, func(t *testing.T) modeltest.Models {
+    db := newSQLiteTestDB(t)
+
+    return modeltest.Models{
+      Snippets:  &models.SnippetModel{DB: db, Dialect: migrations.SQLite},
+      Users:     &models.UserModel{DB: db, Dialect: migrations.SQLite},
+      Revisions: &models.RevisionModel{DB: db, Dialect: migrations.SQLite},
+      Tokens:    &models.TokenModel{DB: db, Dialect: migrations.SQLite},
+      Admin:     &models.AdminModel{DB: db, Dialect: migrations.SQLite},
+    }
+  })
+}
+
+func TestSQLiteMigrationsDown(t *testing.T) {
+  db := newSQLiteTestDB(t)
+
+  migrator, err := migrations.New(db, migrations.SQLite)
+  assert.NilError(t, err)
+
+  // Rolling back every migration should leave nothing behind but the
+  // schema_migrations table.
+  for range migrator.Migrations {
+    _, err = migrator.Down(context.Background())
+    assert.NilError(t, err)
+  }
+
+  var tables int
+  err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master
+  WHERE type = 'table' AND name <> 'schema_migrations'`).Scan(&tables)
+  assert.NilError(t, err)
+  assert.Equal(t, tables, 0)
+}
+
+func TestSQLiteMigrationsBaseline(t *testing.T) {
+  db, err := sql.Open("sqlite", database.SQLiteDSN(filepath.Join(t.TempDir(), "test.db")))
+  assert.NilError(t, err)
+  t.Cleanup(func() { db.Close() })
+
+  migrator, err := migrations.New(db, migrations.SQLite)
+  assert.NilError(t, err)
+
+  // Make the tables by hand, the way they were before there were migrations,
+  // and add two snippets.
+  _, err = db.Exec(migrator.Migrations[0].Up)
+  assert.NilError(t, err)
+
+  _, err = db.Exec(`INSERT INTO snippets (title, content, created, expires)
+  VALUES ('An old snail', 'O snail', '2022-01-01 10:00:00', '2099-01-01 10:00:00'),
+  ('A frog', 'A frog jumps', '2022-01-01 11:00:00', '2099-01-01 11:00:00')`)
+  assert.NilError(t, err)
+
+  // Without the baseline, the first migration tries to make the tables again.
+  _, err = migrator.Up(context.Background())
+  if err == nil {
+    t.Fatal("got no error from Up() on a database without a baseline")
+  }
+
+  baseline, err := migrator.Baseline(context.Background())
+  assert.NilError(t, err)
+  assert.Equal(t, baseline.Version, 1)
+
+  _, err = migrator.Baseline(context.Background())
+  assert.Equal(t, err, migrations.ErrAlreadyVersioned)
+
+  _, err = migrator.Up(context.Background())
+  assert.NilError(t, err)
+
+  // The old snippets have been given random slugs, like new ones, which can
+  // still be found from their IDs. They belong to the placeholder user, who
+  // can't log in.
+  snippets := &models.SnippetModel{DB: db, Dialect: migrations.SQLite}
+  slug, err := snippets.LegacySlug(context.Background(), 1, 0)
+  assert.NilError(t, err)
+  assert.Equal(t, models.ValidSlug(slug), true)
+
+  other, err := snippets.LegacySlug(context.Background(), 2, 0)
+  assert.NilError(t, err)
+  assert.Equal(t, models.ValidSlug(other), true)
+  if other == slug {
+    t.Errorf("both snippets have the slug %q", slug)
+  }
+
+  s, err := snippets.Get(context.Background(), slug, 0)
+  assert.NilError(t, err)
+  assert.Equal(t, s.Title, "An old snail")
+
+  var owner string
+  var disabled bool
+  err = db.QueryRow(`SELECT users.email, users.disabled FROM snippets
+  JOIN users ON users.id = snippets.user_id WHERE snippets.slug = ?`, slug).Scan(&owner, &disabled)
+  assert.NilError(t, err)
+  assert.Equal(t, owner, "anonymous@snippetbox.invalid")
+  assert.Equal(t, disabled, true)
+
+  // It can be searched for too.
+  found, err := snippets.Search(context.Background(), "snail", 1, 0)
+  assert.NilError(t, err)
+  assert.Equal(t, len(found), 1)
+}
+
+func TestSQLiteSessionStore(t *testing.T) {
+  db := newSQLiteTestDB(t)
+
+  // The session store comes from the scs package, so this checks that it
+  // works with the sessions table made by our migrations.
+  s := sqlite3store.NewWithCleanupInterval(db, 0)
+
+  err := s.Commit("t0ken", []byte("data"), time.Now().Add(time.Hour))
+  assert.NilError(t, err)
+
+  // Committing again replaces the data.
+  err = s.Commit("t0ken", []byte("new data"), time.Now().Add(time.Hour))
+  assert.NilError(t, err)
+
+  b, found, err := s.Find("t0ken")
+  assert.NilError(t, err)
+  assert.Equal(t, found, true)
+  assert.Equal(t, string(b), "new data")
+
+  err = s.Delete("t0ken")
+  assert.NilError(t, err)
+
+  _, found, err = s.Find("t0ken")
+  assert.NilError(t, err)
+  assert.Equal(t, found, false)
+
+  // Expired sessions aren't found.
+  err = s.Commit("0ld", []byte("data"), time.Now().Add(-time.Minute))
+  assert.NilError(t, err)
+
+  _, found, err = s.Find("0ld")
+  assert.NilError(t, err)
+  assert.Equal(t, found, false)
+}
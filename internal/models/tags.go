@@ -0,0 +1,165 @@
+package models
+
+import (
+  "context"
+  "database/sql"
+  "strings"
+)
+
+// Define a Tag type to hold a tag name along with the number of unexpired
+// snippets that have that tag.
+type Tag struct {
+  Name  string
+  Count int
+}
+
+// setTags() replaces the tags on a snippet. Any tags which don't exist yet
+// are created. It takes a *sql.Tx so that the tags are saved in the same
+// transaction as the snippet itself.
+func setTags(ctx context.Context, d dialect, tx *sql.Tx, snippetID int, tags []string) error {
+  _, err := tx.ExecContext(ctx, d.rebind("DELETE FROM snippet_tags WHERE snippet_id = ?"), snippetID)
+  if err != nil {
+    return err
+  }
+
+  // Two names can belong to the same tag (MySQL compares them without regard
+  // to case, for example), so we keep track of the tags we've added to make
+  // sure we only add each one once.
+  added := make(map[int]bool, len(tags))
+
+  for _, tag := range tags {
+    tagID, err := d.insertID(ctx, tx, d.upsertTag, tag)
+    if err != nil {
+      return err
+    }
+
+    if added[tagID] {
+      continue
+    }
+    added[tagID] = true
+
+    _, err = tx.ExecContext(ctx, d.rebind(`INSERT INTO snippet_tags (snippet_id, tag_id)
+    VALUES (?, ?)`), snippetID, tagID)
+    if err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx, so that helpers which only
+// read data can be used either inside or outside a transaction.
+type querier interface {
+  QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// snippetTags() returns the tags for a single snippet.
+func snippetTags(ctx context.Context, d dialect, db querier, snippetID int) ([]string, error) {
+  stmt := `SELECT t.name FROM tags t
+  INNER JOIN snippet_tags st ON st.tag_id = t.id
+  WHERE st.snippet_id = ? ORDER BY t.name`
+
+  rows, err := db.QueryContext(ctx, d.rebind(stmt), snippetID)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  var tags []string
+
+  for rows.Next() {
+    var tag string
+    err = rows.Scan(&tag)
+    if err != nil {
+      return nil, err
+    }
+    tags = append(tags, tag)
+  }
+
+  if err = rows.Err(); err != nil {
+    return nil, err
+  }
+
+  return tags, nil
+}
+
+// attachTags() fills in the Tags field for a slice of snippets, using a single
+// query rather than one per snippet.
+func attachTags(ctx context.Context, d dialect, db *sql.DB, snippets []Snippet) error {
+  if len(snippets) == 0 {
+    return nil
+  }
+
+  // Build a map from snippet ID to position in the slice, along with the
+  // arguments for the IN clause.
+  index := make(map[int]int, len(snippets))
+  args := make([]any, len(snippets))
+  for i, s := range snippets {
+    index[s.ID] = i
+    args[i] = s.ID
+  }
+
+  stmt := `SELECT st.snippet_id, t.name FROM tags t
+  INNER JOIN snippet_tags st ON st.tag_id = t.id
+  WHERE st.snippet_id IN (` + strings.Repeat("?, ", len(args)-1) + `?)
+  ORDER BY t.name`
+
+  rows, err := db.QueryContext(ctx, d.rebind(stmt), args...)
+  if err != nil {
+    return err
+  }
+  defer rows.Close()
+
+  for rows.Next() {
+    var snippetID int
+    var tag string
+    err = rows.Scan(&snippetID, &tag)
+    if err != nil {
+      return err
+    }
+
+    i := index[snippetID]
+    snippets[i].Tags = append(snippets[i].Tags, tag)
+  }
+
+  return rows.Err()
+}
+
+// This will return the most used tags on unexpired public snippets, along with
+// how many snippets use each one, ordered by name. Unlisted, private and
+// burn-after-reading snippets aren't counted, so that the tag cloud doesn't
+// reveal anything about them.
+func (m *SnippetModel) Tags(ctx context.Context, limit int) ([]Tag, error) {
+  stmt := `SELECT name, uses FROM (
+    SELECT t.name, COUNT(*) AS uses FROM tags t
+    INNER JOIN snippet_tags st ON st.tag_id = t.id
+    INNER JOIN snippets s ON s.id = st.snippet_id
+    WHERE ` + unexpired + ` AND s.visibility = 'public'
+    AND NOT s.burn_after_reading
+    GROUP BY t.id, t.name ORDER BY uses DESC, t.name LIMIT ?
+  ) AS popular ORDER BY name`
+
+  rows, err := m.DB.QueryContext(ctx, dialectFor(m.Dialect).rebind(stmt), limit)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  var tags []Tag
+
+  for rows.Next() {
+    var t Tag
+    err = rows.Scan(&t.Name, &t.Count)
+    if err != nil {
+      return nil, err
+    }
+    tags = append(tags, t)
+  }
+
+  if err = rows.Err(); err != nil {
+    return nil, err
+  }
+
+  return tags, nil
+}
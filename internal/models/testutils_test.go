@@ -1,33 +1,39 @@
 package models
 
 import (
+  "context"
   "database/sql"
-  "os"
   "testing"
+
+  "github.com/kjloveless/snippetbox/internal/migrations"
 )
 
 func newTestDB(t *testing.T) *sql.DB {
-  // Establish a sql.DB connection pool for out test database. Because our
-  // setup and teardown scripts contain multiple SQL statements, we need to use
-  // the "multiStatements=true" parameter in our DSN. This instructs our MySQL
-  // database driver to support executing multiple SQL statements in one
-  // db.Exec() call.
-  db, err := sql.Open("mysql",
-    "test_web:pass@/test_snippetbox?parseTime=true&multiStatements=true")
+  // Establish a sql.DB connection pool for our test database.
+  db, err := sql.Open("mysql", "test_web:pass@/test_snippetbox?parseTime=true")
   if err != nil {
     t.Fatal(err)
   }
 
-  // Read the setup SQL script from the file and execute the statements,
-  // closing the connection pool and calling t.Fatal() in the event of an
-  // error.
-  script, err := os.ReadFile("./testdata/setup.sql")
+  // Create the tables by applying the MySQL migrations, in the same way as
+  // "snippetadmin migrate up" does, so that the tests use exactly the schema
+  // which a real database has. Then add the user which the tests expect.
+  migrator, err := migrations.New(db, migrations.MySQL)
   if err != nil {
     db.Close()
     t.Fatal(err)
   }
 
-  _, err = db.Exec(string(script))
+  _, err = migrator.Up(context.Background())
+  if err != nil {
+    db.Close()
+    t.Fatal(err)
+  }
+
+  _, err = db.Exec(`INSERT INTO users (name, email, hashed_password, created)
+  VALUES ('Alice Jones', 'alice@example.com',
+  '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
+  '2022-01-01 09:18:24')`)
   if err != nil {
     db.Close()
     t.Fatal(err)
@@ -35,16 +41,20 @@ func newTestDB(t *testing.T) *sql.DB {
 
   // Use t.Cleanup() to register a function *which will automatically be called
   // by Go when the current test (or sub-test) which calls newTestDB() has
-  // finished*. In this function we read and execute the teardown script, and
-  // close the database connection pool.
+  // finished*. In this function we roll back every migration, drop the table
+  // which records them so that the next test starts from scratch, and close
+  // the database connection pool.
   t.Cleanup(func() {
     defer db.Close()
 
-    script, err := os.ReadFile("./testdata/teardown.sql")
-    if err != nil {
-      t.Fatal(err)
+    for range migrator.Migrations {
+      _, err := migrator.Down(context.Background())
+      if err != nil {
+        t.Fatal(err)
+      }
     }
-    _, err = db.Exec(string(script))
+
+    _, err := db.Exec("DROP TABLE schema_migrations")
     if err != nil {
       t.Fatal(err)
     }
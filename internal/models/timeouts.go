@@ -0,0 +1,216 @@
+package models
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "time"
+)
+
+// Define a TimedSnippetModel type which wraps the snippet model for any
+// database, and gives each query a deadline of Timeout. The deadline is added
+// to the context passed in by the caller, so a query is also cancelled if
+// that context is (for example, because the client has gone away). If the
+// deadline passes before the query has finished, ErrTimeout is returned. A
+// zero Timeout means no deadline.
+type TimedSnippetModel struct {
+  Model   SnippetModelInterface
+  Timeout time.Duration
+}
+
+// Define TimedUserModel, TimedTokenModel and TimedRevisionModel types which
+// do the same for the other models.
+type TimedUserModel struct {
+  Model   UserModelInterface
+  Timeout time.Duration
+}
+
+type TimedTokenModel struct {
+  Model   TokenModelInterface
+  Timeout time.Duration
+}
+
+type TimedRevisionModel struct {
+  Model   RevisionModelInterface
+  Timeout time.Duration
+}
+
+// withTimeout() returns a copy of ctx with the given timeout, unless it's
+// zero.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+  if timeout <= 0 {
+    return context.WithCancel(ctx)
+  }
+  return context.WithTimeout(ctx, timeout)
+}
+
+// timeoutError() wraps err in ErrTimeout if the query failed because ctx's
+// deadline passed. The drivers report this in different ways, so we check the
+// context rather than the error. The driver's error is kept, so that it still
+// shows up in the logs.
+func timeoutError(ctx context.Context, err error) error {
+  if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+    return fmt.Errorf("%w: %w", ErrTimeout, err)
+  }
+  return err
+}
+
+func (m *TimedSnippetModel) Insert(ctx context.Context, snippet Snippet) (string, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  slug, err := m.Model.Insert(ctx, snippet)
+  return slug, timeoutError(ctx, err)
+}
+
+func (m *TimedSnippetModel) Get(ctx context.Context, slug string, viewerID int) (Snippet, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  s, err := m.Model.Get(ctx, slug, viewerID)
+  return s, timeoutError(ctx, err)
+}
+
+func (m *TimedSnippetModel) View(ctx context.Context, slug string, viewerID int) (Snippet, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  s, err := m.Model.View(ctx, slug, viewerID)
+  return s, timeoutError(ctx, err)
+}
+
+func (m *TimedSnippetModel) LegacySlug(ctx context.Context, id int, viewerID int) (string, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  slug, err := m.Model.LegacySlug(ctx, id, viewerID)
+  return slug, timeoutError(ctx, err)
+}
+
+func (m *TimedSnippetModel) Latest(ctx context.Context, viewerID int) ([]Snippet, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  snippets, err := m.Model.Latest(ctx, viewerID)
+  return snippets, timeoutError(ctx, err)
+}
+
+func (m *TimedSnippetModel) Search(ctx context.Context, query string, page int, viewerID int) ([]Snippet, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  snippets, err := m.Model.Search(ctx, query, page, viewerID)
+  return snippets, timeoutError(ctx, err)
+}
+
+func (m *TimedSnippetModel) List(ctx context.Context, filter SnippetFilter) (SnippetPage, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  page, err := m.Model.List(ctx, filter)
+  return page, timeoutError(ctx, err)
+}
+
+func (m *TimedSnippetModel) Tags(ctx context.Context, limit int) ([]Tag, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  tags, err := m.Model.Tags(ctx, limit)
+  return tags, timeoutError(ctx, err)
+}
+
+func (m *TimedSnippetModel) Update(ctx context.Context, snippet Snippet, editorID int) error {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  return timeoutError(ctx, m.Model.Update(ctx, snippet, editorID))
+}
+
+func (m *TimedSnippetModel) Delete(ctx context.Context, id int) error {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  return timeoutError(ctx, m.Model.Delete(ctx, id))
+}
+
+// PurgeExpired() is passed straight through without a deadline. It's called
+// by the background reaper rather than while handling a request, and it
+// already limits how much work each call does.
+func (m *TimedSnippetModel) PurgeExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+  return m.Model.PurgeExpired(ctx, before, limit)
+}
+
+// PurgeBurned() is passed straight through too, for the same reason.
+func (m *TimedSnippetModel) PurgeBurned(ctx context.Context, before time.Time) (int, error) {
+  return m.Model.PurgeBurned(ctx, before)
+}
+
+func (m *TimedUserModel) Insert(ctx context.Context, name, email, password string) error {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  return timeoutError(ctx, m.Model.Insert(ctx, name, email, password))
+}
+
+func (m *TimedUserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  id, err := m.Model.Authenticate(ctx, email, password)
+  return id, timeoutError(ctx, err)
+}
+
+func (m *TimedUserModel) Exists(ctx context.Context, id int) (bool, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  exists, err := m.Model.Exists(ctx, id)
+  return exists, timeoutError(ctx, err)
+}
+
+func (m *TimedTokenModel) Insert(ctx context.Context, userID int, name, scope string, expires time.Time) (string, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  token, err := m.Model.Insert(ctx, userID, name, scope, expires)
+  return token, timeoutError(ctx, err)
+}
+
+func (m *TimedTokenModel) Authenticate(ctx context.Context, token string) (Token, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  t, err := m.Model.Authenticate(ctx, token)
+  return t, timeoutError(ctx, err)
+}
+
+func (m *TimedTokenModel) ForUser(ctx context.Context, userID int) ([]Token, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  tokens, err := m.Model.ForUser(ctx, userID)
+  return tokens, timeoutError(ctx, err)
+}
+
+func (m *TimedTokenModel) Revoke(ctx context.Context, id, userID int) error {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  return timeoutError(ctx, m.Model.Revoke(ctx, id, userID))
+}
+
+func (m *TimedRevisionModel) All(ctx context.Context, snippetID int) ([]Revision, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  revisions, err := m.Model.All(ctx, snippetID)
+  return revisions, timeoutError(ctx, err)
+}
+
+func (m *TimedRevisionModel) Get(ctx context.Context, snippetID int, version int) (Revision, error) {
+  ctx, cancel := withTimeout(ctx, m.Timeout)
+  defer cancel()
+
+  r, err := m.Model.Get(ctx, snippetID, version)
+  return r, timeoutError(ctx, err)
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+  "context"
+  "errors"
+  "testing"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+)
+
+// slowSnippetModel's Get() method waits until its context is done, like a
+// query which is taking too long. Its other methods aren't used.
+type slowSnippetModel struct {
+  SnippetModelInterface
+}
+
+func (m *slowSnippetModel) Get(ctx context.Context, slug string, viewerID int) (Snippet, error) {
+  <-ctx.Done()
+  return Snippet{}, ctx.Err()
+}
+
+func TestTimedSnippetModel(t *testing.T) {
+  m := &TimedSnippetModel{Model: &slowSnippetModel{}, Timeout: time.Millisecond}
+
+  // A query which runs past its deadline returns ErrTimeout, wrapped around
+  // the error from the query...
+  _, err := m.Get(t.Context(), "s1lentPd", 0)
+  assert.Equal(t, errors.Is(err, ErrTimeout), true)
+  assert.Equal(t, errors.Is(err, context.DeadlineExceeded), true)
+
+  // ...but one which is cancelled by the caller doesn't.
+  ctx, cancel := context.WithCancel(t.Context())
+  cancel()
+
+  _, err = m.Get(ctx, "s1lentPd", 0)
+  if !errors.Is(err, context.Canceled) {
+    t.Errorf("got %v; want %v", err, context.Canceled)
+  }
+}
+
+// slowTokenModel's Authenticate() method waits until its context is done.
+type slowTokenModel struct {
+  TokenModelInterface
+}
+
+func (m *slowTokenModel) Authenticate(ctx context.Context, token string) (Token, error) {
+  <-ctx.Done()
+  return Token{}, ctx.Err()
+}
+
+func TestTimedTokenModel(t *testing.T) {
+  m := &TimedTokenModel{Model: &slowTokenModel{}, Timeout: time.Millisecond}
+
+  // Tokens are checked on every API request, so they get a deadline too.
+  _, err := m.Authenticate(t.Context(), "alice-token")
+  assert.Equal(t, errors.Is(err, ErrTimeout), true)
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+  "context"
+  "crypto/rand"
+  "crypto/sha256"
+  "database/sql"
+  "errors"
+  "time"
+)
+
+// Define constants for the scopes an API token can have. A read token can only
+// be used to fetch snippets; a write token can also create, update and delete
+// them.
+const (
+  ScopeRead  = "read"
+  ScopeWrite = "write"
+)
+
+// Scopes holds every valid token scope, for use in validation.
+var Scopes = []string{ScopeRead, ScopeWrite}
+
+type TokenModelInterface interface {
+  Insert(ctx context.Context, userID int, name, scope string, expires time.Time) (string, error)
+  Authenticate(ctx context.Context, token string) (Token, error)
+  ForUser(ctx context.Context, userID int) ([]Token, error)
+  Revoke(ctx context.Context, id, userID int) error
+}
+
+// Define a Token type to hold the details of a personal API token. We never
+// store the plaintext token itself, so it isn't part of this struct; it's
+// only shown to the user once, when the token is created. Expires is the
+// zero time if the token never expires, and LastUsed is the zero time if it
+// has never been used.
+type Token struct {
+  ID       int
+  UserID   int
+  Name     string
+  Scope    string
+  Created  time.Time
+  Expires  time.Time
+  LastUsed time.Time
+}
+
+// The CanWrite() method reports whether the token can be used to change
+// snippets, rather than just read them.
+func (t Token) CanWrite() bool {
+  return t.Scope == ScopeWrite
+}
+
+// NeverExpires() reports whether the token stays valid until it's revoked.
+func (t Token) NeverExpires() bool {
+  return t.Expires.IsZero()
+}
+
+// Define a TokenModel type which wraps a database connection pool. Tokens let
+// scripts and other programs use the JSON API on behalf of a user, without
+// needing a session cookie.
+type TokenModel struct {
+  DB      *sql.DB
+  Dialect string
+}
+
+// LastUsedResolution is how often we record that a token has been used. A
+// script which makes lots of requests would otherwise cause a database write
+// for every one of them, and we only need a rough idea of when each token was
+// last used to tell which ones are stale.
+const LastUsedResolution = time.Minute
+
+// HashToken() returns the SHA-256 hash of a plaintext token. We only ever
+// store the hash, so that the tokens can't be used by somebody who gets hold
+// of a copy of the database. Unlike passwords, tokens are long random strings,
+// so a fast hash is fine here.
+func HashToken(token string) []byte {
+  hash := sha256.Sum256([]byte(token))
+  return hash[:]
+}
+
+// We'll use the Insert method to create a new token for a user. It returns
+// the plaintext token, which is the only time it's available.
+func (m *TokenModel) Insert(ctx context.Context, userID int, name, scope string, expires time.Time) (string, error) {
+  // rand.Text() returns 26 random base32 characters, which is 130 bits of
+  // randomness -- plenty to make the token impossible to guess.
+  token := rand.Text()
+
+  stmt := `INSERT INTO tokens (user_id, name, scope, hash, created, expires)
+  VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+  _, err := m.DB.ExecContext(ctx, dialectFor(m.Dialect).rebind(stmt), userID, name, scope,
+    HashToken(token), nullTime(expires))
+  if err != nil {
+    return "", err
+  }
+
+  return token, nil
+}
+
+// We'll use the Authenticate method to look up a plaintext token, and record
+// that it has been used. If there is no matching token, or it has expired, we
+// return the ErrInvalidCredentials error.
+func (m *TokenModel) Authenticate(ctx context.Context, token string) (Token, error) {
+  d := dialectFor(m.Dialect)
+
+  stmt := `SELECT id, user_id, name, scope, created, expires, last_used
+  FROM tokens
+  WHERE hash = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())`
+
+  t, err := scanToken(m.DB.QueryRowContext(ctx, d.rebind(stmt), HashToken(token)))
+  if err != nil {
+    if errors.Is(err, sql.ErrNoRows) {
+      return Token{}, ErrInvalidCredentials
+    }
+    return Token{}, err
+  }
+
+  // Only write the new last used time if the old one is out of date.
+  stmt = `UPDATE tokens SET last_used = UTC_TIMESTAMP()
+  WHERE id = ? AND (last_used IS NULL OR last_used < ?)`
+
+  cutoff := time.Now().UTC().Add(-LastUsedResolution)
+
+  _, err = m.DB.ExecContext(ctx, d.rebind(stmt), t.ID, cutoff)
+  if err != nil {
+    return Token{}, err
+  }
+
+  return t, nil
+}
+
+// The ForUser method returns all of a user's tokens, newest first, including
+// any which have expired (so that the user can see them and tidy up).
+func (m *TokenModel) ForUser(ctx context.Context, userID int) ([]Token, error) {
+  stmt := `SELECT id, user_id, name, scope, created, expires, last_used
+  FROM tokens
+  WHERE user_id = ?
+  ORDER BY id DESC`
+
+  rows, err := m.DB.QueryContext(ctx, dialectFor(m.Dialect).rebind(stmt), userID)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  var tokens []Token
+
+  for rows.Next() {
+    t, err := scanToken(rows)
+    if err != nil {
+      return nil, err
+    }
+    tokens = append(tokens, t)
+  }
+
+  if err = rows.Err(); err != nil {
+    return nil, err
+  }
+
+  return tokens, nil
+}
+
+// The Revoke method deletes one of a user's tokens, so that it can't be used
+// any more. If the user doesn't have a token with that ID it returns
+// ErrNoRecord.
+func (m *TokenModel) Revoke(ctx context.Context, id, userID int) error {
+  stmt := "DELETE FROM tokens WHERE id = ? AND user_id = ?"
+
+  result, err := m.DB.ExecContext(ctx, dialectFor(m.Dialect).rebind(stmt), id, userID)
+  if err != nil {
+    return err
+  }
+
+  n, err := result.RowsAffected()
+  if err != nil {
+    return err
+  }
+  if n == 0 {
+    return ErrNoRecord
+  }
+
+  return nil
+}
+
+// scanToken() scans a row from the tokens table, in the column order used by
+// the queries above.
+func scanToken(row scanner) (Token, error) {
+  var (
+    t        Token
+    expires  sql.NullTime
+    lastUsed sql.NullTime
+  )
+
+  err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &expires, &lastUsed)
+  t.Expires = expires.Time
+  t.LastUsed = lastUsed.Time
+  return t, err
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+  "testing"
+  "time"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+)
+
+// The shared tests in modeltest cover how tokens behave. This checks how
+// they're stored.
+func TestTokenModelHashed(t *testing.T) {
+  if testing.Short() {
+    t.Skip("models: skipping integration test")
+  }
+
+  m := TokenModel{DB: newTestDB(t)}
+
+  token, err := m.Insert(t.Context(), 1, "laptop", ScopeWrite, time.Time{})
+  assert.NilError(t, err)
+
+  // The plaintext token isn't stored anywhere, only its hash.
+  var count int
+  err = m.DB.QueryRow("SELECT COUNT(*) FROM tokens WHERE hash = ?", token).Scan(&count)
+  assert.NilError(t, err)
+  assert.Equal(t, count, 0)
+
+  err = m.DB.QueryRow("SELECT COUNT(*) FROM tokens WHERE hash = ?", HashToken(token)).Scan(&count)
+  assert.NilError(t, err)
+  assert.Equal(t, count, 1)
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+  "context"
+  "errors"
+  "time"
+
+  "go.opentelemetry.io/otel"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans created by this package.
+const tracerName = "github.com/kjloveless/snippetbox/internal/models"
+
+// Define a TracedSnippetModel type which wraps the snippet model for any
+// database, and records an OpenTelemetry span for each call, so that slow
+// queries show up in a request's trace. The spans are children of whatever
+// span is in the context passed in by the caller. If the model is also being
+// given a deadline, wrap the TimedSnippetModel with this one, so that the
+// spans include the deadline passing.
+type TracedSnippetModel struct {
+  Model SnippetModelInterface
+}
+
+// Define TracedUserModel, TracedTokenModel and TracedRevisionModel types which
+// do the same for the other models.
+type TracedUserModel struct {
+  Model UserModelInterface
+}
+
+type TracedTokenModel struct {
+  Model TokenModelInterface
+}
+
+type TracedRevisionModel struct {
+  Model RevisionModelInterface
+}
+
+// startSpan() starts a span for the named model method. We get the tracer from
+// the global provider every time, rather than once up front, so that it's
+// always the one which is currently configured.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+  return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// endSpan() records err on the span and ends it. Errors like ErrNoRecord are
+// normal answers to a query rather than failures, so those don't mark the
+// span as failed.
+func endSpan(span trace.Span, err error) {
+  if err != nil && !errors.Is(err, ErrNoRecord) && !errors.Is(err, ErrInvalidCredentials) &&
+    !errors.Is(err, ErrDuplicateEmail) && !errors.Is(err, ErrBurned) && !errors.Is(err, ErrInvalidCursor) {
+    span.RecordError(err)
+    span.SetStatus(codes.Error, err.Error())
+  }
+  span.End()
+}
+
+func (m *TracedSnippetModel) Insert(ctx context.Context, snippet Snippet) (string, error) {
+  ctx, span := startSpan(ctx, "SnippetModel.Insert")
+  slug, err := m.Model.Insert(ctx, snippet)
+  endSpan(span, err)
+  return slug, err
+}
+
+func (m *TracedSnippetModel) Get(ctx context.Context, slug string, viewerID int) (Snippet, error) {
+  ctx, span := startSpan(ctx, "SnippetModel.Get")
+  s, err := m.Model.Get(ctx, slug, viewerID)
+  endSpan(span, err)
+  return s, err
+}
+
+func (m *TracedSnippetModel) View(ctx context.Context, slug string, viewerID int) (Snippet, error) {
+  ctx, span := startSpan(ctx, "SnippetModel.View")
+  s, err := m.Model.View(ctx, slug, viewerID)
+  endSpan(span, err)
+  return s, err
+}
+
+func (m *TracedSnippetModel) LegacySlug(ctx context.Context, id int, viewerID int) (string, error) {
+  ctx, span := startSpan(ctx, "SnippetModel.LegacySlug")
+  slug, err := m.Model.LegacySlug(ctx, id, viewerID)
+  endSpan(span, err)
+  return slug, err
+}
+
+func (m *TracedSnippetModel) Latest(ctx context.Context, viewerID int) ([]Snippet, error) {
+  ctx, span := startSpan(ctx, "SnippetModel.Latest")
+  snippets, err := m.Model.Latest(ctx, viewerID)
+  endSpan(span, err)
+  return snippets, err
+}
+
+func (m *TracedSnippetModel) Search(ctx context.Context, query string, page int, viewerID int) ([]Snippet, error) {
+  ctx, span := startSpan(ctx, "SnippetModel.Search")
+  snippets, err := m.Model.Search(ctx, query, page, viewerID)
+  endSpan(span, err)
+  return snippets, err
+}
+
+func (m *TracedSnippetModel) List(ctx context.Context, filter SnippetFilter) (SnippetPage, error) {
+  ctx, span := startSpan(ctx, "SnippetModel.List")
+  page, err := m.Model.List(ctx, filter)
+  endSpan(span, err)
+  return page, err
+}
+
+func (m *TracedSnippetModel) Tags(ctx context.Context, limit int) ([]Tag, error) {
+  ctx, span := startSpan(ctx, "SnippetModel.Tags")
+  tags, err := m.Model.Tags(ctx, limit)
+  endSpan(span, err)
+  return tags, err
+}
+
+func (m *TracedSnippetModel) Update(ctx context.Context, snippet Snippet, editorID int) error {
+  ctx, span := startSpan(ctx, "SnippetModel.Update")
+  err := m.Model.Update(ctx, snippet, editorID)
+  endSpan(span, err)
+  return err
+}
+
+func (m *TracedSnippetModel) Delete(ctx context.Context, id int) error {
+  ctx, span := startSpan(ctx, "SnippetModel.Delete")
+  err := m.Model.Delete(ctx, id)
+  endSpan(span, err)
+  return err
+}
+
+func (m *TracedSnippetModel) PurgeExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+  ctx, span := startSpan(ctx, "SnippetModel.PurgeExpired")
+  n, err := m.Model.PurgeExpired(ctx, before, limit)
+  endSpan(span, err)
+  return n, err
+}
+
+func (m *TracedSnippetModel) PurgeBurned(ctx context.Context, before time.Time) (int, error) {
+  ctx, span := startSpan(ctx, "SnippetModel.PurgeBurned")
+  n, err := m.Model.PurgeBurned(ctx, before)
+  endSpan(span, err)
+  return n, err
+}
+
+func (m *TracedUserModel) Insert(ctx context.Context, name, email, password string) error {
+  ctx, span := startSpan(ctx, "UserModel.Insert")
+  err := m.Model.Insert(ctx, name, email, password)
+  endSpan(span, err)
+  return err
+}
+
+func (m *TracedUserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+  ctx, span := startSpan(ctx, "UserModel.Authenticate")
+  id, err := m.Model.Authenticate(ctx, email, password)
+  endSpan(span, err)
+  return id, err
+}
+
+func (m *TracedUserModel) Exists(ctx context.Context, id int) (bool, error) {
+  ctx, span := startSpan(ctx, "UserModel.Exists")
+  exists, err := m.Model.Exists(ctx, id)
+  endSpan(span, err)
+  return exists, err
+}
+
+func (m *TracedTokenModel) Insert(ctx context.Context, userID int, name, scope string, expires time.Time) (string, error) {
+  ctx, span := startSpan(ctx, "TokenModel.Insert")
+  token, err := m.Model.Insert(ctx, userID, name, scope, expires)
+  endSpan(span, err)
+  return token, err
+}
+
+func (m *TracedTokenModel) Authenticate(ctx context.Context, token string) (Token, error) {
+  ctx, span := startSpan(ctx, "TokenModel.Authenticate")
+  t, err := m.Model.Authenticate(ctx, token)
+  endSpan(span, err)
+  return t, err
+}
+
+func (m *TracedTokenModel) ForUser(ctx context.Context, userID int) ([]Token, error) {
+  ctx, span := startSpan(ctx, "TokenModel.ForUser")
+  tokens, err := m.Model.ForUser(ctx, userID)
+  endSpan(span, err)
+  return tokens, err
+}
+
+func (m *TracedTokenModel) Revoke(ctx context.Context, id, userID int) error {
+  ctx, span := startSpan(ctx, "TokenModel.Revoke")
+  err := m.Model.Revoke(ctx, id, userID)
+  endSpan(span, err)
+  return err
+}
+
+func (m *TracedRevisionModel) All(ctx context.Context, snippetID int) ([]Revision, error) {
+  ctx, span := startSpan(ctx, "RevisionModel.All")
+  revisions, err := m.Model.All(ctx, snippetID)
+  endSpan(span, err)
+  return revisions, err
+}
+
+func (m *TracedRevisionModel) Get(ctx context.Context, snippetID int, version int) (Revision, error) {
+  ctx, span := startSpan(ctx, "RevisionModel.Get")
+  r, err := m.Model.Get(ctx, snippetID, version)
+  endSpan(span, err)
+  return r, err
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+  "context"
+  "testing"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+
+  "go.opentelemetry.io/otel"
+  "go.opentelemetry.io/otel/codes"
+  sdktrace "go.opentelemetry.io/otel/sdk/trace"
+  "go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// stubSnippetModel's Get() method returns ErrNoRecord for the slug "m1ss1ng0"
+// and ErrTimeout for anything else. Its other methods aren't used.
+type stubSnippetModel struct {
+  SnippetModelInterface
+}
+
+func (m *stubSnippetModel) Get(ctx context.Context, slug string, viewerID int) (Snippet, error) {
+  if slug == "m1ss1ng0" {
+    return Snippet{}, ErrNoRecord
+  }
+  return Snippet{}, ErrTimeout
+}
+
+func TestTracedSnippetModel(t *testing.T) {
+  recorder := tracetest.NewSpanRecorder()
+  provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+  previous := otel.GetTracerProvider()
+  otel.SetTracerProvider(provider)
+  t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+  ctx, parent := provider.Tracer("test").Start(t.Context(), "parent")
+
+  m := &TracedSnippetModel{Model: &stubSnippetModel{}}
+  m.Get(ctx, "m1ss1ng0", 0)
+  m.Get(ctx, "s1lentPd", 0)
+  parent.End()
+
+  spans := recorder.Ended()
+  assert.Equal(t, len(spans), 3)
+
+  // Each call gets a span which is a child of the caller's span...
+  assert.Equal(t, spans[0].Name(), "SnippetModel.Get")
+  assert.Equal(t, spans[0].Parent().SpanID(), parent.SpanContext().SpanID())
+
+  // ...and only a real failure marks the span as an error.
+  assert.Equal(t, spans[0].Status().Code, codes.Unset)
+  assert.Equal(t, spans[1].Status().Code, codes.Error)
+  assert.Equal(t, spans[1].Status().Description, ErrTimeout.Error())
+}
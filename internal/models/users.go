@@ -1,40 +1,61 @@
 package models
 
 import (
+  "context"
   "database/sql"
   "errors"
   "strings"
   "time"
 
-  "github.com/go-sql-driver/mysql"
   "golang.org/x/crypto/bcrypt"
 )
 
 type UserModelInterface interface {
-  Insert(name, email, password string) error
-  Authenticate(email, password string) (int, error)
-  Exists(id int) (bool, error)
+  Insert(ctx context.Context, name, email, password string) error
+  Authenticate(ctx context.Context, email, password string) (int, error)
+  Exists(ctx context.Context, id int) (bool, error)
 }
 
 // Define a new User struct. Notice how the field names and types align with
-// the columns in the database "users" table?
+// the columns in the database "users" table? Disabled users can't log in, and
+// any sessions or API tokens they already have stop working.
 type User struct {
   ID              int
   Name            string
   Email           string
   HashedPassword  []byte
   Created         time.Time
+  Disabled        bool
 }
 
 // Define a new UserModel struct which wraps a database connection pool.
+// BcryptCost sets how much work goes into hashing passwords; when it's zero,
+// DefaultBcryptCost is used.
 type UserModel struct {
-  DB  *sql.DB
+  DB          *sql.DB
+  Dialect     string
+  BcryptCost  int
+}
+
+// DefaultBcryptCost is the bcrypt cost used for new password hashes when none
+// is configured. Each increase of one doubles the time it takes to hash (and
+// to check) a password.
+const DefaultBcryptCost = 12
+
+// HashPassword creates a bcrypt hash of a plain-text password. A cost of zero
+// means DefaultBcryptCost.
+func HashPassword(password string, cost int) ([]byte, error) {
+  if cost == 0 {
+    cost = DefaultBcryptCost
+  }
+
+  return bcrypt.GenerateFromPassword([]byte(password), cost)
 }
 
 // We'll use the Insert method to add a new record to the "users" table.
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
   // Create a bcrypt hash of the plain-text password.
-  hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+  hashedPassword, err := HashPassword(password, m.BcryptCost)
   if err != nil {
     return err
   }
@@ -42,23 +63,16 @@ func (m *UserModel) Insert(name, email, password string) error {
   stmt := `INSERT INTO users (name, email, hashed_password, created)
   VALUES(?, ?, ?, UTC_TIMESTAMP())`
 
-  // Use the Exec() method to insert the user details and hashed password into
-  // the users table.
-  _, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+  // Use the ExecContext() method to insert the user details and hashed
+  // password into the users table.
+  _, err = m.DB.ExecContext(ctx, dialectFor(m.Dialect).rebind(stmt), name, email,
+    string(hashedPassword))
   if err != nil {
-    // If this returns an error, we use the errors.As() function to check 
-    // whether the error has the type *mysql.MySQLError. If it does, the
-    // error will be assigned to the mySQLError variable. We can then check
-    // whether or not the error relates to our users_uc_email key by checking
-    // if the error code equals 1062 and the contents of the error message
-    // string. If it does, we return an ErrDuplicateEmail error.
-    var mySQLError *mysql.MySQLError
-    if errors.As(err, &mySQLError) {
-      if mySQLError.Number == 1062 && strings.Contains(
-        mySQLError.Message, 
-        "users_uc_email") {
-        return ErrDuplicateEmail
-      }
+    // If this returns an error, we use the isUniqueViolation() function to
+    // check whether the error relates to our users_uc_email key. If it does,
+    // we return an ErrDuplicateEmail error.
+    if isUniqueViolation(err, "users_uc_email", "users.email") {
+      return ErrDuplicateEmail
     }
 
     return err
@@ -70,15 +84,15 @@ func (m *UserModel) Insert(name, email, password string) error {
 // We'll use the Authenticate method to verify whether a user exists with the
 // provided email address and password. This will return the relevant user ID
 // if they do.
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
   // Retrieve the id and hashed password associated with the given email. If no
   // matching email exists we return the ErrInvalidCredentials error.
   var id int
   var hashedPassword []byte
 
-  stmt := "SELECT id, hashed_password FROM users where email = ?"
+  stmt := "SELECT id, hashed_password FROM users where email = ? AND NOT disabled"
 
-  err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+  err := m.DB.QueryRowContext(ctx, dialectFor(m.Dialect).rebind(stmt), email).Scan(&id, &hashedPassword)
   if err != nil {
     if errors.Is(err, sql.ErrNoRows) {
       return 0, ErrInvalidCredentials
@@ -103,11 +117,171 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 }
 
 // We'll use the Exists method to check if a user exists with a specific ID.
-func (m *UserModel) Exists(id int) (bool, error) {
+// Disabled users are treated as if they don't exist.
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
   var exists bool
 
-  stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+  stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ? AND NOT disabled)"
 
-  err := m.DB.QueryRow(stmt, id).Scan(&exists)
+  err := m.DB.QueryRowContext(ctx, dialectFor(m.Dialect).rebind(stmt), id).Scan(&exists)
   return exists, err
 }
+
+// The methods below are used by the snippetadmin command, rather than the web
+// application, so they're part of AdminUserModelInterface instead of
+// UserModelInterface.
+
+// The All method returns every user, in the order they signed up.
+func (m *UserModel) All(ctx context.Context) ([]User, error) {
+  stmt := "SELECT id, name, email, created, disabled FROM users ORDER BY id"
+
+  rows, err := m.DB.QueryContext(ctx, stmt)
+  if err != nil {
+    return nil, err
+  }
+  defer rows.Close()
+
+  var users []User
+
+  for rows.Next() {
+    var u User
+    err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Disabled)
+    if err != nil {
+      return nil, err
+    }
+    users = append(users, u)
+  }
+
+  if err = rows.Err(); err != nil {
+    return nil, err
+  }
+
+  return users, nil
+}
+
+// The SetDisabled method disables or re-enables a user. It returns
+// ErrNoRecord if there is no user with the given ID.
+func (m *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+  stmt := "UPDATE users SET disabled = ? WHERE id = ?"
+
+  return m.update(ctx, stmt, id, disabled, id)
+}
+
+// The SetPassword method replaces a user's password, hashing it in the same
+// way as Insert. It returns ErrNoRecord if there is no user with the given
+// ID.
+func (m *UserModel) SetPassword(ctx context.Context, id int, password string) error {
+  hashedPassword, err := HashPassword(password, m.BcryptCost)
+  if err != nil {
+    return err
+  }
+
+  stmt := "UPDATE users SET hashed_password = ? WHERE id = ?"
+
+  return m.update(ctx, stmt, id, string(hashedPassword), id)
+}
+
+// update() runs an UPDATE statement for the user with the given ID, and
+// returns ErrNoRecord if the user doesn't exist. We can't rely on the number
+// of affected rows for that, because MySQL doesn't count rows which already
+// had the new values.
+func (m *UserModel) update(ctx context.Context, stmt string, id int, args ...any) error {
+  d := dialectFor(m.Dialect)
+
+  var exists bool
+
+  err := m.DB.QueryRowContext(ctx, d.rebind("SELECT EXISTS(SELECT true FROM users WHERE id = ?)"),
+    id).Scan(&exists)
+  if err != nil {
+    return err
+  }
+  if !exists {
+    return ErrNoRecord
+  }
+
+  _, err = m.DB.ExecContext(ctx, d.rebind(stmt), args...)
+  return err
+}
+
+// AnonymousEmail is the email address of the placeholder user which owns the
+// snippets created before snippets had authors (see migration 0003), and
+// the revisions made by users who have since been deleted. The placeholder is
+// disabled, so nobody can log in as them.
+const AnonymousEmail = "anonymous@snippetbox.invalid"
+
+// The Delete method permanently deletes a user, along with all of their
+// snippets. Their API tokens are deleted by the database, because of the ON
+// DELETE CASCADE on the foreign key. It returns ErrNoRecord if there is no
+// user with the given ID.
+func (m *UserModel) Delete(ctx context.Context, id int) error {
+  d := dialectFor(m.Dialect)
+
+  tx, err := m.DB.BeginTx(ctx, nil)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback()
+
+  // Revisions are deleted along with their snippets, but the user may also
+  // have edited other people's snippets. Those revisions are part of the
+  // other snippets' history, so rather than deleting them we hand them over
+  // to the Anonymous user.
+  var edited bool
+
+  err = tx.QueryRowContext(ctx, d.rebind("SELECT EXISTS(SELECT true FROM snippet_revisions WHERE user_id = ?)"),
+    id).Scan(&edited)
+  if err != nil {
+    return err
+  }
+
+  if edited {
+    anonymousID, err := anonymousUserID(ctx, d, tx)
+    if err != nil {
+      return err
+    }
+
+    _, err = tx.ExecContext(ctx, d.rebind("UPDATE snippet_revisions SET user_id = ? WHERE user_id = ?"),
+      anonymousID, id)
+    if err != nil {
+      return err
+    }
+  }
+
+  _, err = tx.ExecContext(ctx, d.rebind("DELETE FROM snippets WHERE user_id = ?"), id)
+  if err != nil {
+    return err
+  }
+
+  result, err := tx.ExecContext(ctx, d.rebind("DELETE FROM users WHERE id = ?"), id)
+  if err != nil {
+    return err
+  }
+
+  n, err := result.RowsAffected()
+  if err != nil {
+    return err
+  }
+  if n == 0 {
+    return ErrNoRecord
+  }
+
+  return tx.Commit()
+}
+
+// anonymousUserID() returns the ID of the Anonymous user. Migration 0003 only
+// adds them if there were already snippets for them to own, so if they don't
+// exist yet they're added now. Their password hash isn't a valid bcrypt hash,
+// so no password can ever match it.
+func anonymousUserID(ctx context.Context, d dialect, tx *sql.Tx) (int, error) {
+  var id int
+
+  err := tx.QueryRowContext(ctx, d.rebind("SELECT id FROM users WHERE email = ?"), AnonymousEmail).Scan(&id)
+  if !errors.Is(err, sql.ErrNoRows) {
+    return id, err
+  }
+
+  stmt := `INSERT INTO users (name, email, hashed_password, created, disabled)
+  VALUES ('Anonymous', ?, ?, UTC_TIMESTAMP(), true)`
+
+  return d.insertID(ctx, tx, stmt, AnonymousEmail, strings.Repeat("!", 60))
+}
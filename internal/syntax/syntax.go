@@ -0,0 +1,142 @@
+// Package syntax renders snippet content as syntax highlighted HTML on the
+// server. The HTML uses CSS classes rather than inline styles, so that it
+// works with a strict Content-Security-Policy; the matching stylesheet lives
+// in ui/static/css/highlight.css.
+package syntax
+
+import (
+  "bytes"
+  "html/template"
+
+  "github.com/alecthomas/chroma/v2"
+  "github.com/alecthomas/chroma/v2/formatters/html"
+  "github.com/alecthomas/chroma/v2/lexers"
+  "github.com/alecthomas/chroma/v2/styles"
+)
+
+// Define a Language type to hold a language that snippets can be written in.
+// Name is the value we store in the database (and is also the name of the
+// chroma lexer), and Label is the human-readable name.
+type Language struct {
+  Name  string
+  Label string
+}
+
+// Languages is the list of languages which can be chosen for a snippet. An
+// empty language name means the language should be detected automatically.
+var Languages = []Language{
+  {"bash", "Bash"},
+  {"c", "C"},
+  {"cpp", "C++"},
+  {"css", "CSS"},
+  {"docker", "Dockerfile"},
+  {"go", "Go"},
+  {"html", "HTML"},
+  {"java", "Java"},
+  {"javascript", "JavaScript"},
+  {"json", "JSON"},
+  {"markdown", "Markdown"},
+  {"python", "Python"},
+  {"ruby", "Ruby"},
+  {"rust", "Rust"},
+  {"sql", "SQL"},
+  {"toml", "TOML"},
+  {"typescript", "TypeScript"},
+  {"yaml", "YAML"},
+  {"plaintext", "Plain text"},
+}
+
+// Names() returns the names of all the permitted languages, including the
+// empty string for automatic detection.
+func Names() []string {
+  names := []string{""}
+  for _, l := range Languages {
+    names = append(names, l.Name)
+  }
+  return names
+}
+
+// Label() returns the human-readable name for a language, or the name itself
+// if it isn't in the list.
+func Label(name string) string {
+  for _, l := range Languages {
+    if l.Name == name {
+      return l.Label
+    }
+  }
+  return name
+}
+
+// LinePrefix is the prefix for the id of each line in the rendered HTML, so
+// that line 5 can be linked to with "#L5".
+const LinePrefix = "L"
+
+// formatter renders the highlighted code with CSS classes and a line number on
+// each line. Each line number is a link to that line.
+var formatter = html.New(
+  html.WithClasses(true),
+  html.WithLineNumbers(true),
+  html.WithLinkableLineNumbers(true, LinePrefix),
+)
+
+// style is used to generate the stylesheet. The generated class names don't
+// depend on it, so it doesn't affect Render().
+var style = styles.Get("github")
+
+// Render() returns the content as syntax highlighted HTML. If language is
+// empty, the language is detected from the content. The name of the language
+// which was actually used is also returned.
+func Render(content, language string) (template.HTML, string, error) {
+  if language == "" {
+    language = Detect(content)
+  }
+
+  lexer := lexers.Get(language)
+  if lexer == nil {
+    lexer = lexers.Fallback
+  }
+
+  // Coalesce runs of identical token types, to keep the HTML smaller.
+  lexer = chroma.Coalesce(lexer)
+
+  iterator, err := lexer.Tokenise(nil, content)
+  if err != nil {
+    return "", "", err
+  }
+
+  var buf bytes.Buffer
+  err = formatter.Format(&buf, style, iterator)
+  if err != nil {
+    return "", "", err
+  }
+
+  return template.HTML(buf.String()), language, nil
+}
+
+// Detect() guesses which of our permitted languages the content is written
+// in, returning "plaintext" if it can't tell. We only consider the languages
+// in our list (rather than every language chroma knows about), so that the
+// result is always something that could have been chosen in the form.
+func Detect(content string) string {
+  best, bestScore := "plaintext", float32(0)
+
+  for _, l := range Languages {
+    analyser, ok := lexers.Get(l.Name).(chroma.Analyser)
+    if !ok {
+      continue
+    }
+
+    if score := analyser.AnalyseText(content); score > bestScore {
+      best, bestScore = l.Name, score
+    }
+  }
+
+  return best
+}
+
+// CSS() returns the stylesheet for the highlighted HTML.
+func CSS() (string, error) {
+  var buf bytes.Buffer
+  err := formatter.WriteCSS(&buf, style)
+  return buf.String(), err
+}
@@ -0,0 +1,65 @@
+package syntax
+
+import (
+  "io/fs"
+  "strings"
+  "testing"
+
+  "github.com/kjloveless/snippetbox/internal/assert"
+  "github.com/kjloveless/snippetbox/ui"
+)
+
+func TestDetect(t *testing.T) {
+  tests := []struct {
+    name    string
+    content string
+    want    string
+  }{
+    {
+      name:    "Go",
+      content: "package main\n\nfunc main() {}\n",
+      want:    "go",
+    },
+    {
+      name:    "Shell script",
+      content: "#!/bin/bash\necho hello\n",
+      want:    "bash",
+    },
+    {
+      name:    "Prose",
+      content: "an old silent pond...",
+      want:    "plaintext",
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      assert.Equal(t, Detect(tt.content), tt.want)
+    })
+  }
+}
+
+func TestRender(t *testing.T) {
+  html, language, err := Render("SELECT 1;\nSELECT '<b>';", "sql")
+
+  assert.NilError(t, err)
+  assert.Equal(t, language, "sql")
+
+  // Each line should have an anchor, the content should be escaped, and
+  // there should be no inline styles (which our CSP doesn't allow).
+  assert.StringContains(t, string(html), `<span class="ln" id="L2"><a class="lnlinks" href="#L2">2</a></span>`)
+  assert.StringContains(t, string(html), "&lt;b&gt;")
+  assert.Equal(t, strings.Contains(string(html), "style="), false)
+}
+
+func TestCSSUpToDate(t *testing.T) {
+  // The stylesheet in ui/static/css is generated from CSS(), so check that it
+  // hasn't drifted out of date.
+  css, err := CSS()
+  assert.NilError(t, err)
+
+  file, err := fs.ReadFile(ui.Files, "static/css/highlight.css")
+  assert.NilError(t, err)
+
+  assert.StringContains(t, string(file), css)
+}
@@ -16,6 +16,10 @@ import (
 var EmailRX =
 regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// TagRX matches a valid tag: between 1 and 32 lower-case letters, numbers and
+// the characters "+", ".", "_" and "-", starting with a letter or number.
+var TagRX = regexp.MustCompile("^[a-z0-9][a-z0-9+._-]{0,31}$")
+
 // Define a new Validator struct which contains a map of validation error
 // messages for our form fields.
 type Validator struct {
@@ -82,3 +86,19 @@ func Matches(value string, rx *regexp.Regexp) bool {
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {
   return slices.Contains(permittedValues, value)
 }
+
+// AllMatch() returns true if every value in a slice matches a provided
+// compiled regular expression pattern.
+func AllMatch(values []string, rx *regexp.Regexp) bool {
+  for _, value := range values {
+    if !rx.MatchString(value) {
+      return false
+    }
+  }
+  return true
+}
+
+// MaxItems() returns true if a slice contains no more than n items.
+func MaxItems[T any](values []T, n int) bool {
+  return len(values) <= n
+}
@@ -5,4 +5,62 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// Keep the "expires in" countdowns up to date. The data-expires attribute
+// holds the expiry time as a Unix timestamp, and we format the time left in
+// the same way as the until template function.
+var countdowns = document.querySelectorAll("[data-expires]");
+
+function updateCountdowns() {
+	for (var i = 0; i < countdowns.length; i++) {
+		var seconds = countdowns[i].dataset.expires - Date.now() / 1000;
+		var text;
+		if (seconds < 60) {
+			text = "less than a minute";
+		} else if (seconds < 3600) {
+			text = Math.floor(seconds / 60) + "m";
+		} else if (seconds < 48 * 3600) {
+			text = Math.floor(seconds / 3600) + "h";
+		} else {
+			text = Math.floor(seconds / 86400) + "d";
+		}
+		countdowns[i].textContent = text;
+	}
+}
+
+if (countdowns.length > 0) {
+	setInterval(updateCountdowns, 30000);
+}
+
+// The date and time for a custom snippet expiry has no time zone, so the
+// server reads it using the offset from UTC in the hidden tz_offset field.
+// The page gives us the time in that zone (UTC when editing a snippet), and
+// we show it in the browser's time zone instead. When the form is sent, we
+// fill in the browser's offset for the chosen time, which allows for daylight
+// saving time.
+var expiresAt = document.querySelector("input[name='expires_at']");
+var tzOffset = document.querySelector("input[name='tz_offset']");
+
+function pad(n) {
+	return (n < 10 ? "0" : "") + n;
+}
+
+if (expiresAt && tzOffset) {
+	if (expiresAt.value) {
+		// A time ending in Z is read as UTC, and getTimezoneOffset() style
+		// offsets are UTC minus local time, so adding the offset gives UTC.
+		var t = new Date(Date.parse(expiresAt.value + "Z") + tzOffset.value * 60000);
+		expiresAt.value = t.getFullYear() + "-" + pad(t.getMonth() + 1) + "-" + pad(t.getDate()) +
+			"T" + pad(t.getHours()) + ":" + pad(t.getMinutes());
+	}
+	tzOffset.value = new Date().getTimezoneOffset();
+	document.querySelector(".timezone").textContent = "your local time";
+
+	expiresAt.form.addEventListener("submit", function() {
+		if (expiresAt.value) {
+			// A date and time without a time zone is read as local time.
+			tzOffset.value = new Date(expiresAt.value).getTimezoneOffset();
+		}
+	});
+}